@@ -17,28 +17,117 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"io"
+	mathrand "math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
+
+	"quest/parseable"
 )
 
+// When and how often `HTTPClient.Do` retries a request. Only requests whose
+// body can be replayed are retried.
+type RetryPolicy struct {
+	// Total number of attempts; 1 or less means no retries.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Response statuses that are retried.
+	RetryStatuses []int
+	// Retry when the request fails without a response, e.g. on a
+	// connection reset or a timeout.
+	RetryTransportErrors bool
+}
+
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:          4,
+		InitialBackoff:       250 * time.Millisecond,
+		MaxBackoff:           5 * time.Second,
+		RetryStatuses:        []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryTransportErrors: true,
+	}
+}
+
+func (policy RetryPolicy) retriableStatus(status int) bool {
+	for _, s := range policy.RetryStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff before attempt `attempt` (counting from 1 for the first retry):
+// exponential, capped at `MaxBackoff`, with between half and all of it
+// randomised so that concurrent clients don't retry in lockstep.
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := policy.InitialBackoff
+	for i := 1; i < attempt && backoff < policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(mathrand.Int63n(int64(half)+1))
+}
+
 type HTTPClient struct {
 	client   http.Client
 	Url      url.URL
 	Username string
 	Password string
+	Retry    RetryPolicy
+	// Deadline of each attempt, including reading the response body. No
+	// deadline when 0.
+	RequestTimeout time.Duration
+	// Header that carries a random ID, the same for every attempt of a
+	// request, so the server can tell retries apart from new requests.
+	// Not sent when empty.
+	RequestIDHeader string
+	// Called with every request `Do` completes, e.g. to report it.
+	OnCall func(HTTPCall)
+}
+
+// A request sent by `HTTPClient.Do`, with all its attempts.
+type HTTPCall struct {
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Url       string        `json:"url"`
+	Status    int           `json:"status,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Attempts  int           `json:"attempts"`
+	Duration  time.Duration `json:"duration"`
+	RequestID string        `json:"request_id,omitempty"`
 }
 
 func DefaultClient(url url.URL, username string, password string) HTTPClient {
 	return HTTPClient{
-		client:   http.Client{Timeout: 60 * time.Second},
-		Url:      url,
-		Username: username,
-		Password: password,
+		client:         http.Client{},
+		Url:            url,
+		Username:       username,
+		Password:       password,
+		Retry:          NoRetry,
+		RequestTimeout: 60 * time.Second,
 	}
 }
 
+// Typed Parseable API over the client.
+func (client HTTPClient) API() *parseable.Client {
+	return parseable.New(&client)
+}
+
 func (client *HTTPClient) baseAPIURL(path string) (x string) {
 	x, _ = url.JoinPath(client.Url.String(), "api/v1/", path)
 	return
@@ -54,6 +143,112 @@ func (client *HTTPClient) NewRequest(method string, path string, body io.Reader)
 	return
 }
 
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Cancels the context of an attempt once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body cancelOnClose) Close() error {
+	err := body.ReadCloser.Close()
+	body.cancel()
+	return err
+}
+
+// Sends `req`, retrying it according to `client.Retry`. The response of the
+// last attempt is returned, whatever its status.
 func (client *HTTPClient) Do(req *http.Request) (*http.Response, error) {
-	return client.client.Do(req)
+	if client.RequestIDHeader != "" && req.Header.Get(client.RequestIDHeader) == "" {
+		req.Header.Set(client.RequestIDHeader, newRequestID())
+	}
+	if client.OnCall == nil {
+		response, _, err := client.do(req)
+		return response, err
+	}
+
+	start := time.Now()
+	response, attempts, err := client.do(req)
+	call := HTTPCall{
+		Time:     start,
+		Method:   req.Method,
+		Url:      req.URL.Redacted(),
+		Attempts: attempts,
+		Duration: time.Since(start),
+	}
+	if client.RequestIDHeader != "" {
+		call.RequestID = req.Header.Get(client.RequestIDHeader)
+	}
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.Status = response.StatusCode
+	}
+	client.OnCall(call)
+	return response, err
+}
+
+// Does the work of `Do`, also returning the number of attempts made.
+func (client *HTTPClient) do(req *http.Request) (*http.Response, int, error) {
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		response, err := client.attempt(req)
+
+		last := attempt >= client.Retry.MaxAttempts || !replayable
+		retry := false
+		switch {
+		case err != nil:
+			retry = client.Retry.RetryTransportErrors && req.Context().Err() == nil
+		default:
+			retry = client.Retry.retriableStatus(response.StatusCode)
+		}
+		if last || !retry {
+			return response, attempt, err
+		}
+
+		wait := client.Retry.backoff(attempt)
+		if response != nil {
+			if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+				if after := time.Duration(seconds) * time.Second; after > wait && (client.Retry.MaxBackoff == 0 || after <= client.Retry.MaxBackoff) {
+					wait = after
+				}
+			}
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, attempt, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, attempt, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+func (client *HTTPClient) attempt(req *http.Request) (*http.Response, error) {
+	if client.RequestTimeout <= 0 {
+		return client.client.Do(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), client.RequestTimeout)
+	response, err := client.client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
 }
@@ -0,0 +1,144 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fastRetries(attempts int) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = attempts
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 10 * time.Millisecond
+	return policy
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		backoff := policy.backoff(attempt + 1)
+		require.GreaterOrEqual(t, backoff, max/2, "attempt %d", attempt+1)
+		require.LessOrEqual(t, backoff, max, "attempt %d", attempt+1)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Run("retriable status", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "ingest", Status: http.StatusServiceUnavailable, Times: 2})
+		client := fake.Client()
+		client.Retry = fastRetries(3)
+		fakeIngest(t, client, "app", fakeFlogs(5))
+		require.Len(t, fake.Events("app"), 5)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "ingest", Status: http.StatusServiceUnavailable, Times: 3})
+		client := fake.Client()
+		client.Retry = fastRetries(3)
+		status, err := postEvents(client, "app", fakeFlogs(5))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, status)
+		require.Empty(t, fake.Events("app"))
+	})
+
+	t.Run("status not retried", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "ingest", Status: http.StatusBadRequest, Times: 1})
+		client := fake.Client()
+		client.Retry = fastRetries(3)
+		status, err := postEvents(client, "app", fakeFlogs(5))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("transport error", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "ingest", Drop: true, Times: 2})
+		client := fake.Client()
+		client.Retry = fastRetries(3)
+		fakeIngest(t, client, "app", fakeFlogs(5))
+		require.Len(t, fake.Events("app"), 5)
+	})
+
+	t.Run("no retries by default", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "ingest", Status: http.StatusServiceUnavailable, Times: 1})
+		status, err := postEvents(fake.Client(), "app", fakeFlogs(5))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, status)
+	})
+
+	t.Run("request timeout", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		fake.AddFault(FakeFault{Path: "liveness", Delay: 300 * time.Millisecond, Times: 1})
+		client := fake.Client()
+		client.RequestTimeout = 100 * time.Millisecond
+		require.Error(t, checkEndpoint(client, "liveness"))
+
+		fake.AddFault(FakeFault{Path: "liveness", Delay: 300 * time.Millisecond, Times: 1})
+		client.Retry = fastRetries(2)
+		require.NoError(t, checkEndpoint(client, "liveness"))
+	})
+}
+
+func TestClientRequestID(t *testing.T) {
+	var mu sync.Mutex
+	ids := make([]string, 0)
+	bodies := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		ids = append(ids, r.Header.Get("X-Request-Id"))
+		bodies = append(bodies, string(body))
+		if len(ids)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	client := DefaultClient(*u, "admin", "admin")
+	client.Retry = fastRetries(2)
+	client.RequestIDHeader = "X-Request-Id"
+
+	for i := 0; i < 2; i++ {
+		req, _ := client.NewRequest("POST", "ingest", strings.NewReader(`[{"a":1}]`))
+		response, err := client.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	require.Len(t, ids, 4)
+	require.NotEmpty(t, ids[0])
+	require.Equal(t, ids[0], ids[1], "retries must reuse the request ID")
+	require.Equal(t, ids[2], ids[3], "retries must reuse the request ID")
+	require.NotEqual(t, ids[0], ids[2])
+	require.Equal(t, []string{`[{"a":1}]`, `[{"a":1}]`, `[{"a":1}]`, `[{"a":1}]`}, bodies)
+}
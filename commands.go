@@ -0,0 +1,346 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"testing"
+	"time"
+
+	"quest/parseable"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name string
+	help string
+	// Runs the command and returns its exit code, nil for commands that run
+	// `tests` instead.
+	run   func() int
+	tests []testing.InternalTest
+}
+
+var commands = []command{
+	{name: "smoke", help: "Run smoke tests against the configured Parseable", tests: smokeTests},
+	{name: "load", help: "Run load tests against the configured Parseable", tests: loadTests},
+	{name: "integrity", help: "Ingest generated flog events and verify them against the stored parquet files", tests: integrityTests},
+	{name: "cleanup", help: "Delete streams, users and roles left behind by quest runs", run: cleanupCommand},
+	{name: "doctor", help: "Check connectivity and credentials", run: doctorCommand},
+	{name: "report", help: "Print server info and stream stats as JSON", run: reportCommand},
+}
+
+func runCommand(args []string) int {
+	if len(args) != 1 {
+		flag.Usage()
+		return exitUsage
+	}
+	for _, cmd := range commands {
+		switch {
+		case cmd.name != args[0]:
+		case cmd.run == nil && reporting() && os.Getenv(reportStateEnv) == "":
+			return runReported()
+		case cmd.run == nil:
+			runSuite(cmd.name, cmd.tests)
+		default:
+			return cmd.run()
+		}
+	}
+	fmt.Fprintf(os.Stderr, "quest: unknown command %q\n\n", args[0])
+	flag.Usage()
+	return exitUsage
+}
+
+// Runs `tests` the same way `go test` would, honouring the `-test.*` flags.
+// This never returns; the process exits with the result of the run.
+func runSuite(suite string, tests []testing.InternalTest) {
+	verboseSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "test.v" {
+			verboseSet = true
+		}
+	})
+	if !verboseSet {
+		flag.Set("test.v", "true")
+	}
+
+	if state := os.Getenv(reportStateEnv); state != "" {
+		tests = withReport(suite, tests, state)
+	}
+
+	testing.Main(regexp.MatchString, tests, nil, nil)
+}
+
+// Set for the process runReported runs a suite in, to the path that process
+// writes its JSON report to.
+const reportStateEnv = "QUEST_REPORT_STATE"
+
+func reporting() bool {
+	return NewGlob.Config.ReportJUnit != "" || NewGlob.Config.ReportJSON != ""
+}
+
+// Runs the command again in a process of its own, capturing its output so
+// that the reports carry what each failed test logged. The output can't be
+// captured in this process: `testing.Main` exits before all of it is read.
+func runReported() int {
+	state, err := os.CreateTemp("", "quest-report-*.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: running the suite: %s\n", err)
+		return exitFailure
+	}
+	state.Close()
+	defer os.Remove(state.Name())
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: running the suite: %s\n", err)
+		return exitFailure
+	}
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), reportStateEnv+"="+state.Name())
+	cmd.Stdin, cmd.Stderr = os.Stdin, os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: running the suite: %s\n", err)
+		return exitFailure
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	output := NewTestOutput()
+	if err := output.Capture(stdout, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "quest: capturing the test output: %s\n", err)
+		io.Copy(io.Discard, stdout)
+	}
+	cmd.Wait()
+
+	report, err := LoadRunReport(state.Name())
+	if err == nil {
+		report.AddOutput(output)
+		err = report.Save(NewGlob.Config.ReportJUnit, NewGlob.Config.ReportJSON)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: writing the report: %s\n", err)
+	}
+	if code := cmd.ProcessState.ExitCode(); code >= 0 {
+		return code
+	}
+	return exitFailure
+}
+
+// Records `tests` and the requests they make, rewriting the reports and the
+// JSON report at `state` each time a test finishes since `testing.Main` exits
+// once the last one does.
+func withReport(suite string, tests []testing.InternalTest, state string) []testing.InternalTest {
+	var about *parseable.About
+	info, err := NewGlob.QueryClient.API().About()
+	if err == nil {
+		about = &info
+	}
+	reporter := NewReporter(suite, NewGlob.Config, about, err)
+
+	wrapped := make([]testing.InternalTest, 0, len(tests))
+	for _, test := range tests {
+		test := reporter.Wrap(test)
+		wrapped = append(wrapped, testing.InternalTest{Name: test.Name, F: func(t *testing.T) {
+			// Cleanups run last first, so this runs once the reporter
+			// recorded the test.
+			t.Cleanup(func() {
+				err := errors.Join(
+					reporter.Save(NewGlob.Config.ReportJUnit, NewGlob.Config.ReportJSON),
+					reporter.Save("", state),
+				)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "quest: writing the report: %s\n", err)
+				}
+			})
+			test.F(t)
+		}})
+	}
+	return wrapped
+}
+
+var integrityTests = []testing.InternalTest{
+	{Name: "Integrity", F: func(t *testing.T) {
+		CheckIntegrity(t, NewTestStream(t, NewGlob.For(t).QueryClient, parseable.StreamOptions{}))
+	}},
+}
+
+// Deletes the streams, users and roles quest runs left behind, see
+// `FindOrphans`, once they are `-cleanup-min-age` old. Users and roles with
+// the fixed names of older versions only go with `-cleanup-legacy`. Missing resources are
+// not an error; server errors and failed requests are.
+func cleanupCommand() int {
+	api := NewGlob.QueryClient.API()
+	now := time.Now()
+	orphans, err := FindOrphans(api, NewGlob.Stream, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: listing streams, users and roles: %s\n", err)
+		return exitFailure
+	}
+
+	code := exitOK
+	for _, orphan := range orphans {
+		age := orphan.Age(now)
+		switch {
+		case !orphan.Expired(now, NewGlob.Config.CleanupMinAge, NewGlob.Config.CleanupLegacy):
+			if !NewGlob.Config.CleanupLegacy && orphan.Expired(now, NewGlob.Config.CleanupMinAge, true) {
+				age += ", -cleanup-legacy deletes it"
+			}
+			fmt.Printf("kept %s %s: %s\n", orphan.Kind, orphan.Name, age)
+		case NewGlob.Config.CleanupDryRun:
+			fmt.Printf("would delete %s %s: %s\n", orphan.Kind, orphan.Name, age)
+		default:
+			if err := orphan.Delete(api); err != nil && !alreadyDeleted(err) {
+				fmt.Fprintf(os.Stderr, "%s %s: %s\n", orphan.Kind, orphan.Name, err)
+				code = exitFailure
+				continue
+			}
+			fmt.Printf("deleted %s %s: %s\n", orphan.Kind, orphan.Name, age)
+		}
+	}
+	return code
+}
+
+func checkEndpoint(client HTTPClient, path string) error {
+	req, err := client.NewRequest("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	body := readAsString(response.Body)
+	if response.StatusCode != 200 {
+		return fmt.Errorf("GET %s returned http code: %s and response: %s", path, response.Status, body)
+	}
+	return nil
+}
+
+func doctorCommand() int {
+	type check struct {
+		name string
+		run  func() error
+	}
+	checks := []check{
+		{"query liveness", func() error { return checkEndpoint(NewGlob.QueryClient, "liveness") }},
+		{"query credentials", func() error { return checkEndpoint(NewGlob.QueryClient, "logstream") }},
+	}
+	if NewGlob.IngestorUrl.String() != "" {
+		for _, client := range NewGlob.Ingestors.Clients {
+			client := client
+			checks = append(checks,
+				check{"ingestor liveness " + client.Url.Host, func() error { return checkEndpoint(client, "liveness") }},
+				check{"ingestor credentials " + client.Url.Host, func() error { return checkEndpoint(client, "logstream") }},
+			)
+		}
+	}
+	checks = append(checks,
+		check{"object store " + NewGlob.Store.String(), func() error { return NewGlob.Store.Check() }},
+	)
+
+	code := exitOK
+	for _, c := range checks {
+		if err := c.run(); err != nil {
+			fmt.Printf("FAIL %-20s %s\n", c.name, err)
+			code = exitFailure
+		} else {
+			fmt.Printf("ok   %s\n", c.name)
+		}
+	}
+	return code
+}
+
+func getJSON(client HTTPClient, path string) (json.RawMessage, error) {
+	req, _ := client.NewRequest("GET", path, nil)
+	response, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body := readAsString(response.Body)
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("GET %s returned http code: %s and response: %s", path, response.Status, body)
+	}
+	if !json.Valid([]byte(body)) {
+		return nil, fmt.Errorf("GET %s returned invalid JSON: %s", path, body)
+	}
+	return json.RawMessage(body), nil
+}
+
+func reportCommand() int {
+	about, err := getJSON(NewGlob.QueryClient, "about")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var streams []struct {
+		Name string `json:"name"`
+	}
+	raw, err := getJSON(NewGlob.QueryClient, "logstream")
+	if err == nil {
+		err = json.Unmarshal(raw, &streams)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	stats := make(map[string]json.RawMessage, len(streams))
+	for _, stream := range streams {
+		stat, err := getJSON(NewGlob.QueryClient, "logstream/"+stream.Name+"/stats")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		stats[stream.Name] = stat
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	out.SetIndent("", "  ")
+	err = out.Encode(map[string]interface{}{
+		"about": about,
+		"stats": stats,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	return exitOK
+}
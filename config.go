@@ -0,0 +1,470 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings quest runs with. Every setting can come from a config file, a
+// `QUEST_*` environment variable or a flag, in increasing order of
+// precedence; see `LoadConfig`.
+type Config struct {
+	QueryUrl             string
+	QueryUser            string
+	QueryPass            string
+	IngestorUrl          string
+	IngestorUser         string
+	IngestorPass         string
+	IngestorDistribution string
+	Stream               string
+	Mode                 string
+	MinioUrl             string
+	MinioUser            string
+	MinioPass            string
+	MinioBucket          string
+	MinioTLS             bool
+	MinioCACert          string
+	MinioBucketLookup    string
+	MinioRegion          string
+	Store                string
+	StoreDir             string
+	StoreServerDir       string
+	Retries              int
+	RequestTimeout       time.Duration
+	RequestIDHeader      string
+	ReportJUnit          string
+	ReportJSON           string
+	PerfBaseline         string
+	PerfSave             string
+	ThroughputTolerance  float64
+	LatencyTolerance     float64
+	ErrorRateTolerance   float64
+	FuzzQueries          int
+	FuzzSeed             int64
+	LogSeed              int64
+	CleanupMinAge        time.Duration
+	CleanupDryRun        bool
+	CleanupLegacy        bool
+	WebhookListen        string
+	WebhookUrl           string
+	RetentionTimeout     time.Duration
+}
+
+// Registers a flag for every setting of `config` on `fs`, with the defaults.
+// The flag names are also the keys of the config file and, upper-cased with
+// `_` for `-` and a `QUEST_` prefix, the names of the environment variables.
+func bindConfigFlags(fs *flag.FlagSet, config *Config) {
+	fs.StringVar(&config.QueryUrl, "query-url", "http://localhost:8000", "Specify url. Default is root")
+	fs.StringVar(&config.QueryUser, "query-user", "admin", "Specify username. Default is admin")
+	fs.StringVar(&config.QueryPass, "query-pass", "admin", "Specify pass. Default is admin")
+
+	fs.StringVar(&config.IngestorUrl, "ingestor-url", "", "Specify url, or a comma separated list of ingestor urls. Default is root")
+	fs.StringVar(&config.IngestorUser, "ingestor-user", "admin", "Specify username. Default is admin")
+	fs.StringVar(&config.IngestorPass, "ingestor-pass", "admin", "Specify pass. Default is admin")
+	fs.StringVar(&config.IngestorDistribution, "ingestor-distribution", string(RoundRobin), "How events are spread across ingestors: round-robin, random or hash (by stream). Default is round-robin")
+
+	fs.StringVar(&config.Stream, "stream", "app", "Specify stream. Default is app")
+	fs.StringVar(&config.Mode, "mode", "smoke", "Specify mode. Default is smoke")
+
+	fs.StringVar(&config.MinioUrl, "minio-url", "localhost:9000", "Specify MinIO URL. Default is localhost:9000")
+	fs.StringVar(&config.MinioUser, "minio-user", "minioadmin", "Specify MinIO User. Default is `minioadmin`")
+	fs.StringVar(&config.MinioPass, "minio-pass", "minioadmin", "Specify MinIO Password. Default is `minioadmin`")
+	fs.StringVar(&config.MinioBucket, "minio-bucket", "parseable", "Specify the name of MinIO Bucket. Default is `integrity-test`")
+	fs.BoolVar(&config.MinioTLS, "minio-tls", false, "Connect to MinIO over https")
+	fs.StringVar(&config.MinioCACert, "minio-ca-cert", "", "PEM file of a CA to trust for MinIO besides the system ones")
+	fs.StringVar(&config.MinioBucketLookup, "minio-bucket-lookup", "auto", "How the bucket is addressed: auto, path (host/bucket) or virtual-host (bucket.host). Default is auto")
+	fs.StringVar(&config.MinioRegion, "minio-region", "", "Region of the bucket. Looked up by default")
+	fs.StringVar(&config.Store, "store", "s3", "Where Parseable keeps stream data: s3 (MinIO or another S3 compatible store) or local. Default is s3")
+	fs.StringVar(&config.StoreDir, "store-dir", "", "Data directory of Parseable's local store, mounted where quest runs, for -store=local")
+	fs.StringVar(&config.StoreServerDir, "store-server-dir", "", "Data directory of Parseable's local store where Parseable runs, its --fs-dir. Default is -store-dir")
+
+	fs.IntVar(&config.Retries, "retries", 0, "Number of times a request is retried on a transport error or a 429, 502, 503 or 504 response. Default is 0")
+	fs.DurationVar(&config.RequestTimeout, "request-timeout", 60*time.Second, "Deadline of each request to Parseable. Default is 60s")
+	fs.StringVar(&config.RequestIDHeader, "request-id-header", "", "Header to send a unique ID per request in, e.g. X-Request-Id. Not sent by default")
+
+	fs.StringVar(&config.ReportJUnit, "report-junit", "", "Path to write a JUnit XML report of the run to. Not written by default")
+	fs.StringVar(&config.ReportJSON, "report-json", "", "Path to write a JSON report of the run to. Not written by default")
+
+	fs.StringVar(&config.PerfBaseline, "perf-baseline", "", "Path of a baseline of load results to compare the load tests against. Not compared by default")
+	fs.StringVar(&config.PerfSave, "perf-save", "", "Path to save the load results to, to be used as a baseline. Not saved by default")
+	fs.Float64Var(&config.ThroughputTolerance, "perf-throughput-tolerance", 0.1, "Fraction by which events/s may drop below the baseline. Default is 0.1")
+	fs.Float64Var(&config.LatencyTolerance, "perf-latency-tolerance", 0.2, "Fraction by which p50, p95 and p99 latency may rise above the baseline. Default is 0.2")
+	fs.Float64Var(&config.ErrorRateTolerance, "perf-error-rate-tolerance", 0.01, "Amount by which the error rate may rise above the baseline. Default is 0.01")
+
+	fs.IntVar(&config.FuzzQueries, "fuzz-queries", 100, "Number of random queries the query fuzzer runs. Default is 100")
+	fs.Int64Var(&config.FuzzSeed, "fuzz-seed", 0, "Seed of the query fuzzer, to reproduce a run. Random by default")
+	fs.Int64Var(&config.LogSeed, "log-seed", 0, "Seed of the generated flog events, to replay a run. Random by default")
+
+	fs.DurationVar(&config.CleanupMinAge, "cleanup-min-age", time.Hour, "Age a stream, user or role must reach before cleanup deletes it. Default is 1h")
+	fs.BoolVar(&config.CleanupDryRun, "cleanup-dry-run", false, "Only list what cleanup would delete")
+	fs.BoolVar(&config.CleanupLegacy, "cleanup-legacy", false, "Let cleanup delete the streams, users and roles named the way older versions of quest named them")
+	fs.StringVar(&config.WebhookListen, "webhook-listen", ":0", "Address the webhook sink the alert tests point alerts at listens on. Default is a random port")
+	fs.DurationVar(&config.RetentionTimeout, "retention-timeout", 0, "How long to wait for Parseable to enforce retention. Default is 0, which only checks that it is set")
+	fs.StringVar(&config.WebhookUrl, "webhook-url", "", "URL Parseable reaches the webhook sink at. Default is http://127.0.0.1 and its port, if Parseable runs on this host")
+}
+
+// The settings of `config` by name, with passwords redacted.
+func (config Config) Settings() map[string]string {
+	c := config
+	fs := flag.NewFlagSet("settings", flag.ContinueOnError)
+	bindConfigFlags(fs, &c)
+	// Binding the flags reset `c` to the defaults.
+	c = config
+
+	settings := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if strings.HasSuffix(f.Name, "-pass") && value != "" {
+			value = "REDACTED"
+		}
+		settings[f.Name] = value
+	})
+	return settings
+}
+
+func configEnvName(setting string) string {
+	return "QUEST_" + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// Contents of a config file: settings shared by all profiles, the profiles
+// and the profile used when none is given.
+type configFile struct {
+	settings       map[string]interface{}
+	profiles       map[string]map[string]interface{}
+	defaultProfile string
+}
+
+func readConfigFile(path string) (configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return configFile{}, err
+	}
+	var raw map[string]interface{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return configFile{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	file := configFile{settings: raw, profiles: map[string]map[string]interface{}{}}
+	if profile, ok := raw["profile"]; ok {
+		name, ok := profile.(string)
+		if !ok {
+			return configFile{}, fmt.Errorf("%s: profile must be a string", path)
+		}
+		file.defaultProfile = name
+		delete(raw, "profile")
+	}
+	if profiles, ok := raw["profiles"]; ok {
+		m, ok := profiles.(map[string]interface{})
+		if !ok {
+			return configFile{}, fmt.Errorf("%s: profiles must be a map of profile names to settings", path)
+		}
+		for name, settings := range m {
+			s, ok := settings.(map[string]interface{})
+			if !ok {
+				return configFile{}, fmt.Errorf("%s: profile %s must be a map of settings", path, name)
+			}
+			file.profiles[name] = s
+		}
+		delete(raw, "profiles")
+	}
+	return file, nil
+}
+
+// Sets the settings in `values` on `fs`, which has the config flags. Keys may
+// use `_` or `-`.
+func applyConfigSettings(fs *flag.FlagSet, source string, values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", source, key))
+			continue
+		}
+		switch value := values[key].(type) {
+		case map[string]interface{}, []interface{}:
+			errs = append(errs, fmt.Errorf("%s: %s must be a single value", source, key))
+		default:
+			if err := fs.Set(name, fmt.Sprint(value)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", source, key, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Builds the config from, in increasing order of precedence: the defaults,
+// the shared settings of the config file at `path`, the settings of
+// `profile` in it, `QUEST_*` environment variables and the flags in `flags`
+// (those given on the command line). When empty, `path` and `profile` come
+// from `QUEST_CONFIG` and `QUEST_PROFILE`, and the profile then from the
+// file's `profile` key.
+func LoadConfig(path string, profile string, lookupEnv func(string) (string, bool), flags map[string]string) (Config, error) {
+	var config Config
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	bindConfigFlags(fs, &config)
+
+	if path == "" {
+		path, _ = lookupEnv("QUEST_CONFIG")
+	}
+	if profile == "" {
+		profile, _ = lookupEnv("QUEST_PROFILE")
+	}
+
+	if path != "" {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		if err := applyConfigSettings(fs, path, file.settings); err != nil {
+			return Config{}, err
+		}
+		if profile == "" {
+			profile = file.defaultProfile
+		}
+		if profile != "" {
+			settings, ok := file.profiles[profile]
+			if !ok {
+				return Config{}, fmt.Errorf("%s: no profile named %q", path, profile)
+			}
+			if err := applyConfigSettings(fs, path+": profile "+profile, settings); err != nil {
+				return Config{}, err
+			}
+		}
+	} else if profile != "" {
+		return Config{}, fmt.Errorf("profile %q given without a config file", profile)
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := configEnvName(f.Name)
+		if value, ok := lookupEnv(name); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	})
+	for name, value := range flags {
+		if err := fs.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+
+	return config, config.Validate()
+}
+
+func validateUrl(setting string, value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", setting, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s: %q is not an http(s) URL", setting, value)
+	}
+	return nil
+}
+
+// Reports every invalid setting.
+func (config Config) Validate() error {
+	var errs []error
+	if err := validateUrl("query-url", config.QueryUrl); err != nil {
+		errs = append(errs, err)
+	}
+	for _, u := range config.IngestorUrls() {
+		if err := validateUrl("ingestor-url", u); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := ParseDistribution(config.IngestorDistribution); err != nil {
+		errs = append(errs, fmt.Errorf("ingestor-distribution: %w", err))
+	}
+	if config.Stream == "" {
+		errs = append(errs, errors.New("stream: must not be empty"))
+	}
+	switch config.Store {
+	case "s3":
+		if config.MinioUrl == "" || strings.Contains(config.MinioUrl, "://") {
+			errs = append(errs, fmt.Errorf("minio-url: %q must be a host:port without a scheme", config.MinioUrl))
+		}
+		if config.MinioBucket == "" {
+			errs = append(errs, errors.New("minio-bucket: must not be empty"))
+		}
+		if _, ok := bucketLookups[config.MinioBucketLookup]; !ok {
+			errs = append(errs, fmt.Errorf("minio-bucket-lookup: %q must be auto, path or virtual-host", config.MinioBucketLookup))
+		}
+		if config.MinioCACert != "" {
+			if !config.MinioTLS {
+				errs = append(errs, errors.New("minio-ca-cert: needs -minio-tls"))
+			} else if _, err := loadCACert(config.MinioCACert); err != nil {
+				errs = append(errs, fmt.Errorf("minio-ca-cert: %w", err))
+			}
+		}
+	case "local":
+		if config.StoreDir == "" {
+			errs = append(errs, errors.New("store-dir: must be set for -store=local"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store: %q must be s3 or local", config.Store))
+	}
+	if config.Retries < 0 {
+		errs = append(errs, fmt.Errorf("retries: %d must not be negative", config.Retries))
+	}
+	if config.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("request-timeout: %s must not be negative", config.RequestTimeout))
+	}
+	if config.CleanupMinAge < 0 {
+		errs = append(errs, fmt.Errorf("cleanup-min-age: %s must not be negative", config.CleanupMinAge))
+	}
+	if config.WebhookUrl != "" {
+		if err := validateUrl("webhook-url", config.WebhookUrl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if config.RetentionTimeout < 0 {
+		errs = append(errs, fmt.Errorf("retention-timeout: %s must not be negative", config.RetentionTimeout))
+	}
+	if config.FuzzQueries < 0 {
+		errs = append(errs, fmt.Errorf("fuzz-queries: %d must not be negative", config.FuzzQueries))
+	}
+	tolerances := []struct {
+		setting string
+		value   float64
+	}{
+		{"perf-throughput-tolerance", config.ThroughputTolerance},
+		{"perf-latency-tolerance", config.LatencyTolerance},
+		{"perf-error-rate-tolerance", config.ErrorRateTolerance},
+	}
+	for _, tolerance := range tolerances {
+		if tolerance.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: %g must not be negative", tolerance.setting, tolerance.value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// The ingestor URLs in `IngestorUrl`.
+func (config Config) IngestorUrls() []string {
+	var urls []string
+	for _, u := range strings.Split(config.IngestorUrl, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+func (config Config) client(rawUrl string, username string, password string) HTTPClient {
+	u, _ := url.Parse(rawUrl)
+	client := DefaultClient(*u, username, password)
+	if config.Retries > 0 {
+		client.Retry = DefaultRetryPolicy()
+		client.Retry.MaxAttempts = config.Retries + 1
+	}
+	client.RequestTimeout = config.RequestTimeout
+	client.RequestIDHeader = config.RequestIDHeader
+	return client
+}
+
+func MinIoConfigOf(config Config) MinIoConfig {
+	return MinIoConfig{
+		Url:          config.MinioUrl,
+		User:         config.MinioUser,
+		Pass:         config.MinioPass,
+		Bucket:       config.MinioBucket,
+		TLS:          config.MinioTLS,
+		CACert:       config.MinioCACert,
+		BucketLookup: config.MinioBucketLookup,
+		Region:       config.MinioRegion,
+	}
+}
+
+// Builds the `Glob` for a config that passed `Validate`. Fails if the object
+// store can't be set up, e.g. with a MinIO CA certificate that can't be read.
+func NewGlobFromConfig(config Config) (Glob, error) {
+	queryClient := config.client(config.QueryUrl, config.QueryUser, config.QueryPass)
+	glob := Glob{
+		QueryUrl:      queryClient.Url,
+		QueryUsername: config.QueryUser,
+		QueryPassword: config.QueryPass,
+		QueryClient:   queryClient,
+		Stream:        config.Stream,
+		Mode:          config.Mode,
+		Config:        config,
+		MinIoConfig:   MinIoConfigOf(config),
+	}
+	store, err := NewObjectStore(config)
+	if err != nil {
+		return Glob{}, fmt.Errorf("store: %w", err)
+	}
+	glob.Store = store
+	if config.PerfBaseline != "" || config.PerfSave != "" {
+		glob.Perf = &PerfGate{
+			BaselinePath: config.PerfBaseline,
+			SavePath:     config.PerfSave,
+			Tolerances: PerfTolerances{
+				Throughput: config.ThroughputTolerance,
+				Latency:    config.LatencyTolerance,
+				ErrorRate:  config.ErrorRateTolerance,
+			},
+		}
+	}
+
+	distribution, _ := ParseDistribution(config.IngestorDistribution)
+	urls := config.IngestorUrls()
+	if len(urls) == 0 {
+		glob.Ingestors = NewIngestorPool(distribution, time.Now().UnixNano(), queryClient)
+		return glob, nil
+	}
+
+	clients := make([]HTTPClient, 0, len(urls))
+	for _, u := range urls {
+		clients = append(clients, config.client(u, config.IngestorUser, config.IngestorPass))
+	}
+	glob.Ingestors = NewIngestorPool(distribution, time.Now().UnixNano(), clients...)
+	glob.IngestorClient = clients[0]
+	glob.IngestorUrl = clients[0].Url
+	glob.IngestorUsername = config.IngestorUser
+	glob.IngestorPassword = config.IngestorPass
+	return glob, nil
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, name string, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+	return path
+}
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	}
+}
+
+const testConfigYAML = `
+query-user: quest
+minio_bucket: logs
+profile: dev
+profiles:
+  dev:
+    query-url: http://dev.local:8000
+  prod:
+    query-url: https://prod.example.com
+    ingestor-url: https://ingest.example.com
+    retries: 3
+    request-timeout: 30s
+`
+
+func TestLoadConfigDefaults(t *testing.T) {
+	config, err := LoadConfig("", "", env(nil), nil)
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8000", config.QueryUrl)
+	require.Equal(t, "app", config.Stream)
+	require.Equal(t, 60*time.Second, config.RequestTimeout)
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	path := writeConfig(t, "quest.yaml", testConfigYAML)
+
+	t.Run("default profile", func(t *testing.T) {
+		config, err := LoadConfig(path, "", env(nil), nil)
+		require.NoError(t, err)
+		require.Equal(t, "http://dev.local:8000", config.QueryUrl)
+		require.Equal(t, "quest", config.QueryUser)
+		require.Equal(t, "logs", config.MinioBucket)
+		require.Equal(t, 0, config.Retries)
+	})
+
+	t.Run("named profile", func(t *testing.T) {
+		config, err := LoadConfig(path, "prod", env(nil), nil)
+		require.NoError(t, err)
+		require.Equal(t, "https://prod.example.com", config.QueryUrl)
+		require.Equal(t, "https://ingest.example.com", config.IngestorUrl)
+		require.Equal(t, 3, config.Retries)
+		require.Equal(t, 30*time.Second, config.RequestTimeout)
+	})
+
+	t.Run("environment over file", func(t *testing.T) {
+		config, err := LoadConfig("", "", env(map[string]string{
+			"QUEST_CONFIG":      path,
+			"QUEST_PROFILE":     "prod",
+			"QUEST_QUERY_USER":  "envuser",
+			"QUEST_MINIO_URL":   "minio:9000",
+			"QUEST_QUERY_PASS":  "envpass",
+			"QUEST_UNSUPPORTED": "ignored",
+		}), nil)
+		require.NoError(t, err)
+		require.Equal(t, "https://prod.example.com", config.QueryUrl)
+		require.Equal(t, "envuser", config.QueryUser)
+		require.Equal(t, "envpass", config.QueryPass)
+		require.Equal(t, "minio:9000", config.MinioUrl)
+	})
+
+	t.Run("flags over environment", func(t *testing.T) {
+		config, err := LoadConfig(path, "", env(map[string]string{"QUEST_STREAM": "envstream"}), map[string]string{"stream": "flagstream"})
+		require.NoError(t, err)
+		require.Equal(t, "flagstream", config.Stream)
+	})
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	path := writeConfig(t, "quest.json", `{"stream": "jsonstream", "profiles": {"staging": {"query_url": "http://staging:8000", "retries": 2}}}`)
+	config, err := LoadConfig(path, "staging", env(nil), nil)
+	require.NoError(t, err)
+	require.Equal(t, "jsonstream", config.Stream)
+	require.Equal(t, "http://staging:8000", config.QueryUrl)
+	require.Equal(t, 2, config.Retries)
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := writeConfig(t, "quest.yaml", testConfigYAML)
+
+	_, err := LoadConfig(path, "qa", env(nil), nil)
+	require.ErrorContains(t, err, `no profile named "qa"`)
+
+	_, err = LoadConfig("", "prod", env(nil), nil)
+	require.ErrorContains(t, err, "without a config file")
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"), "", env(nil), nil)
+	require.Error(t, err)
+
+	bad := writeConfig(t, "bad.yaml", "query-url: [a, b]\nquery-host: x\n")
+	_, err = LoadConfig(bad, "", env(nil), nil)
+	require.ErrorContains(t, err, "query-url must be a single value")
+	require.ErrorContains(t, err, `unknown setting "query-host"`)
+
+	_, err = LoadConfig("", "", env(map[string]string{"QUEST_RETRIES": "many"}), nil)
+	require.ErrorContains(t, err, "QUEST_RETRIES")
+
+	_, err = LoadConfig("", "", env(nil), map[string]string{
+		"query-url":              "localhost:8000",
+		"ingestor-url":           "ftp://ingest",
+		"minio-url":              "http://minio:9000",
+		"stream":                 "",
+		"retries":                "-1",
+		"ingestor-distribution":  "sticky",
+		"perf-latency-tolerance": "-0.5",
+		"fuzz-queries":           "-1",
+		"cleanup-min-age":        "-1h",
+		"webhook-url":            "sink:9000",
+		"retention-timeout":      "-1s",
+	})
+	require.ErrorContains(t, err, "query-url")
+	require.ErrorContains(t, err, "ingestor-url")
+	require.ErrorContains(t, err, "minio-url")
+	require.ErrorContains(t, err, "stream")
+	require.ErrorContains(t, err, "retries")
+	require.ErrorContains(t, err, "ingestor-distribution")
+	require.ErrorContains(t, err, "perf-latency-tolerance")
+	require.ErrorContains(t, err, "fuzz-queries")
+	require.ErrorContains(t, err, "cleanup-min-age")
+	require.ErrorContains(t, err, "webhook-url")
+	require.ErrorContains(t, err, "retention-timeout")
+}
+
+func TestNewGlobFromConfig(t *testing.T) {
+	config, err := LoadConfig("", "", env(map[string]string{
+		"QUEST_INGESTOR_URL": "http://ingest:8000",
+		"QUEST_RETRIES":      "2",
+	}), nil)
+	require.NoError(t, err)
+
+	glob, err := NewGlobFromConfig(config)
+	require.NoError(t, err)
+	require.Equal(t, "localhost:8000", glob.QueryUrl.Host)
+	require.Equal(t, "ingest:8000", glob.IngestClient().Url.Host)
+	require.Equal(t, 3, glob.QueryClient.Retry.MaxAttempts)
+	require.Equal(t, 3, glob.IngestorClient.Retry.MaxAttempts)
+	require.Len(t, glob.Ingestors.Clients, 1)
+	require.Nil(t, glob.Perf)
+
+	config, err = LoadConfig("", "", env(map[string]string{
+		"QUEST_INGESTOR_URL":          "http://ingest-0:8000, http://ingest-1:8000",
+		"QUEST_INGESTOR_DISTRIBUTION": "hash",
+	}), nil)
+	require.NoError(t, err)
+	glob, err = NewGlobFromConfig(config)
+	require.NoError(t, err)
+	require.Equal(t, HashByStream, glob.Ingestors.Distribution)
+	require.Len(t, glob.Ingestors.Clients, 2)
+	require.Equal(t, "ingest-1:8000", glob.Ingestors.Clients[1].Url.Host)
+	require.Equal(t, "ingest-0:8000", glob.IngestClient().Url.Host)
+
+	config, err = LoadConfig("", "", env(nil), map[string]string{"perf-baseline": "baseline.json", "perf-throughput-tolerance": "0.05"})
+	require.NoError(t, err)
+	glob, err = NewGlobFromConfig(config)
+	require.NoError(t, err)
+	require.Equal(t, "baseline.json", glob.Perf.BaselinePath)
+	require.Equal(t, PerfTolerances{Throughput: 0.05, Latency: 0.2, ErrorRate: 0.01}, glob.Perf.Tolerances)
+
+	glob, err = NewGlobFromConfig(Config{QueryUrl: "http://localhost:8000", IngestorDistribution: "round-robin", Store: "local"})
+	require.NoError(t, err)
+	require.Equal(t, []HTTPClient{glob.QueryClient}, glob.Ingestors.Clients)
+
+	_, err = NewGlobFromConfig(Config{QueryUrl: "http://localhost:8000", IngestorDistribution: "round-robin", Store: "gcs"})
+	require.ErrorContains(t, err, "store")
+}
+
+func TestLoadConfigStore(t *testing.T) {
+	config, err := LoadConfig("", "", env(nil), map[string]string{"store": "local", "store-dir": "/parseable/data", "minio-url": ""})
+	require.NoError(t, err)
+	glob, err := NewGlobFromConfig(config)
+	require.NoError(t, err)
+	require.Equal(t, "directory /parseable/data", glob.Store.String())
+
+	config, err = LoadConfig("", "", env(nil), nil)
+	require.NoError(t, err)
+	glob, err = NewGlobFromConfig(config)
+	require.NoError(t, err)
+	require.Equal(t, "bucket parseable at localhost:9000", glob.Store.String())
+
+	_, err = LoadConfig("", "", env(nil), map[string]string{"store": "local"})
+	require.ErrorContains(t, err, "store-dir")
+	_, err = LoadConfig("", "", env(nil), map[string]string{"store": "gcs"})
+	require.ErrorContains(t, err, "store")
+	_, err = LoadConfig("", "", env(nil), map[string]string{"minio-bucket-lookup": "dns", "minio-ca-cert": "ca.pem"})
+	require.ErrorContains(t, err, "minio-bucket-lookup")
+	require.ErrorContains(t, err, "minio-ca-cert: needs -minio-tls")
+	_, err = LoadConfig("", "", env(nil), map[string]string{"minio-tls": "true", "minio-ca-cert": "missing.pem"})
+	require.ErrorContains(t, err, "minio-ca-cert")
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Number of example rows of each kind printed by `RowDiff.String`.
+const diffExamples = 5
+
+type DuplicatedRow struct {
+	Record Record `json:"record"`
+	// Number of copies stored beyond the ones that were sent.
+	Copies int `json:"copies"`
+}
+
+type MismatchedRow struct {
+	Expected Record          `json:"expected"`
+	Actual   Record          `json:"actual"`
+	Fields   []FieldMismatch `json:"fields"`
+}
+
+// Result of comparing the events that were sent with the rows that were
+// stored, as multisets.
+type RowDiff struct {
+	Sent    int `json:"sent"`
+	Stored  int `json:"stored"`
+	Matched int `json:"matched"`
+	// Events that were sent but not stored.
+	Missing []Record `json:"missing,omitempty"`
+	// Rows that were stored but never sent.
+	Extra      []Record        `json:"extra,omitempty"`
+	Duplicated []DuplicatedRow `json:"duplicated,omitempty"`
+	// Events that were stored with some of their fields changed.
+	Mismatched []MismatchedRow `json:"mismatched,omitempty"`
+}
+
+func (diff RowDiff) Empty() bool {
+	return len(diff.Missing) == 0 && len(diff.Extra) == 0 && len(diff.Duplicated) == 0 && len(diff.Mismatched) == 0
+}
+
+func (diff RowDiff) String() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%d sent, %d stored: %d matched, %d missing, %d extra, %d duplicated, %d mismatched",
+		diff.Sent, diff.Stored, diff.Matched, len(diff.Missing), len(diff.Extra), len(diff.Duplicated), len(diff.Mismatched))
+
+	for i, row := range diff.Mismatched {
+		if i == diffExamples {
+			fmt.Fprintf(&out, "\n  ... %d more mismatched", len(diff.Mismatched)-i)
+			break
+		}
+		fields := make([]string, len(row.Fields))
+		for j, field := range row.Fields {
+			fields[j] = field.String()
+		}
+		fmt.Fprintf(&out, "\n  mismatched: %s", strings.Join(fields, "; "))
+	}
+	writeRows := func(kind string, rows []Record) {
+		for i, row := range rows {
+			if i == diffExamples {
+				fmt.Fprintf(&out, "\n  ... %d more %s", len(rows)-i, kind)
+				break
+			}
+			data, _ := json.Marshal(row)
+			fmt.Fprintf(&out, "\n  %s: %s", kind, data)
+		}
+	}
+	writeRows("missing", diff.Missing)
+	writeRows("extra", diff.Extra)
+	for i, row := range diff.Duplicated {
+		if i == diffExamples {
+			fmt.Fprintf(&out, "\n  ... %d more duplicated", len(diff.Duplicated)-i)
+			break
+		}
+		data, _ := json.Marshal(row.Record)
+		fmt.Fprintf(&out, "\n  duplicated (%d extra copies): %s", row.Copies, data)
+	}
+	return out.String()
+}
+
+// Hash of the values of `record`, coerced to the types of `schema`, so that a
+// sent event and its stored row hash the same. Columns added by the server and
+// null values are left out.
+func recordHash(schema StreamSchema, record Record) string {
+	canonical := make(map[string]interface{}, len(record))
+	for name, value := range record {
+		if serverColumns[name] || value == nil {
+			continue
+		}
+		field, ok := schema.Field(name)
+		if !ok {
+			// Can't be stored, so it mustn't match anything that was.
+			canonical[name] = fmt.Sprintf("unknown:%v", value)
+			continue
+		}
+		coerced, err := coerceValue(field, value)
+		if err != nil {
+			canonical[name] = fmt.Sprintf("invalid:%v", value)
+			continue
+		}
+		if ts, ok := coerced.(time.Time); ok {
+			coerced = ts.Truncate(timestampUnit(field)).Format(time.RFC3339Nano)
+		}
+		canonical[name] = coerced
+	}
+	// Map keys are sorted by json.Marshal.
+	data, _ := json.Marshal(canonical)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Compares the events that were sent with the rows that were read back,
+// regardless of order. Rows are matched by content; what is left over on
+// either side is paired up by similarity to report per-field mismatches.
+func diffRecords(schema StreamSchema, sent []Record, stored []Record) RowDiff {
+	diff := RowDiff{Sent: len(sent), Stored: len(stored)}
+
+	storedByHash := make(map[string][]Record)
+	for _, row := range stored {
+		hash := recordHash(schema, row)
+		storedByHash[hash] = append(storedByHash[hash], row)
+	}
+
+	sentCount := make(map[string]int)
+	sentOrder := make([]string, 0)
+	sentRecord := make(map[string]Record)
+	for _, event := range sent {
+		hash := recordHash(schema, event)
+		if sentCount[hash] == 0 {
+			sentOrder = append(sentOrder, hash)
+			sentRecord[hash] = event
+		}
+		sentCount[hash]++
+	}
+
+	unmatchedSent := make([]Record, 0)
+	for _, hash := range sentOrder {
+		want, got := sentCount[hash], len(storedByHash[hash])
+		switch {
+		case got >= want:
+			diff.Matched += want
+			if got > want {
+				diff.Duplicated = append(diff.Duplicated, DuplicatedRow{Record: sentRecord[hash], Copies: got - want})
+			}
+		default:
+			diff.Matched += got
+			for i := got; i < want; i++ {
+				unmatchedSent = append(unmatchedSent, sentRecord[hash])
+			}
+		}
+		delete(storedByHash, hash)
+	}
+
+	unmatchedStored := make([]Record, 0)
+	for _, row := range stored {
+		hash := recordHash(schema, row)
+		if rows, ok := storedByHash[hash]; ok {
+			unmatchedStored = append(unmatchedStored, rows...)
+			delete(storedByHash, hash)
+		}
+	}
+
+	// Pair each event that wasn't found with the most similar row left over,
+	// as long as most of their fields agree.
+	used := make([]bool, len(unmatchedStored))
+	for _, event := range unmatchedSent {
+		best, bestFields := -1, []FieldMismatch(nil)
+		for i, row := range unmatchedStored {
+			if used[i] {
+				continue
+			}
+			fields := compareRecord(schema, event, row)
+			if best == -1 || len(fields) < len(bestFields) {
+				best, bestFields = i, fields
+			}
+		}
+		if best != -1 && 2*len(bestFields) <= len(event) {
+			used[best] = true
+			diff.Mismatched = append(diff.Mismatched, MismatchedRow{Expected: event, Actual: unmatchedStored[best], Fields: bestFields})
+			continue
+		}
+		diff.Missing = append(diff.Missing, event)
+	}
+	for i, row := range unmatchedStored {
+		if !used[i] {
+			diff.Extra = append(diff.Extra, row)
+		}
+	}
+	return diff
+}
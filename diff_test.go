@@ -0,0 +1,95 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffRecords(t *testing.T) {
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(FlogJsonSchema), &schema))
+
+	sent, err := decodeRecords([]byte(`[
+		{"host": "a", "method": "GET", "status": 200, "bytes": 10},
+		{"host": "b", "method": "PUT", "status": 201, "bytes": 20},
+		{"host": "c", "method": "POST", "status": 500, "bytes": 30},
+		{"host": "d", "method": "DELETE", "status": 404, "bytes": 40}
+	]`))
+	require.NoError(t, err)
+	stored := func(host string, method string, status int64, bytes int64) Record {
+		return Record{"p_timestamp": int64(1700000000000), "host": host, "method": method, "status": status, "bytes": bytes}
+	}
+
+	t.Run("reordered", func(t *testing.T) {
+		diff := diffRecords(schema, sent, []Record{
+			stored("c", "POST", 500, 30),
+			stored("a", "GET", 200, 10),
+			stored("d", "DELETE", 404, 40),
+			stored("b", "PUT", 201, 20),
+		})
+		require.True(t, diff.Empty(), diff.String())
+		require.Equal(t, 4, diff.Matched)
+	})
+
+	t.Run("fewer rows", func(t *testing.T) {
+		diff := diffRecords(schema, sent, []Record{
+			stored("a", "GET", 200, 10),
+		})
+		require.False(t, diff.Empty())
+		require.Equal(t, 1, diff.Matched)
+		require.Len(t, diff.Missing, 3)
+		require.Empty(t, diff.Extra)
+	})
+
+	t.Run("duplicated and extra", func(t *testing.T) {
+		diff := diffRecords(schema, sent, []Record{
+			stored("a", "GET", 200, 10),
+			stored("a", "GET", 200, 10),
+			stored("b", "PUT", 201, 20),
+			stored("c", "POST", 500, 30),
+			stored("d", "DELETE", 404, 40),
+			stored("z", "HEAD", 100, 0),
+		})
+		require.Equal(t, 4, diff.Matched)
+		require.Len(t, diff.Duplicated, 1)
+		require.Equal(t, 1, diff.Duplicated[0].Copies)
+		require.Equal(t, "a", diff.Duplicated[0].Record["host"])
+		require.Len(t, diff.Extra, 1)
+		require.Equal(t, "z", diff.Extra[0]["host"])
+		require.Empty(t, diff.Missing)
+	})
+
+	t.Run("mismatched field", func(t *testing.T) {
+		diff := diffRecords(schema, sent, []Record{
+			stored("d", "DELETE", 404, 40),
+			stored("c", "POST", 500, 30),
+			stored("b", "PUT", 201, 20),
+			stored("a", "GET", 200, 11),
+		})
+		require.Equal(t, 3, diff.Matched)
+		require.Empty(t, diff.Missing)
+		require.Empty(t, diff.Extra)
+		require.Len(t, diff.Mismatched, 1)
+		require.Equal(t, []FieldMismatch{{Field: "bytes", Expected: int64(10), Actual: int64(11)}}, diff.Mismatched[0].Fields)
+		require.Contains(t, diff.String(), "1 mismatched")
+		require.Contains(t, diff.String(), "bytes: expected 10, actual 11")
+	})
+}
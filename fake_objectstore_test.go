@@ -0,0 +1,93 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// In-memory object store, standing in for MinIO in unit tests.
+type FakeObjectStore struct {
+	Bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func NewFakeObjectStore() *FakeObjectStore {
+	return &FakeObjectStore{Bucket: "parseable", objects: map[string][]byte{}}
+}
+
+func (store *FakeObjectStore) Put(key string, data []byte) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.objects[key] = append([]byte(nil), data...)
+}
+
+func (store *FakeObjectStore) Delete(key string) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.objects, key)
+}
+
+func (store *FakeObjectStore) List(prefix string) ([]StoredObject, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var objects []StoredObject
+	for key, data := range store.objects {
+		if strings.HasPrefix(key, prefix) {
+			objects = append(objects, StoredObject{key, int64(len(data))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+type fakeObjectReader struct {
+	*bytes.Reader
+}
+
+func (fakeObjectReader) Close() error {
+	return nil
+}
+
+func (store *FakeObjectStore) Get(key string) (ObjectReader, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	data, ok := store.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, fs.ErrNotExist)
+	}
+	return fakeObjectReader{bytes.NewReader(data)}, nil
+}
+
+func (store *FakeObjectStore) Key(ref string) string {
+	return metadataObjectKey(store.Bucket, ref)
+}
+
+func (store *FakeObjectStore) Check() error {
+	return nil
+}
+
+func (store *FakeObjectStore) String() string {
+	return "fake bucket " + store.Bucket
+}
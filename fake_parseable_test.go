@@ -0,0 +1,849 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	fakeAdminUser = "admin"
+	fakeAdminPass = "admin"
+)
+
+// A fault the fake server applies to matching requests, so the helpers can be
+// tested against a misbehaving server.
+type FakeFault struct {
+	// Matches any method when empty.
+	Method string
+	// Prefix of the path under `api/v1/`; matches any path when empty.
+	Path string
+	// Wait this long before handling the request.
+	Delay time.Duration
+	// Respond with this status and `Body` instead of handling the request.
+	Status int
+	Body   string
+	// Close the connection without responding.
+	Drop bool
+	// Number of matching requests to affect; every one of them when 0.
+	Times int
+
+	hits int
+}
+
+type fakeStream struct {
+	created       time.Time
+	events        []Record
+	times         []time.Time
+	fields        map[string]string
+	static        bool
+	timePartition string
+	// Comma separated, as the header has it.
+	customPartition string
+	limit           time.Duration
+	alert           json.RawMessage
+	retention       json.RawMessage
+}
+
+type fakeUser struct {
+	password string
+	roles    []string
+}
+
+type fakePrivilege struct {
+	Privilege string `json:"privilege"`
+	Resource  *struct {
+		Stream string  `json:"stream"`
+		Tag    *string `json:"tag"`
+	} `json:"resource"`
+}
+
+// In-process stand-in for a Parseable server. It implements the endpoints the
+// helpers in test_utils.go use, keeps everything in memory and answers count
+// queries; any other query returns the raw events of the stream.
+type FakeParseable struct {
+	Server *httptest.Server
+
+	mu          sync.Mutex
+	streams     map[string]*fakeStream
+	roles       map[string]json.RawMessage
+	users       map[string]*fakeUser
+	defaultRole json.RawMessage
+	faults      []*FakeFault
+}
+
+func NewFakeParseable(t *testing.T) *FakeParseable {
+	fake := &FakeParseable{
+		streams: make(map[string]*fakeStream),
+		roles:   make(map[string]json.RawMessage),
+		users:   make(map[string]*fakeUser),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.Server.Close)
+	return fake
+}
+
+// Client authenticated as the admin user.
+func (fake *FakeParseable) Client() HTTPClient {
+	u, _ := url.Parse(fake.Server.URL)
+	return DefaultClient(*u, fakeAdminUser, fakeAdminPass)
+}
+
+func (fake *FakeParseable) AddFault(fault FakeFault) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.faults = append(fake.faults, &fault)
+}
+
+func (fake *FakeParseable) ClearFaults() {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.faults = nil
+}
+
+// Makes `stream` look as if it was created at `created`.
+func (fake *FakeParseable) Backdate(stream string, created time.Time) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.streams[stream].created = created
+}
+
+// Events ingested into `stream` so far.
+func (fake *FakeParseable) Events(stream string) []Record {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if s, ok := fake.streams[stream]; ok {
+		return append([]Record(nil), s.events...)
+	}
+	return nil
+}
+
+func (fake *FakeParseable) matchFault(method string, path string) *FakeFault {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, fault := range fake.faults {
+		if fault.Method != "" && fault.Method != method {
+			continue
+		}
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 && fault.hits >= fault.Times {
+			continue
+		}
+		fault.hits++
+		return fault
+	}
+	return nil
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func fakeJSON(status int, value interface{}) fakeResponse {
+	data, _ := json.Marshal(value)
+	return fakeResponse{status, string(data)}
+}
+
+func fakeError(status int, format string, args ...interface{}) fakeResponse {
+	return fakeResponse{status, fmt.Sprintf(format, args...)}
+}
+
+var fakeOK = fakeResponse{http.StatusOK, ""}
+
+func (fake *FakeParseable) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	if fault := fake.matchFault(r.Method, path); fault != nil {
+		time.Sleep(fault.Delay)
+		if fault.Drop {
+			if hijacker, ok := w.(http.Hijacker); ok {
+				conn, _, err := hijacker.Hijack()
+				if err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		}
+		if fault.Status != 0 {
+			w.WriteHeader(fault.Status)
+			io.WriteString(w, fault.Body)
+			return
+		}
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	fake.mu.Lock()
+	response := fake.handle(r, strings.Split(strings.Trim(path, "/"), "/"), body)
+	fake.mu.Unlock()
+
+	w.WriteHeader(response.status)
+	io.WriteString(w, response.body)
+}
+
+func (fake *FakeParseable) handle(r *http.Request, route []string, body []byte) fakeResponse {
+	method := r.Method
+	if len(route) == 1 && (route[0] == "liveness" || route[0] == "readiness") {
+		return fakeOK
+	}
+
+	username, password, _ := r.BasicAuth()
+	privileges, ok := fake.authenticate(username, password)
+	if !ok {
+		return fakeError(http.StatusUnauthorized, "invalid credentials")
+	}
+	allow := func(action string, stream string) bool {
+		return privileges == nil || fakeAllowed(privileges, action, stream)
+	}
+	readTags := func(stream string) []string {
+		return fakeReadTags(privileges, stream)
+	}
+	forbidden := fakeError(http.StatusForbidden, "Forbidden")
+
+	switch route[0] {
+	case "about":
+		return fakeJSON(http.StatusOK, map[string]string{"version": "fake", "mode": "Standalone"})
+
+	case "ingest":
+		if method != http.MethodPost {
+			break
+		}
+		stream := r.Header.Get("X-P-Stream")
+		if stream == "" {
+			return fakeError(http.StatusBadRequest, "X-P-Stream header is missing")
+		}
+		if !allow("ingest", stream) {
+			return forbidden
+		}
+		if _, ok := fake.streams[stream]; !ok {
+			fake.streams[stream] = &fakeStream{fields: map[string]string{}, limit: 30 * 24 * time.Hour}
+		}
+		return fake.ingest(r, fake.streams[stream], body)
+
+	case "logstream":
+		if len(route) == 1 {
+			if method != http.MethodGet {
+				break
+			}
+			if !allow("list", "") {
+				return forbidden
+			}
+			names := make([]map[string]string, 0, len(fake.streams))
+			for _, name := range fake.sortedStreams() {
+				names = append(names, map[string]string{"name": name})
+			}
+			return fakeJSON(http.StatusOK, names)
+		}
+		return fake.handleStream(r, route[1:], body, allow, forbidden)
+
+	case "query":
+		if method != http.MethodPost {
+			break
+		}
+		return fake.query(body, allow, readTags, forbidden)
+
+	case "role":
+		if !allow("access", "") {
+			return forbidden
+		}
+		return fake.handleRole(method, route[1:], body)
+
+	case "user":
+		if !allow("access", "") {
+			return forbidden
+		}
+		return fake.handleUser(method, route[1:], body)
+	}
+	return fakeError(http.StatusNotFound, "no route for %s %s", method, strings.Join(route, "/"))
+}
+
+// Returns the privileges of the user, or nil for the admin.
+func (fake *FakeParseable) authenticate(username string, password string) ([]fakePrivilege, bool) {
+	if username == fakeAdminUser && password == fakeAdminPass {
+		return nil, true
+	}
+	user, ok := fake.users[username]
+	if !ok || user.password != password {
+		return nil, false
+	}
+	privileges := make([]fakePrivilege, 0)
+	for _, role := range user.roles {
+		var rolePrivileges []fakePrivilege
+		json.Unmarshal(fake.roles[role], &rolePrivileges)
+		privileges = append(privileges, rolePrivileges...)
+	}
+	return privileges, true
+}
+
+// Actions each privilege grants. Only the admin can delete streams and manage
+// users and roles.
+var fakePrivilegeActions = map[string][]string{
+	"admin":    {"list", "create", "ingest", "read", "configure", "delete", "access"},
+	"editor":   {"list", "create", "ingest", "read", "configure"},
+	"writer":   {"list", "ingest", "read", "configure"},
+	"reader":   {"list", "read"},
+	"ingestor": {"ingest"},
+}
+
+func fakeAllowed(privileges []fakePrivilege, action string, stream string) bool {
+	for _, privilege := range privileges {
+		granted := false
+		for _, a := range fakePrivilegeActions[privilege.Privilege] {
+			granted = granted || a == action
+		}
+		if !granted {
+			continue
+		}
+		if privilege.Resource == nil || stream == "" || privilege.Resource.Stream == stream || privilege.Resource.Stream == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags the reads of `stream` are limited to, nil if they aren't.
+func fakeReadTags(privileges []fakePrivilege, stream string) []string {
+	var tags []string
+	for _, privilege := range privileges {
+		if !fakeAllowed([]fakePrivilege{privilege}, "read", stream) {
+			continue
+		}
+		if privilege.Resource == nil || privilege.Resource.Tag == nil {
+			return nil
+		}
+		tags = append(tags, *privilege.Resource.Tag)
+	}
+	return tags
+}
+
+// Whether `event` has one of `tags`, or `tags` is nil.
+func fakeHasTag(event Record, tags []string) bool {
+	if tags == nil {
+		return true
+	}
+	eventTags, _ := event["p_tags"].(string)
+	for _, tag := range tags {
+		if strings.Contains(strings.ToLower(eventTags), strings.ToLower(tag)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fake *FakeParseable) sortedStreams() []string {
+	names := make([]string, 0, len(fake.streams))
+	for name := range fake.streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (fake *FakeParseable) handleStream(r *http.Request, route []string, body []byte, allow func(string, string) bool, forbidden fakeResponse) fakeResponse {
+	name := route[0]
+	stream, exists := fake.streams[name]
+	notFound := fakeError(http.StatusNotFound, "Stream %s not found", name)
+
+	if len(route) == 1 {
+		switch r.Method {
+		case http.MethodPut:
+			if !allow("create", name) {
+				return forbidden
+			}
+			if exists {
+				return fakeError(http.StatusBadRequest, "Logstream %s already exists", name)
+			}
+			created, err := newFakeStream(r.Header, body)
+			if err != nil {
+				return fakeError(http.StatusBadRequest, "%s", err)
+			}
+			fake.streams[name] = created
+			return fakeOK
+		case http.MethodDelete:
+			if !allow("delete", name) {
+				return forbidden
+			}
+			if !exists {
+				return notFound
+			}
+			delete(fake.streams, name)
+			return fakeOK
+		case http.MethodPost:
+			if !allow("ingest", name) {
+				return forbidden
+			}
+			if !exists {
+				return notFound
+			}
+			return fake.ingest(r, stream, body)
+		}
+		return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	if !exists {
+		return notFound
+	}
+
+	var setting *json.RawMessage
+	switch route[1] {
+	case "schema":
+		if !allow("read", name) {
+			return forbidden
+		}
+		return fakeJSON(http.StatusOK, stream.schema())
+	case "stats":
+		if !allow("read", name) {
+			return forbidden
+		}
+		return fakeJSON(http.StatusOK, map[string]interface{}{
+			"stream":    name,
+			"time":      time.Now().UTC().Format(time.RFC3339Nano),
+			"ingestion": map[string]interface{}{"count": len(stream.events), "format": "json"},
+		})
+	case "info":
+		if !allow("read", name) {
+			return forbidden
+		}
+		info := map[string]interface{}{
+			"created-at":       stream.created.UTC().Format(time.RFC3339Nano),
+			"first-event-at":   nil,
+			"time_partition":   stream.timePartition,
+			"custom_partition": stream.customPartition,
+		}
+		if len(stream.times) > 0 {
+			info["first-event-at"] = stream.times[0].UTC().Format(time.RFC3339Nano)
+		}
+		return fakeJSON(http.StatusOK, info)
+	case "alert":
+		setting = &stream.alert
+	case "retention":
+		setting = &stream.retention
+	default:
+		return fakeError(http.StatusNotFound, "no route for %s", strings.Join(route, "/"))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if !allow("read", name) {
+			return forbidden
+		}
+		if *setting == nil {
+			return fakeError(http.StatusBadRequest, "%s not set for stream %s", route[1], name)
+		}
+		return fakeResponse{http.StatusOK, string(*setting)}
+	case http.MethodPut:
+		if !allow("configure", name) {
+			return forbidden
+		}
+		if !json.Valid(body) {
+			return fakeError(http.StatusBadRequest, "invalid %s config", route[1])
+		}
+		*setting = append(json.RawMessage(nil), body...)
+		return fakeOK
+	}
+	return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// Arrow type names for the types of a static schema.
+var fakeStaticTypes = map[string]string{
+	"string":   "Utf8",
+	"int":      "Int64",
+	"float":    "Float64",
+	"boolean":  "Boolean",
+	"datetime": "Timestamp",
+}
+
+func newFakeStream(header http.Header, body []byte) (*fakeStream, error) {
+	stream := &fakeStream{
+		created:         time.Now(),
+		fields:          map[string]string{},
+		timePartition:   header.Get("X-P-Time-Partition"),
+		customPartition: header.Get("X-P-Custom-Partition"),
+		limit:           30 * 24 * time.Hour,
+	}
+	if limit := header.Get("X-P-Time-Partition-Limit"); limit != "" {
+		days, err := strconv.Atoi(strings.TrimSuffix(limit, "d"))
+		if err != nil || !strings.HasSuffix(limit, "d") || days <= 0 {
+			return nil, fmt.Errorf("invalid time partition limit %q", limit)
+		}
+		stream.limit = time.Duration(days) * 24 * time.Hour
+	}
+
+	if header.Get("X-P-Static-Schema-Flag") == "true" {
+		var schema struct {
+			Fields []struct {
+				Name     string `json:"name"`
+				DataType string `json:"data_type"`
+			} `json:"fields"`
+		}
+		if err := json.Unmarshal(body, &schema); err != nil || len(schema.Fields) == 0 {
+			return nil, fmt.Errorf("static schema flag is set but the body has no schema")
+		}
+		for _, field := range schema.Fields {
+			typ, ok := fakeStaticTypes[field.DataType]
+			if !ok {
+				return nil, fmt.Errorf("unsupported data type %s for field %s", field.DataType, field.Name)
+			}
+			stream.fields[field.Name] = typ
+		}
+		stream.static = true
+	}
+	return stream, nil
+}
+
+func fakeValueType(value interface{}) string {
+	switch v := value.(type) {
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "Float64"
+		}
+		return "Int64"
+	case bool:
+		return "Boolean"
+	}
+	return "Utf8"
+}
+
+func (fake *FakeParseable) ingest(r *http.Request, stream *fakeStream, body []byte) fakeResponse {
+	events, err := decodeRecords(body)
+	if err != nil {
+		// A single event may be sent as an object.
+		events, err = decodeRecords([]byte("[" + string(body) + "]"))
+	}
+	if err != nil {
+		return fakeError(http.StatusBadRequest, "invalid JSON: %s", err)
+	}
+
+	now := time.Now()
+	times := make([]time.Time, len(events))
+	for i, event := range events {
+		times[i] = now
+		if stream.timePartition != "" {
+			value, ok := event[stream.timePartition].(string)
+			if !ok {
+				return fakeError(http.StatusBadRequest, "time partition field %s is missing from the event", stream.timePartition)
+			}
+			ts, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				ts, err = time.Parse("2006-01-02T15:04:05.000", value)
+			}
+			if err != nil {
+				return fakeError(http.StatusBadRequest, "time partition field %s has an invalid datetime %q", stream.timePartition, value)
+			}
+			if ts.Before(now.Add(-stream.limit).Truncate(24 * time.Hour)) {
+				return fakeError(http.StatusBadRequest, "time partition field %s is outside the time partition limit", stream.timePartition)
+			}
+			times[i] = ts
+		}
+		if stream.static {
+			for name := range event {
+				if _, ok := stream.fields[name]; !ok {
+					return fakeError(http.StatusBadRequest, "field %s is not in the static schema", name)
+				}
+			}
+		}
+	}
+
+	metadata := make([]string, 0)
+	tags := make([]string, 0)
+	for key := range r.Header {
+		upper := strings.ToUpper(key)
+		switch {
+		case strings.HasPrefix(upper, "X-P-META-"):
+			metadata = append(metadata, key[len("X-P-META-"):]+"="+r.Header.Get(key))
+		case strings.HasPrefix(upper, "X-P-TAG-"):
+			tags = append(tags, key[len("X-P-TAG-"):]+"="+r.Header.Get(key))
+		}
+	}
+	sort.Strings(metadata)
+	sort.Strings(tags)
+
+	for i, event := range events {
+		for name, value := range event {
+			if _, ok := stream.fields[name]; !ok {
+				stream.fields[name] = fakeValueType(value)
+			}
+		}
+		event["p_timestamp"] = now.UTC().Format(time.RFC3339Nano)
+		event["p_metadata"] = strings.Join(metadata, "^")
+		event["p_tags"] = strings.Join(tags, "^")
+		stream.events = append(stream.events, event)
+		stream.times = append(stream.times, times[i])
+	}
+	return fakeOK
+}
+
+// Schema in the shape Parseable returns it: Arrow fields sorted by name,
+// including the columns the server adds.
+type fakeArrowField struct {
+	Name          string            `json:"name"`
+	DataType      json.RawMessage   `json:"data_type"`
+	Nullable      bool              `json:"nullable"`
+	DictId        int               `json:"dict_id"`
+	DictIsOrdered bool              `json:"dict_is_ordered"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+func (stream *fakeStream) schema() map[string]interface{} {
+	types := map[string]string{
+		"p_metadata":  "Utf8",
+		"p_tags":      "Utf8",
+		"p_timestamp": "Timestamp",
+	}
+	for name, typ := range stream.fields {
+		types[name] = typ
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := make([]fakeArrowField, 0, len(names))
+	for _, name := range names {
+		dataType := json.RawMessage(strconv.Quote(types[name]))
+		if types[name] == "Timestamp" {
+			dataType = json.RawMessage(`{"Timestamp":["Millisecond",null]}`)
+		}
+		fields = append(fields, fakeArrowField{Name: name, DataType: dataType, Nullable: true, Metadata: map[string]string{}})
+	}
+	return map[string]interface{}{
+		"fields":   fields,
+		"metadata": map[string]string{},
+	}
+}
+
+var (
+	fakeFromPattern  = regexp.MustCompile(`(?i)\bfrom\s+"?([A-Za-z0-9_\-]+)"?`)
+	fakeCountPattern = regexp.MustCompile(`(?i)count\(\*\)`)
+)
+
+func (fake *FakeParseable) query(body []byte, allow func(string, string) bool, readTags func(string) []string, forbidden fakeResponse) fakeResponse {
+	var request struct {
+		Query     string `json:"query"`
+		StartTime string `json:"startTime"`
+		EndTime   string `json:"endTime"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return fakeError(http.StatusBadRequest, "invalid query request: %s", err)
+	}
+	start, err := time.Parse(time.RFC3339Nano, request.StartTime)
+	if err != nil {
+		return fakeError(http.StatusBadRequest, "invalid startTime %q", request.StartTime)
+	}
+	end, err := time.Parse(time.RFC3339Nano, request.EndTime)
+	if err != nil {
+		return fakeError(http.StatusBadRequest, "invalid endTime %q", request.EndTime)
+	}
+
+	matches := fakeFromPattern.FindAllStringSubmatch(request.Query, -1)
+	if len(matches) == 0 {
+		return fakeError(http.StatusBadRequest, "no stream in query %q", request.Query)
+	}
+
+	count := 0
+	rows := make([]Record, 0)
+	for _, match := range matches {
+		stream, ok := fake.streams[match[1]]
+		if !ok {
+			return fakeError(http.StatusBadRequest, "stream %s not found", match[1])
+		}
+		if !allow("read", match[1]) {
+			return forbidden
+		}
+		tags := readTags(match[1])
+		for i, ts := range stream.times {
+			if ts.Before(start) || !ts.Before(end) || !fakeHasTag(stream.events[i], tags) {
+				continue
+			}
+			count++
+			rows = append(rows, stream.events[i])
+		}
+	}
+
+	if fakeCountPattern.MatchString(request.Query) {
+		return fakeResponse{http.StatusOK, fmt.Sprintf(`[{"count":%d}]`, count)}
+	}
+	return fakeJSON(http.StatusOK, rows)
+}
+
+func (fake *FakeParseable) handleRole(method string, route []string, body []byte) fakeResponse {
+	if len(route) == 0 {
+		if method != http.MethodGet {
+			return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return fakeJSON(http.StatusOK, fake.roles)
+	}
+
+	name := route[0]
+	if name == "default" {
+		switch method {
+		case http.MethodPut:
+			var role string
+			if err := json.Unmarshal(body, &role); err != nil {
+				return fakeError(http.StatusBadRequest, "invalid role name")
+			}
+			if _, ok := fake.roles[role]; !ok {
+				return fakeError(http.StatusBadRequest, "role %s does not exist", role)
+			}
+			fake.defaultRole = append(json.RawMessage(nil), body...)
+			return fakeOK
+		case http.MethodGet:
+			if fake.defaultRole == nil {
+				return fakeResponse{http.StatusOK, "null"}
+			}
+			return fakeResponse{http.StatusOK, string(fake.defaultRole)}
+		}
+		return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	role, exists := fake.roles[name]
+	switch method {
+	case http.MethodPut:
+		var privileges []fakePrivilege
+		if err := json.Unmarshal(body, &privileges); err != nil {
+			return fakeError(http.StatusBadRequest, "invalid role: %s", err)
+		}
+		fake.roles[name] = append(json.RawMessage(nil), body...)
+		return fakeOK
+	case http.MethodGet:
+		if !exists {
+			return fakeError(http.StatusBadRequest, "role %s does not exist", name)
+		}
+		return fakeResponse{http.StatusOK, string(role)}
+	case http.MethodDelete:
+		if !exists {
+			return fakeError(http.StatusBadRequest, "role %s does not exist", name)
+		}
+		for _, user := range fake.users {
+			for _, r := range user.roles {
+				if r == name {
+					return fakeError(http.StatusBadRequest, "role %s is in use", name)
+				}
+			}
+		}
+		delete(fake.roles, name)
+		return fakeOK
+	}
+	return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func fakePassword() string {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	password := make([]byte, 16)
+	for i := range password {
+		password[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(password)
+}
+
+func (fake *FakeParseable) parseRoles(body []byte) ([]string, *fakeResponse) {
+	roles := make([]string, 0)
+	if len(body) == 0 {
+		return roles, nil
+	}
+	if err := json.Unmarshal(body, &roles); err != nil {
+		response := fakeError(http.StatusBadRequest, "invalid roles: %s", err)
+		return nil, &response
+	}
+	for _, role := range roles {
+		if _, ok := fake.roles[role]; !ok {
+			response := fakeError(http.StatusBadRequest, "role %s does not exist", role)
+			return nil, &response
+		}
+	}
+	return roles, nil
+}
+
+func (fake *FakeParseable) handleUser(method string, route []string, body []byte) fakeResponse {
+	if len(route) == 0 {
+		if method != http.MethodGet {
+			return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+		}
+		names := make([]string, 0, len(fake.users))
+		for name := range fake.users {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fakeJSON(http.StatusOK, names)
+	}
+
+	name := route[0]
+	user, exists := fake.users[name]
+	notFound := fakeError(http.StatusNotFound, "user %s does not exist", name)
+
+	if len(route) == 1 {
+		switch method {
+		case http.MethodPost:
+			if exists || name == fakeAdminUser {
+				return fakeError(http.StatusBadRequest, "user %s already exists", name)
+			}
+			roles, errResponse := fake.parseRoles(body)
+			if errResponse != nil {
+				return *errResponse
+			}
+			user = &fakeUser{password: fakePassword(), roles: roles}
+			fake.users[name] = user
+			return fakeResponse{http.StatusOK, user.password}
+		case http.MethodDelete:
+			if !exists {
+				return notFound
+			}
+			delete(fake.users, name)
+			return fakeOK
+		}
+		return fakeError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+
+	if !exists {
+		return notFound
+	}
+	switch {
+	case route[1] == "role" && method == http.MethodPut:
+		roles, errResponse := fake.parseRoles(body)
+		if errResponse != nil {
+			return *errResponse
+		}
+		user.roles = roles
+		return fakeOK
+	case route[1] == "role" && method == http.MethodGet:
+		roles := make(map[string]json.RawMessage, len(user.roles))
+		for _, role := range user.roles {
+			roles[role] = fake.roles[role]
+		}
+		return fakeJSON(http.StatusOK, roles)
+	case route[1] == "generate-new-password" && method == http.MethodPost:
+		user.password = fakePassword()
+		return fakeResponse{http.StatusOK, user.password}
+	}
+	return fakeError(http.StatusNotFound, "no route for user/%s", strings.Join(route, "/"))
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Prefix of the users and roles created through the fixtures below. Streams
+// are prefixed with `-stream` instead.
+const fixturePrefix = "quest"
+
+// Longest part of a name taken from its hint, which keeps user names within
+// the 64 characters Parseable allows.
+const maxNameHint = 24
+
+// Length of the suffix of `UniqueName`: the time in seconds in base 36, six
+// digits until 2038, and four random hex digits.
+const nameSuffixLen = 10
+
+// Returns `prefix`, the letters and digits of `hint` and a suffix made of the
+// time and random digits, so that tests running in parallel, or after a
+// failed run, never share names, and `cleanup` can tell how old they are.
+func UniqueName(prefix string, hint string) string {
+	var name strings.Builder
+	name.WriteString(prefix)
+	hinted := 0
+	for _, r := range strings.ToLower(hint) {
+		if hinted == maxNameHint {
+			break
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+			hinted++
+		}
+	}
+	fmt.Fprintf(&name, "%06s", strconv.FormatInt(time.Now().Unix(), 36))
+	random := make([]byte, 2)
+	rand.Read(random)
+	name.WriteString(hex.EncodeToString(random))
+	return name.String()
+}
+
+// Earliest time a `UniqueName` suffix can hold, older ones are taken for
+// names that only look like it.
+var firstNameTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// When `name` was made by `UniqueName`, if it ends in a suffix holding a time
+// between `firstNameTime` and `now`.
+func nameCreatedAt(name string, now time.Time) (time.Time, bool) {
+	if len(name) < nameSuffixLen {
+		return time.Time{}, false
+	}
+	suffix := name[len(name)-nameSuffixLen:]
+	if _, err := hex.DecodeString(suffix[6:]); err != nil {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(suffix[:6], 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	created := time.Unix(seconds, 0)
+	if created.Before(firstNameTime) || created.After(now.Add(time.Minute)) {
+		return time.Time{}, false
+	}
+	return created, true
+}
+
+func testHint(t *testing.T) string {
+	return strings.TrimPrefix(t.Name(), "Test")
+}
+
+// Whether deleting failed only because the resource is already gone, e.g.
+// because the test deleted it itself. Parseable answers 400 for missing roles.
+func alreadyDeleted(err error) bool {
+	var apiErr *parseable.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound ||
+		apiErr.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Body), "not exist")
+}
+
+func track(t *testing.T, kind string, name string, remove func(string) error) {
+	t.Cleanup(func() {
+		if err := remove(name); err != nil && !alreadyDeleted(err) {
+			t.Errorf("Couldn't delete %s %s: %s", kind, name, err)
+		}
+	})
+}
+
+// Deletes `stream` once the test and its subtests are done, whether or not
+// they passed.
+func TrackStream(t *testing.T, client HTTPClient, stream string) {
+	track(t, "stream", stream, client.API().DeleteStream)
+}
+
+// Deletes `user` once the test and its subtests are done.
+func TrackUser(t *testing.T, client HTTPClient, user string) {
+	track(t, "user", user, client.API().DeleteUser)
+}
+
+// Deletes `role` once the test and its subtests are done. Track roles before
+// the users holding them, cleanups run last in first out.
+func TrackRole(t *testing.T, client HTTPClient, role string) {
+	track(t, "role", role, client.API().DeleteRole)
+}
+
+// Creates a stream of its own for the test, named after `-stream` and the
+// test, and deletes it when the test is done.
+func NewTestStream(t *testing.T, client HTTPClient, options parseable.StreamOptions) string {
+	stream := UniqueName(NewGlob.Stream, testHint(t))
+	err := client.API().CreateStream(stream, options)
+	require.NoErrorf(t, err, "Couldn't create stream %s: %s", stream, err)
+	TrackStream(t, client, stream)
+	return stream
+}
+
+// Creates a role of its own for the test from `body` and deletes it when the
+// test is done. Returns the name of the role, which starts with `name`.
+func NewTestRole(t *testing.T, client HTTPClient, name string, body string) string {
+	role := UniqueName(fixturePrefix, name)
+	CreateRole(t, client, role, body)
+	TrackRole(t, client, role)
+	return role
+}
+
+// Creates a user of its own for the test with `roles` and deletes it when the
+// test is done. Returns the name of the user, which starts with `name`, and
+// its password.
+func NewTestUser(t *testing.T, client HTTPClient, name string, roles []string) (string, string) {
+	user := UniqueName(fixturePrefix, name)
+	password := CreateUserWithRole(t, client, user, roles)
+	TrackUser(t, client, user)
+	return user, password
+}
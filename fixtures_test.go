@@ -0,0 +1,85 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUniqueName(t *testing.T) {
+	name := UniqueName("app", "SmokeRoles/editor_user")
+	require.Regexp(t, regexp.MustCompile(`^appsmokeroleseditoruser[0-9a-z]{6}[0-9a-f]{4}$`), name)
+	require.NotEqual(t, name, UniqueName("app", "SmokeRoles/editor_user"))
+
+	long := UniqueName(fixturePrefix, strings.Repeat("x", 100))
+	require.Len(t, long, len(fixturePrefix)+maxNameHint+nameSuffixLen)
+
+	now := time.Now()
+	created, ok := nameCreatedAt(name, now)
+	require.True(t, ok)
+	require.WithinDuration(t, now, created, 2*time.Second)
+
+	for _, name := range []string{"app", "kubernetes", "appzzzzzz0000", "app000000beef", "appsmokeroles" + strconv.FormatInt(now.Add(time.Hour).Unix(), 36) + "beef"} {
+		_, ok := nameCreatedAt(name, now)
+		require.False(t, ok, name)
+	}
+}
+
+func TestFixturesCleanup(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+
+	var stream, deleted, role, user string
+	passed := t.Run("fixtures", func(t *testing.T) {
+		stream = NewTestStream(t, client, parseable.StreamOptions{})
+		deleted = NewTestStream(t, client, parseable.StreamOptions{})
+		role = NewTestRole(t, client, "reader", RoleReader(stream))
+		user, _ = NewTestUser(t, client, "reader_user", []string{role})
+
+		require.True(t, strings.HasPrefix(stream, NewGlob.Stream+"fixtures"))
+		require.True(t, strings.HasPrefix(role, fixturePrefix+"reader"))
+		require.True(t, strings.HasPrefix(user, fixturePrefix+"readeruser"))
+		DeleteStream(t, client, deleted)
+	})
+	require.True(t, passed, "Deleting a stream the test already deleted should be fine")
+
+	streams, err := client.API().ListStreams()
+	require.NoError(t, err)
+	require.Empty(t, streams)
+	users, err := client.API().ListUsers()
+	require.NoError(t, err)
+	require.Empty(t, users)
+	roles, err := client.API().ListRoles()
+	require.NoError(t, err)
+	require.Empty(t, roles)
+}
+
+func TestAlreadyDeleted(t *testing.T) {
+	require.True(t, alreadyDeleted(&parseable.APIError{StatusCode: http.StatusNotFound}))
+	require.True(t, alreadyDeleted(&parseable.APIError{StatusCode: http.StatusBadRequest, Body: "Role does not exist"}))
+	require.False(t, alreadyDeleted(&parseable.APIError{StatusCode: http.StatusBadRequest, Body: "role is in use"}))
+	require.False(t, alreadyDeleted(&parseable.APIError{StatusCode: http.StatusInternalServerError}))
+}
@@ -0,0 +1,796 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+)
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindInt
+	kindFloat
+)
+
+// A column the fuzzer builds queries over.
+type FuzzColumn struct {
+	Name string
+	Kind columnKind
+}
+
+func (c FuzzColumn) quoted() string {
+	return `"` + strings.ReplaceAll(c.Name, `"`, `""`) + `"`
+}
+
+// Value of the column in `event`, converted to what the query API returns
+// for it; nil when the event doesn't have it.
+func (c FuzzColumn) value(event ModelEvent) interface{} {
+	raw, ok := event.Fields[c.Name]
+	if !ok || raw == nil {
+		return nil
+	}
+	switch c.Kind {
+	case kindInt:
+		if v, err := toInt64(raw); err == nil {
+			return v
+		}
+	case kindFloat:
+		if v, err := toFloat64(raw); err == nil {
+			return v
+		}
+	default:
+		if s, ok := raw.(string); ok {
+			return s
+		}
+	}
+	return nil
+}
+
+func fieldKind(field SchemaField) (columnKind, bool) {
+	switch typ := field.Type(); {
+	case typ == "Utf8" || typ == "LargeUtf8":
+		return kindString, true
+	case strings.HasPrefix(typ, "Int") || strings.HasPrefix(typ, "UInt"):
+		return kindInt, true
+	case strings.HasPrefix(typ, "Float"):
+		return kindFloat, true
+	}
+	return 0, false
+}
+
+// The columns of `schemas` the fuzzer can use: those every schema has with
+// the same string, integer or float type, leaving out the server's columns.
+func fuzzColumns(schemas ...StreamSchema) []FuzzColumn {
+	if len(schemas) == 0 {
+		return nil
+	}
+	kinds := make(map[string]columnKind)
+	for _, field := range schemas[0].Fields {
+		if kind, ok := fieldKind(field); ok && !serverColumns[field.Name] {
+			kinds[field.Name] = kind
+		}
+	}
+	for _, schema := range schemas[1:] {
+		for name, kind := range kinds {
+			field, ok := schema.Field(name)
+			if !ok {
+				delete(kinds, name)
+			} else if other, known := fieldKind(field); !known || other != kind {
+				delete(kinds, name)
+			}
+		}
+	}
+
+	columns := make([]FuzzColumn, 0, len(kinds))
+	for name, kind := range kinds {
+		columns = append(columns, FuzzColumn{name, kind})
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns
+}
+
+type fuzzFilter struct {
+	Column FuzzColumn
+	Op     string
+	Value  interface{}
+}
+
+func sqlLiteral(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return fmt.Sprint(value)
+}
+
+func (f fuzzFilter) sql() string {
+	return fmt.Sprintf("%s %s %s", f.Column.quoted(), f.Op, sqlLiteral(f.Value))
+}
+
+func compareValues(a, b interface{}) int {
+	if as, ok := a.(string); ok {
+		return strings.Compare(as, b.(string))
+	}
+	af, _ := toFloat64(a)
+	bf, _ := toFloat64(b)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+func (f fuzzFilter) matches(event ModelEvent) bool {
+	v := f.Column.value(event)
+	if v == nil {
+		return false
+	}
+	c := compareValues(v, f.Value)
+	switch f.Op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type fuzzAggregate struct {
+	Func string
+	// nil for `COUNT(*)`.
+	Column *FuzzColumn
+}
+
+func (a fuzzAggregate) sql() string {
+	if a.Column == nil {
+		return a.Func + "(*)"
+	}
+	return fmt.Sprintf("%s(%s)", a.Func, a.Column.quoted())
+}
+
+func (a fuzzAggregate) compute(events []ModelEvent) interface{} {
+	if a.Column == nil {
+		return int64(len(events))
+	}
+	var result interface{}
+	var count int64
+	for _, e := range events {
+		v := a.Column.value(e)
+		if v == nil {
+			continue
+		}
+		count++
+		switch {
+		case result == nil && a.Func != "COUNT":
+			result = v
+		case a.Func == "MIN" && compareValues(v, result) < 0, a.Func == "MAX" && compareValues(v, result) > 0:
+			result = v
+		case a.Func == "SUM":
+			if a.Column.Kind == kindInt {
+				result = result.(int64) + v.(int64)
+			} else {
+				result = result.(float64) + v.(float64)
+			}
+		}
+	}
+	if a.Func == "COUNT" {
+		return count
+	}
+	return result
+}
+
+// A generated query. Its rows are ordered by every output column when
+// `OrderBy` is set, and only then can it have a `Limit` or an `Offset`.
+type FuzzQuery struct {
+	// One stream, or two whose rows are combined with UNION ALL.
+	Streams []string
+	// Projected when there are no aggregates.
+	Columns    []FuzzColumn
+	Aggregates []fuzzAggregate
+	GroupBy    *FuzzColumn
+	Filters    []fuzzFilter
+	OrderBy    bool
+	Descending bool
+	Limit      int
+	Offset     int
+	Start, End time.Time
+}
+
+func (q FuzzQuery) outputColumns() []string {
+	var names []string
+	if len(q.Aggregates) == 0 {
+		for _, c := range q.Columns {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+	if q.GroupBy != nil {
+		names = append(names, q.GroupBy.Name)
+	}
+	for i := range q.Aggregates {
+		names = append(names, fmt.Sprintf("a%d", i))
+	}
+	return names
+}
+
+// Columns the query reads, which the UNION ALL of its streams must have.
+func (q FuzzQuery) readColumns() []FuzzColumn {
+	seen := make(map[string]bool)
+	var columns []FuzzColumn
+	add := func(c FuzzColumn) {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			columns = append(columns, c)
+		}
+	}
+	for _, c := range q.Columns {
+		add(c)
+	}
+	if q.GroupBy != nil {
+		add(*q.GroupBy)
+	}
+	for _, a := range q.Aggregates {
+		if a.Column != nil {
+			add(*a.Column)
+		}
+	}
+	for _, f := range q.Filters {
+		add(f.Column)
+	}
+	return columns
+}
+
+func (q FuzzQuery) SQL() string {
+	var selects []string
+	if len(q.Aggregates) == 0 {
+		for _, c := range q.Columns {
+			selects = append(selects, c.quoted())
+		}
+	} else {
+		if q.GroupBy != nil {
+			selects = append(selects, q.GroupBy.quoted())
+		}
+		for i, a := range q.Aggregates {
+			selects = append(selects, fmt.Sprintf("%s AS a%d", a.sql(), i))
+		}
+	}
+
+	from := q.Streams[0]
+	if len(q.Streams) > 1 {
+		columns := q.readColumns()
+		names := make([]string, 0, len(columns))
+		for _, c := range columns {
+			names = append(names, c.quoted())
+		}
+		list := strings.Join(names, ", ")
+		if list == "" {
+			list = "p_timestamp"
+		}
+		parts := make([]string, 0, len(q.Streams))
+		for _, stream := range q.Streams {
+			parts = append(parts, fmt.Sprintf("SELECT %s FROM %s", list, stream))
+		}
+		from = "(" + strings.Join(parts, " UNION ALL ") + ") AS u"
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s", strings.Join(selects, ", "), from)
+	if len(q.Filters) > 0 {
+		conditions := make([]string, 0, len(q.Filters))
+		for _, f := range q.Filters {
+			conditions = append(conditions, f.sql())
+		}
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if q.GroupBy != nil && len(q.Aggregates) > 0 {
+		sql += " GROUP BY " + q.GroupBy.quoted()
+	}
+	if q.OrderBy {
+		direction := ""
+		if q.Descending {
+			direction = " DESC"
+		}
+		keys := make([]string, 0)
+		for _, name := range q.outputColumns() {
+			keys = append(keys, FuzzColumn{Name: name}.quoted()+direction)
+		}
+		sql += " ORDER BY " + strings.Join(keys, ", ")
+		if q.Limit > 0 {
+			sql += fmt.Sprintf(" LIMIT %d", q.Limit)
+		}
+		if q.Offset > 0 {
+			sql += fmt.Sprintf(" OFFSET %d", q.Offset)
+		}
+	}
+	return sql
+}
+
+// Compares rows by `columns`, NULLs sorting last, or first when descending.
+func compareRows(a, b Row, columns []string, descending bool) int {
+	for _, column := range columns {
+		av, bv := a[column], b[column]
+		var c int
+		switch {
+		case av == nil && bv == nil:
+			continue
+		case av == nil:
+			c = 1
+		case bv == nil:
+			c = -1
+		default:
+			c = compareValues(av, bv)
+		}
+		if descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// The rows `q` returns over `events`, and whether they come in that order.
+// False when the rows can't be predicted because an event may have been
+// stamped on either side of the time range.
+func (q FuzzQuery) Expected(events []ModelEvent) (rows []Row, ordered bool, ok bool) {
+	var selected []ModelEvent
+	for _, e := range events {
+		switch {
+		case e.Acked.Before(q.Start) || !e.Sent.Before(q.End):
+			continue
+		case e.Sent.Before(q.Start) || !e.Acked.Before(q.End):
+			return nil, false, false
+		}
+		keep := true
+		for _, f := range q.Filters {
+			keep = keep && f.matches(e)
+		}
+		if keep {
+			selected = append(selected, e)
+		}
+	}
+
+	rows = make([]Row, 0)
+	switch {
+	case len(q.Aggregates) == 0:
+		for _, e := range selected {
+			row := make(Row, len(q.Columns))
+			for _, c := range q.Columns {
+				row[c.Name] = c.value(e)
+			}
+			rows = append(rows, row)
+		}
+	case q.GroupBy == nil:
+		row := make(Row, len(q.Aggregates))
+		for i, a := range q.Aggregates {
+			row[fmt.Sprintf("a%d", i)] = a.compute(selected)
+		}
+		rows = append(rows, row)
+	default:
+		groups := make(map[interface{}][]ModelEvent)
+		var keys []interface{}
+		for _, e := range selected {
+			key := q.GroupBy.value(e)
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], e)
+		}
+		for _, key := range keys {
+			row := Row{q.GroupBy.Name: key}
+			for i, a := range q.Aggregates {
+				row[fmt.Sprintf("a%d", i)] = a.compute(groups[key])
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if !q.OrderBy {
+		return rows, false, true
+	}
+	columns := q.outputColumns()
+	sort.SliceStable(rows, func(i, j int) bool { return compareRows(rows[i], rows[j], columns, q.Descending) < 0 })
+	if q.Offset > 0 || q.Limit > 0 {
+		limit := len(rows)
+		if q.Limit > 0 {
+			limit = q.Limit
+		}
+		rows = window(rows, q.Offset, limit)
+	}
+	return rows, true, true
+}
+
+// Orders rows canonically, for comparing results that come in any order.
+func sortRows(rows []Row) []Row {
+	keyed := make([]Row, 0, len(rows))
+	keys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		normal := make(Row, len(row))
+		for column, value := range row {
+			if f, err := toFloat64(value); err == nil && value != nil {
+				if _, isString := value.(string); !isString {
+					value = f
+				}
+			}
+			normal[column] = value
+		}
+		keyed = append(keyed, normal)
+		keys = append(keys, fmt.Sprint(normal))
+	}
+	sort.Sort(rowsByKey{keyed, keys})
+	return keyed
+}
+
+type rowsByKey struct {
+	rows []Row
+	keys []string
+}
+
+func (r rowsByKey) Len() int           { return len(r.rows) }
+func (r rowsByKey) Less(i, j int) bool { return r.keys[i] < r.keys[j] }
+func (r rowsByKey) Swap(i, j int) {
+	r.rows[i], r.rows[j] = r.rows[j], r.rows[i]
+	r.keys[i], r.keys[j] = r.keys[j], r.keys[i]
+}
+
+// How far the edges of a query's time range stay from the times events were
+// sent and acked, as the server stamps events with its own clock.
+const fuzzEdgeMargin = 250 * time.Millisecond
+
+// Generates random queries over streams whose events are in a model. The
+// queries depend on the seed and the events, not on the time of the run, so
+// the same `-fuzz-seed` and `-log-seed` generate them again.
+type QueryFuzzer struct {
+	Columns []FuzzColumn
+	Streams []string
+	Model   *EventModel
+	// Time range that covers every event of the streams.
+	Start, End time.Time
+
+	r *rand.Rand
+}
+
+func NewQueryFuzzer(seed int64, columns []FuzzColumn, model *EventModel, streams ...string) *QueryFuzzer {
+	f := &QueryFuzzer{Columns: columns, Streams: streams, Model: model, r: rand.New(rand.NewSource(seed))}
+	events := f.events(streams)
+	if len(events) == 0 {
+		f.End = time.Now()
+		f.Start = f.End
+	}
+	for i, e := range events {
+		if i == 0 || e.Sent.Before(f.Start) {
+			f.Start = e.Sent
+		}
+		if i == 0 || e.Acked.After(f.End) {
+			f.End = e.Acked
+		}
+	}
+	f.Start, f.End = f.Start.Add(-time.Minute), f.End.Add(time.Minute)
+	return f
+}
+
+func (f *QueryFuzzer) events(streams []string) []ModelEvent {
+	var events []ModelEvent
+	for _, stream := range streams {
+		events = append(events, f.Model.Events(stream)...)
+	}
+	return events
+}
+
+func (f *QueryFuzzer) column(kinds ...columnKind) *FuzzColumn {
+	var candidates []FuzzColumn
+	for _, c := range f.Columns {
+		for _, k := range kinds {
+			if c.Kind == k {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	c := candidates[f.r.Intn(len(candidates))]
+	return &c
+}
+
+// A value for a filter on `column`, mostly one the events have.
+func (f *QueryFuzzer) value(column FuzzColumn, events []ModelEvent) interface{} {
+	if len(events) > 0 && f.r.Intn(5) > 0 {
+		if v := column.value(events[f.r.Intn(len(events))]); v != nil {
+			return v
+		}
+	}
+	switch column.Kind {
+	case kindInt:
+		return int64(f.r.Intn(100000))
+	case kindFloat:
+		return math.Round(f.r.Float64()*100000) / 100
+	}
+	return fmt.Sprintf("fuzz-%d", f.r.Intn(1000))
+}
+
+func (f *QueryFuzzer) Generate() FuzzQuery {
+	q := FuzzQuery{Streams: f.Streams[:1], Start: f.Start, End: f.End}
+	if len(f.Streams) > 1 && f.r.Intn(3) == 0 {
+		q.Streams = f.Streams
+	}
+	events := f.events(q.Streams)
+
+	if f.r.Intn(2) == 0 {
+		perm := f.r.Perm(len(f.Columns))
+		for _, i := range perm[:1+f.r.Intn(min(3, len(perm)))] {
+			q.Columns = append(q.Columns, f.Columns[i])
+		}
+	} else {
+		for n := 1 + f.r.Intn(3); n > 0; n-- {
+			switch fn := []string{"COUNT", "MIN", "MAX", "SUM"}[f.r.Intn(4)]; {
+			case fn == "COUNT" && f.r.Intn(2) == 0:
+				q.Aggregates = append(q.Aggregates, fuzzAggregate{Func: fn})
+			case fn == "SUM":
+				if c := f.column(kindInt, kindFloat); c != nil {
+					q.Aggregates = append(q.Aggregates, fuzzAggregate{Func: fn, Column: c})
+				}
+			default:
+				q.Aggregates = append(q.Aggregates, fuzzAggregate{Func: fn, Column: f.column(kindString, kindInt, kindFloat)})
+			}
+		}
+		if len(q.Aggregates) == 0 {
+			q.Aggregates = append(q.Aggregates, fuzzAggregate{Func: "COUNT"})
+		}
+		if f.r.Intn(2) == 0 {
+			q.GroupBy = f.column(kindString, kindInt)
+		}
+	}
+
+	for n := f.r.Intn(3); n > 0; n-- {
+		c := f.Columns[f.r.Intn(len(f.Columns))]
+		ops := []string{"=", "!="}
+		if c.Kind != kindString || f.r.Intn(2) == 0 {
+			ops = append(ops, "<", "<=", ">", ">=")
+		}
+		q.Filters = append(q.Filters, fuzzFilter{c, ops[f.r.Intn(len(ops))], f.value(c, events)})
+	}
+
+	if f.r.Intn(2) == 0 {
+		q.OrderBy = true
+		q.Descending = f.r.Intn(2) == 0
+		if f.r.Intn(2) == 0 {
+			q.Limit = 1 + f.r.Intn(20)
+		}
+		if f.r.Intn(3) == 0 {
+			q.Offset = f.r.Intn(30)
+		}
+	}
+
+	// Time range edges: start or end between two events, or before all of
+	// them.
+	switch f.r.Intn(6) {
+	case 0:
+		if edge, ok := f.edge(events); ok {
+			q.Start = edge
+		}
+	case 1:
+		if edge, ok := f.edge(events); ok {
+			q.End = edge
+		}
+	case 2:
+		q.Start, q.End = f.Start.Add(-time.Hour), f.Start
+	}
+	return q
+}
+
+// A time between two of `events` in the order they were sent, picked at
+// random. False when the two weren't `fuzzEdgeMargin` apart on both sides.
+func (f *QueryFuzzer) edge(events []ModelEvent) (time.Time, bool) {
+	if len(events) < 2 {
+		return time.Time{}, false
+	}
+	sorted := append([]ModelEvent{}, events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Sent.Before(sorted[j].Sent) })
+	i := 1 + f.r.Intn(len(sorted)-1)
+	before := sorted[0].Acked
+	for _, e := range sorted[:i] {
+		if e.Acked.After(before) {
+			before = e.Acked
+		}
+	}
+	after := sorted[i].Sent
+	if after.Sub(before) < 2*fuzzEdgeMargin {
+		return time.Time{}, false
+	}
+	return before.Add(after.Sub(before) / 2), true
+}
+
+// A query the server answered wrongly or not at all.
+type FuzzFailure struct {
+	Query FuzzQuery
+	// What went wrong: "server error", "rejected", "timeout", "no response"
+	// or "wrong result".
+	Kind   string
+	Detail string
+}
+
+func (failure FuzzFailure) String() string {
+	return fmt.Sprintf("%s for %s (from %s to %s): %s", failure.Kind, failure.Query.SQL(),
+		failure.Query.Start.Format(time.RFC3339Nano), failure.Query.End.Format(time.RFC3339Nano), failure.Detail)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Runs `q` and checks the server's answer; nil if it's right.
+func (f *QueryFuzzer) Check(client HTTPClient, q FuzzQuery) *FuzzFailure {
+	rows, err := client.API().Query(parseable.Query{SQL: q.SQL(), StartTime: q.Start, EndTime: q.End})
+	if err != nil {
+		var apiErr *parseable.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+			return &FuzzFailure{q, "server error", err.Error()}
+		case errors.As(err, &apiErr):
+			return &FuzzFailure{q, "rejected", err.Error()}
+		case isTimeout(err):
+			return &FuzzFailure{q, "timeout", err.Error()}
+		}
+		return &FuzzFailure{q, "no response", err.Error()}
+	}
+
+	expected, ordered, ok := q.Expected(f.events(q.Streams))
+	if !ok {
+		return nil
+	}
+	if !ordered {
+		expected, rows = sortRows(expected), sortRows(rows)
+	}
+	if diff := diffRows(expected, rows); diff != "" {
+		return &FuzzFailure{q, "wrong result", diff}
+	}
+	return nil
+}
+
+// Smaller variants of `q`, each one step simpler.
+func (f *QueryFuzzer) simplifications(q FuzzQuery) []FuzzQuery {
+	var variants []FuzzQuery
+	with := func(change func(*FuzzQuery)) {
+		v := q
+		v.Columns = append([]FuzzColumn{}, q.Columns...)
+		v.Aggregates = append([]fuzzAggregate{}, q.Aggregates...)
+		v.Filters = append([]fuzzFilter{}, q.Filters...)
+		change(&v)
+		variants = append(variants, v)
+	}
+
+	if len(q.Streams) > 1 {
+		for _, stream := range q.Streams {
+			stream := stream
+			with(func(v *FuzzQuery) { v.Streams = []string{stream} })
+		}
+	}
+	for i := range q.Filters {
+		i := i
+		with(func(v *FuzzQuery) { v.Filters = append(v.Filters[:i], v.Filters[i+1:]...) })
+	}
+	if len(q.Columns) > 1 {
+		for i := range q.Columns {
+			i := i
+			with(func(v *FuzzQuery) { v.Columns = append(v.Columns[:i], v.Columns[i+1:]...) })
+		}
+	}
+	if len(q.Aggregates) > 1 {
+		for i := range q.Aggregates {
+			i := i
+			with(func(v *FuzzQuery) { v.Aggregates = append(v.Aggregates[:i], v.Aggregates[i+1:]...) })
+		}
+	}
+	if q.GroupBy != nil {
+		with(func(v *FuzzQuery) { v.GroupBy = nil })
+	}
+	if q.Limit > 0 || q.Offset > 0 {
+		with(func(v *FuzzQuery) { v.Limit, v.Offset = 0, 0 })
+	}
+	if q.Offset > 0 {
+		with(func(v *FuzzQuery) { v.Offset = 0 })
+	}
+	if q.OrderBy && q.Limit == 0 && q.Offset == 0 {
+		with(func(v *FuzzQuery) { v.OrderBy, v.Descending = false, false })
+	}
+	if !q.Start.Equal(f.Start) || !q.End.Equal(f.End) {
+		with(func(v *FuzzQuery) { v.Start, v.End = f.Start, f.End })
+	}
+	return variants
+}
+
+// Simplifies the query of `failure` for as long as the simpler query still
+// fails the same way, trying at most `budget` queries.
+func (f *QueryFuzzer) Shrink(client HTTPClient, failure FuzzFailure, budget int) FuzzFailure {
+	for shrunk := true; shrunk && budget > 0; {
+		shrunk = false
+		for _, v := range f.simplifications(failure.Query) {
+			if budget == 0 {
+				break
+			}
+			budget--
+			if next := f.Check(client, v); next != nil && next.Kind == failure.Kind {
+				failure, shrunk = *next, true
+				break
+			}
+		}
+	}
+	return failure
+}
+
+// Runs `n` random queries and returns the failing ones, shrunk.
+func (f *QueryFuzzer) Run(client HTTPClient, n int) []FuzzFailure {
+	var failures []FuzzFailure
+	for i := 0; i < n; i++ {
+		if failure := f.Check(client, f.Generate()); failure != nil {
+			failures = append(failures, f.Shrink(client, *failure, 50))
+		}
+	}
+	return failures
+}
+
+// Runs `n` random queries over `streams`, whose events are in `model`, and
+// fails the test with a minimal reproducer for every query the server
+// answers wrongly, with a 5xx or not in time. A `seed` of 0 picks one. The
+// events were generated from `logSeed`, which replays the queries along with
+// `seed`.
+func FuzzQueries(t *testing.T, client HTTPClient, model *EventModel, seed int64, logSeed int64, n int, streams ...string) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	schemas := make([]StreamSchema, 0, len(streams))
+	for _, stream := range streams {
+		schema, err := FetchStreamSchema(client, stream)
+		if err != nil {
+			t.Fatalf("Couldn't fetch the schema of %s: %s", stream, err)
+		}
+		schemas = append(schemas, schema)
+	}
+	columns := fuzzColumns(schemas...)
+	if len(columns) == 0 {
+		t.Fatalf("Streams %v have no columns in common to query", streams)
+	}
+
+	fuzzer := NewQueryFuzzer(seed, columns, model, streams...)
+	failures := fuzzer.Run(client, n)
+	for _, failure := range failures {
+		t.Errorf("Query fuzzer (-fuzz-seed=%d -log-seed=%d): %s", seed, logSeed, failure)
+	}
+	t.Logf("Ran %d random queries with -fuzz-seed=%d -log-seed=%d, %d failed", n, seed, logSeed, len(failures))
+}
@@ -0,0 +1,274 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema(t *testing.T, fields string) StreamSchema {
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"fields": [`+fields+`]}`), &schema))
+	return schema
+}
+
+func TestFuzzColumns(t *testing.T) {
+	first := testSchema(t, `
+		{"name": "host", "data_type": "Utf8"},
+		{"name": "status", "data_type": "Int64"},
+		{"name": "ratio", "data_type": "Float64"},
+		{"name": "ok", "data_type": "Boolean"},
+		{"name": "p_tags", "data_type": "Utf8"},
+		{"name": "p_timestamp", "data_type": {"Timestamp": ["Millisecond", null]}}`)
+	second := testSchema(t, `
+		{"name": "host", "data_type": "Utf8"},
+		{"name": "status", "data_type": "Utf8"},
+		{"name": "ratio", "data_type": "Float64"}`)
+
+	require.Equal(t, []FuzzColumn{{"host", kindString}, {"ratio", kindFloat}, {"status", kindInt}}, fuzzColumns(first))
+	require.Equal(t, []FuzzColumn{{"host", kindString}, {"ratio", kindFloat}}, fuzzColumns(first, second))
+}
+
+var (
+	fuzzHost   = FuzzColumn{"host", kindString}
+	fuzzStatus = FuzzColumn{"status", kindInt}
+	fuzzBytes  = FuzzColumn{"bytes", kindInt}
+	fuzzUser   = FuzzColumn{"user-identifier", kindString}
+)
+
+func TestFuzzQuerySQL(t *testing.T) {
+	q := FuzzQuery{
+		Streams:    []string{"app"},
+		Columns:    []FuzzColumn{fuzzHost, fuzzUser},
+		Filters:    []fuzzFilter{{fuzzStatus, ">=", int64(400)}, {fuzzHost, "!=", "it's"}},
+		OrderBy:    true,
+		Descending: true,
+		Limit:      5,
+		Offset:     10,
+	}
+	require.Equal(t, `SELECT "host", "user-identifier" FROM app WHERE "status" >= 400 AND "host" != 'it''s' ORDER BY "host" DESC, "user-identifier" DESC LIMIT 5 OFFSET 10`, q.SQL())
+
+	q = FuzzQuery{
+		Streams:    []string{"app1", "app2"},
+		Aggregates: []fuzzAggregate{{Func: "COUNT"}, {Func: "SUM", Column: &fuzzBytes}},
+		GroupBy:    &fuzzHost,
+	}
+	require.Equal(t, `SELECT "host", COUNT(*) AS a0, SUM("bytes") AS a1 FROM (SELECT "host", "bytes" FROM app1 UNION ALL SELECT "host", "bytes" FROM app2) AS u GROUP BY "host"`, q.SQL())
+}
+
+// Events stamped one second apart from `oracleSent`, some without `bytes`.
+func fuzzEvents(t *testing.T) []ModelEvent {
+	records, err := decodeRecords([]byte(`[
+		{"host": "a", "status": 200, "bytes": 10},
+		{"host": "b", "status": 500, "bytes": 20},
+		{"host": "a", "status": 404},
+		{"host": "c", "status": 200, "bytes": 5},
+		{"status": 500, "bytes": 1}
+	]`))
+	require.NoError(t, err)
+	events := make([]ModelEvent, 0, len(records))
+	for i, record := range records {
+		sent := oracleSent.Add(time.Duration(i) * time.Second)
+		events = append(events, ModelEvent{Fields: record, Sent: sent, Acked: sent.Add(10 * time.Millisecond)})
+	}
+	return events
+}
+
+func TestFuzzQueryExpected(t *testing.T) {
+	events := fuzzEvents(t)
+	full := FuzzQuery{Streams: []string{"app"}, Start: oracleSent.Add(-time.Minute), End: oracleSent.Add(time.Minute)}
+
+	q := full
+	q.Columns = []FuzzColumn{fuzzHost}
+	q.Filters = []fuzzFilter{{fuzzStatus, "=", int64(500)}}
+	rows, ordered, ok := q.Expected(events)
+	require.True(t, ok)
+	require.False(t, ordered)
+	require.Equal(t, []Row{{"host": "b"}, {"host": nil}}, rows)
+
+	q = full
+	q.Aggregates = []fuzzAggregate{{Func: "COUNT"}, {Func: "COUNT", Column: &fuzzBytes}, {Func: "SUM", Column: &fuzzBytes}, {Func: "MIN", Column: &fuzzHost}}
+	rows, _, _ = q.Expected(events)
+	require.Equal(t, []Row{{"a0": int64(5), "a1": int64(4), "a2": int64(36), "a3": "a"}}, rows)
+
+	q.Filters = []fuzzFilter{{fuzzStatus, ">", int64(1000)}}
+	rows, _, _ = q.Expected(events)
+	require.Equal(t, []Row{{"a0": int64(0), "a1": int64(0), "a2": nil, "a3": nil}}, rows)
+
+	q = full
+	q.Aggregates = []fuzzAggregate{{Func: "MAX", Column: &fuzzBytes}}
+	q.GroupBy = &fuzzHost
+	q.OrderBy = true
+	q.Descending = true
+	rows, ordered, _ = q.Expected(events)
+	require.True(t, ordered)
+	require.Equal(t, []Row{{"host": nil, "a0": int64(1)}, {"host": "c", "a0": int64(5)}, {"host": "b", "a0": int64(20)}, {"host": "a", "a0": int64(10)}}, rows)
+
+	q.Descending = false
+	q.Limit = 2
+	q.Offset = 1
+	rows, _, _ = q.Expected(events)
+	require.Equal(t, []Row{{"host": "b", "a0": int64(20)}, {"host": "c", "a0": int64(5)}}, rows)
+
+	q = full
+	q.Aggregates = []fuzzAggregate{{Func: "COUNT"}}
+	q.Start = events[2].Sent
+	rows, _, ok = q.Expected(events)
+	require.True(t, ok)
+	require.Equal(t, []Row{{"a0": int64(3)}}, rows)
+
+	q.Start = events[2].Sent.Add(5 * time.Millisecond)
+	_, _, ok = q.Expected(events)
+	require.False(t, ok, "An event stamped on either side of the start can't be predicted")
+}
+
+func TestSortRows(t *testing.T) {
+	expected := sortRows([]Row{{"host": "b", "n": int64(2)}, {"host": "a", "n": int64(1)}})
+	actual := sortRows([]Row{{"host": "a", "n": json.Number("1")}, {"host": "b", "n": json.Number("2")}})
+	require.Empty(t, diffRows(expected, actual))
+}
+
+func fuzzModel(t *testing.T, streams ...string) *EventModel {
+	model := NewEventModel()
+	for _, stream := range streams {
+		model.Add(stream, fuzzEvents(t)...)
+	}
+	return model
+}
+
+func TestQueryFuzzerGenerate(t *testing.T) {
+	columns := []FuzzColumn{fuzzBytes, fuzzHost, fuzzStatus}
+	model := fuzzModel(t, "app1", "app2")
+	first := NewQueryFuzzer(42, columns, model, "app1", "app2")
+	second := NewQueryFuzzer(42, columns, model, "app1", "app2")
+
+	unions, edges := 0, 0
+	for i := 0; i < 200; i++ {
+		q := first.Generate()
+		require.Equal(t, q, second.Generate(), "The same seed should generate the same queries")
+		require.True(t, q.Start.Before(q.End), "Empty time range in %s", q.SQL())
+		for _, edge := range []time.Time{q.Start, q.End} {
+			if edge.Equal(first.Start) || edge.Equal(first.End) || edge.Before(first.Start) {
+				continue
+			}
+			edges++
+			for _, e := range model.Events(q.Streams[0]) {
+				require.False(t, edge.After(e.Sent.Add(-fuzzEdgeMargin)) && edge.Before(e.Acked.Add(fuzzEdgeMargin)),
+					"Edge %s is within %s of an event sent at %s", edge, fuzzEdgeMargin, e.Sent)
+			}
+		}
+		if !q.OrderBy {
+			require.Zero(t, q.Limit+q.Offset, "LIMIT without ORDER BY in %s", q.SQL())
+		}
+		require.NotRegexp(t, `(MIN|MAX|SUM)\(\*\)`, q.SQL(), "Only COUNT can take *")
+		if len(q.Streams) > 1 {
+			unions++
+		}
+	}
+	require.NotZero(t, unions)
+	require.NotZero(t, edges)
+}
+
+// Answers every query with what `answer` returns for its SQL.
+func fuzzServer(t *testing.T, answer func(sql string) (int, string)) HTTPClient {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		status, response := answer(body.Query)
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return DefaultClient(*u, "admin", "admin")
+}
+
+func TestQueryFuzzerCheck(t *testing.T) {
+	model := fuzzModel(t, "app")
+	fuzzer := NewQueryFuzzer(1, []FuzzColumn{fuzzHost, fuzzStatus}, model, "app")
+	q := FuzzQuery{Streams: []string{"app"}, Aggregates: []fuzzAggregate{{Func: "COUNT"}}, Start: fuzzer.Start, End: fuzzer.End}
+
+	client := fuzzServer(t, func(string) (int, string) { return http.StatusOK, `[{"a0": 5}]` })
+	require.Nil(t, fuzzer.Check(client, q))
+
+	client = fuzzServer(t, func(string) (int, string) { return http.StatusOK, `[{"a0": 4}]` })
+	require.Equal(t, "wrong result", fuzzer.Check(client, q).Kind)
+
+	client = fuzzServer(t, func(string) (int, string) { return http.StatusInternalServerError, "panic" })
+	require.Equal(t, "server error", fuzzer.Check(client, q).Kind)
+
+	client = fuzzServer(t, func(string) (int, string) { return http.StatusBadRequest, "syntax error" })
+	require.Equal(t, "rejected", fuzzer.Check(client, q).Kind)
+
+	client = fuzzServer(t, func(string) (int, string) {
+		time.Sleep(100 * time.Millisecond)
+		return http.StatusOK, "[]"
+	})
+	client.RequestTimeout = 20 * time.Millisecond
+	require.Equal(t, "timeout", fuzzer.Check(client, q).Kind)
+}
+
+func TestQueryFuzzerShrink(t *testing.T) {
+	model := fuzzModel(t, "app1", "app2")
+	fuzzer := NewQueryFuzzer(1, []FuzzColumn{fuzzBytes, fuzzHost, fuzzStatus}, model, "app1", "app2")
+	client := fuzzServer(t, func(sql string) (int, string) {
+		if strings.Contains(sql, "MAX(") {
+			return http.StatusInternalServerError, "panic"
+		}
+		return http.StatusOK, "[]"
+	})
+
+	failing := FuzzQuery{
+		Streams:    []string{"app1", "app2"},
+		Aggregates: []fuzzAggregate{{Func: "COUNT"}, {Func: "MAX", Column: &fuzzStatus}, {Func: "SUM", Column: &fuzzBytes}},
+		GroupBy:    &fuzzHost,
+		Filters:    []fuzzFilter{{fuzzStatus, ">", int64(100)}, {fuzzHost, "!=", "x"}},
+		OrderBy:    true,
+		Limit:      3,
+		Offset:     1,
+		Start:      oracleSent.Add(2 * time.Second),
+		End:        fuzzer.End,
+	}
+	failure := fuzzer.Check(client, failing)
+	require.NotNil(t, failure)
+
+	shrunk := fuzzer.Shrink(client, *failure, 100)
+	require.Equal(t, "server error", shrunk.Kind)
+	require.Equal(t, `SELECT MAX("status") AS a0 FROM app1`, shrunk.Query.SQL())
+	require.Equal(t, fuzzer.Start, shrunk.Query.Start)
+
+	client = fuzzServer(t, func(string) (int, string) { return http.StatusInternalServerError, "panic" })
+	failures := fuzzer.Run(client, 20)
+	require.Len(t, failures, 20)
+	for _, f := range failures {
+		require.Len(t, f.Query.Streams, 1, "Not shrunk: %s", f)
+		require.Empty(t, f.Query.Filters, "Not shrunk: %s", f)
+		require.Nil(t, f.Query.GroupBy, "Not shrunk: %s", f)
+		require.Zero(t, f.Query.Limit+f.Query.Offset, "Not shrunk: %s", f)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// How events are spread across the ingestors of an `IngestorPool`.
+type Distribution string
+
+const (
+	// Every request goes to the next ingestor in turn.
+	RoundRobin Distribution = "round-robin"
+	// Every request goes to an ingestor picked at random.
+	RandomIngestor Distribution = "random"
+	// All requests for a stream go to the same ingestor.
+	HashByStream Distribution = "hash"
+)
+
+func ParseDistribution(value string) (Distribution, error) {
+	switch d := Distribution(strings.ToLower(value)); d {
+	case RoundRobin, RandomIngestor, HashByStream:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown distribution %q, expected %s, %s or %s", value, RoundRobin, RandomIngestor, HashByStream)
+}
+
+// The ingestor nodes events are sent to. Safe for concurrent use.
+type IngestorPool struct {
+	Clients      []HTTPClient
+	Distribution Distribution
+
+	next atomic.Uint64
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func NewIngestorPool(distribution Distribution, seed int64, clients ...HTTPClient) *IngestorPool {
+	return &IngestorPool{
+		Clients:      clients,
+		Distribution: distribution,
+		rand:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Picks the ingestor the next request for `stream` goes to, returning its
+// index in `Clients` along with it.
+func (pool *IngestorPool) Pick(stream string) (int, HTTPClient) {
+	var node int
+	switch pool.Distribution {
+	case RandomIngestor:
+		pool.mu.Lock()
+		node = pool.rand.Intn(len(pool.Clients))
+		pool.mu.Unlock()
+	case HashByStream:
+		h := fnv.New32a()
+		h.Write([]byte(stream))
+		node = int(h.Sum32() % uint32(len(pool.Clients)))
+	default:
+		node = int((pool.next.Add(1) - 1) % uint64(len(pool.Clients)))
+	}
+	return node, pool.Clients[node]
+}
+
+// Events accepted by each ingestor of a pool, indexed like `Clients`.
+type IngestorTally []atomic.Uint64
+
+func (pool *IngestorPool) NewTally() IngestorTally {
+	return make(IngestorTally, len(pool.Clients))
+}
+
+func (tally IngestorTally) Add(node int, events int) {
+	tally[node].Add(uint64(events))
+}
+
+func (tally IngestorTally) Counts() []uint64 {
+	counts := make([]uint64, len(tally))
+	for i := range tally {
+		counts[i] = tally[i].Load()
+	}
+	return counts
+}
+
+func sumCounts(counts []uint64) uint64 {
+	var total uint64
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDistribution(t *testing.T) {
+	for _, value := range []string{"round-robin", "random", "hash", "Hash"} {
+		_, err := ParseDistribution(value)
+		require.NoError(t, err)
+	}
+	_, err := ParseDistribution("least-loaded")
+	require.ErrorContains(t, err, "unknown distribution")
+}
+
+func TestIngestorPoolPick(t *testing.T) {
+	clients := []HTTPClient{{}, {}, {}}
+
+	t.Run("round-robin", func(t *testing.T) {
+		pool := NewIngestorPool(RoundRobin, 1, clients...)
+		picked := make([]int, 0, 6)
+		for i := 0; i < 6; i++ {
+			node, _ := pool.Pick("app")
+			picked = append(picked, node)
+		}
+		require.Equal(t, []int{0, 1, 2, 0, 1, 2}, picked)
+	})
+
+	t.Run("hash", func(t *testing.T) {
+		pool := NewIngestorPool(HashByStream, 1, clients...)
+		first, _ := pool.Pick("app")
+		for i := 0; i < 10; i++ {
+			node, _ := pool.Pick("app")
+			require.Equal(t, first, node)
+		}
+		nodes := make(map[int]bool)
+		for _, stream := range []string{"app", "app1", "app2", "backend", "frontend", "audit", "metrics"} {
+			node, _ := pool.Pick(stream)
+			nodes[node] = true
+		}
+		require.Greater(t, len(nodes), 1, "Every stream hashed to the same ingestor")
+	})
+
+	t.Run("random", func(t *testing.T) {
+		pool := NewIngestorPool(RandomIngestor, 1, clients...)
+		counts := make([]int, len(clients))
+		for i := 0; i < 300; i++ {
+			node, _ := pool.Pick("app")
+			counts[node]++
+		}
+		for node, count := range counts {
+			require.NotZerof(t, count, "Ingestor %d was never picked", node)
+		}
+	})
+}
+
+func TestRunLoadOnIngestors(t *testing.T) {
+	fake := NewFakeParseable(t)
+	first, firstClient := newTestProxy(t, fake)
+	second, secondClient := newTestProxy(t, fake)
+	CreateStream(t, fake.Client(), "loadstream")
+
+	pool := NewIngestorPool(RoundRobin, 1, firstClient, secondClient)
+	options := DefaultLoadOptions()
+	options.VUs = 1
+	options.Duration = 200 * time.Millisecond
+	options.Pause = 10 * time.Millisecond
+	options.SchemaCount = 2
+	options.EventsCount = 1
+
+	result := RunLoadTest(t, pool, "loadstream", options)
+	require.Len(t, result.Accepted, 2)
+	require.Equal(t, result.Events, result.Accepted[0]+result.Accepted[1])
+	require.EqualValues(t, first.Stats(RouteIngest).Requests*2, result.Accepted[0])
+	require.EqualValues(t, second.Stats(RouteIngest).Requests*2, result.Accepted[1])
+	VerifyIngestorCounts(t, fake.Client(), pool, "loadstream", result.Accepted)
+}
+
+func TestRunLoadOnIngestorsCountsPerNode(t *testing.T) {
+	ok, received := loadTestServer(t, http.StatusOK)
+	failing, _ := loadTestServer(t, http.StatusServiceUnavailable)
+	pool := NewIngestorPool(RoundRobin, 1, ok, failing)
+	options := DefaultLoadOptions()
+	options.VUs = 1
+	options.Duration = 100 * time.Millisecond
+	options.Pause = 10 * time.Millisecond
+
+	result, err := RunLoadOn(pool, "loadstream", options)
+	require.NoError(t, err)
+	require.NotZero(t, result.Errors)
+	require.EqualValues(t, received.Load(), result.Accepted[0])
+	require.Zero(t, result.Accepted[1])
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"log/slog"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type Flog struct {
+	Host      string `json:"host"`
+	UserId    string `json:"user-identifier"`
+	Timestamp string `json:"datetime"`
+	Method    string `json:"method"`
+	Request   string `json:"request"`
+	Protocol  string `json:"protocol"`
+	Status    uint16 `json:"status"`
+	ByteCount uint64 `json:"bytes"`
+	Referer   string `json:"referer"`
+	// Only in the apache-combined format, not in the JSON events.
+	UserAgent string `json:"-"`
+}
+
+// - Send logs to `stream`, which the caller creates
+// - Wait for sync
+// - Read the parquet objects created by Parseable from the store
+// - Compare the sent logs with the ones loaded from the parquet objects
+func CheckIntegrity(t *testing.T, stream string) {
+	iterations := 2
+	flogsPerIteration := 100
+
+	// - Generate the logs, replayable with the seed that is logged
+	// - Ingest them into Parseable
+
+	generator := NewTestLogGenerator(t)
+	batches := make([][]Record, 0, iterations)
+
+	for i := 0; i < iterations; i++ {
+		records, err := toRecords(generator.Flogs(flogsPerIteration))
+		require.NoErrorf(t, err, "Couldn't convert flogs to records: %s", err)
+		batches = append(batches, records)
+	}
+
+	IngestAndVerify(t, stream, batches)
+}
+
+// Ingests each batch of events into `stream`, waiting for it to be synced to
+// the object store, then checks the parquet files against what was sent,
+// using the stream's schema to compare values, and the stream's metadata
+// against the parquet files.
+func IngestAndVerify(t *testing.T, stream string, batches [][]Record) {
+	ingestors := NewGlob.For(t).Ingestors
+	IngestAndVerifyWith(t, stream, batches, func(batch []Record) error {
+		return ingestEvents(ingestors, batch, stream)
+	})
+}
+
+// Same as `IngestAndVerify`, sending each batch with `ingest`, e.g. through a
+// `FaultProxy`.
+func IngestAndVerifyWith(t *testing.T, stream string, batches [][]Record, ingest func(batch []Record) error) {
+	glob := NewGlob.For(t)
+	events := make([]Record, 0)
+
+	for i, batch := range batches {
+		// Each batch is expected to land in at least one new parquet file.
+		existing, err := listParquetObjects(stream, glob.Store)
+		require.NoErrorf(t, err, "Couldn't list parquet objects: %s", err)
+
+		err = ingest(batch)
+		require.NoErrorf(t, err, "Couldn't ingest events: %s", err)
+
+		events = append(events, batch...)
+
+		slog.Info("ingested logs, waiting for sync...",
+			"iteration", i+1,
+			"log_count", len(batch))
+
+		// Wait for the events to be sync'd.
+		WaitForParquetObjects(t, glob.Store, stream, len(existing)+1, syncTimeout)
+	}
+
+	schema, err := FetchStreamSchema(glob.QueryClient, stream)
+	require.NoErrorf(t, err, "Couldn't fetch schema of stream %s: %s", stream, err)
+
+	rows, err := loadStreamRecords(stream, glob.Store)
+	require.NoErrorf(t, err, "Couldn't read parquet objects: %s", err)
+
+	diff := diffRecords(schema, events, rows)
+	t.Logf("Integrity of stream %s: %s", stream, diff)
+	require.Truef(t, diff.Empty(), "Stored rows don't match the events sent: %s", diff)
+
+	VerifyStreamMetadata(t, glob.QueryClient, glob.Store, stream)
+}
+
+// Generates `batches` batches of events with the load test schemas, with
+// `perSchema` events of each schema in every batch.
+func loadEventBatches(r *rand.Rand, schemaCount int, batches int, perSchema int) ([][]Record, error) {
+	schemas := overlappingLoadSchemas(schemaCount)
+	result := make([][]Record, 0, batches)
+	for i := 0; i < batches; i++ {
+		records, err := toRecords(generateLoadEvents(r, time.Now(), schemas, perSchema))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, records)
+	}
+	return result, nil
+}
+
+func ingestEvents(ingestors *IngestorPool, events interface{}, stream string) error {
+	_, client := ingestors.Pick(stream)
+	return client.API().Ingest(stream, events, nil)
+}
+
+// Reads the rows of every parquet object of `stream` straight from `store`,
+// several objects at a time, in the order of their keys.
+func loadStreamRecords(stream string, store ObjectStore) ([]Record, error) {
+	keys, err := listParquetObjects(stream, store)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("reading parquet objects", "store", store.String(), "stream", stream, "count", len(keys))
+
+	objectRecords, err := readParquetObjects(keys, func(key string) ([]Record, error) {
+		return readParquetObject(store, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, 0, len(keys)*10)
+	for _, key := range keys {
+		records = append(records, objectRecords[key]...)
+	}
+	return records, nil
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// How a stream's objects are partitioned in the object store.
+type StreamLayout struct {
+	// Column the date, hour and minute segments of the keys come from.
+	TimeColumn string
+	// Columns of the segments after the minute, in order.
+	CustomPartitions []string
+}
+
+// Layout of `stream`, from its info.
+func FetchStreamLayout(client HTTPClient, stream string) (StreamLayout, error) {
+	info, err := client.API().Info(stream)
+	if err != nil {
+		return StreamLayout{}, err
+	}
+	return layoutFromInfo(info), nil
+}
+
+func layoutFromInfo(info parseable.StreamInfo) StreamLayout {
+	layout := StreamLayout{TimeColumn: info.TimePartition}
+	if layout.TimeColumn == "" {
+		layout.TimeColumn = "p_timestamp"
+	}
+	for _, column := range strings.Split(info.CustomPartition, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			layout.CustomPartitions = append(layout.CustomPartitions, column)
+		}
+	}
+	return layout
+}
+
+// A `{column}={value}` segment of an object key.
+type PartitionValue struct {
+	Column string
+	Value  string
+}
+
+// What the key of an object says about the rows in it, e.g.
+// `{stream}/date=2024-05-10/hour=03/minute=15/level=error/{file}.parquet`.
+type ObjectPartition struct {
+	Key  string
+	Date time.Time
+	// -1 when the key has no such segment.
+	Hour   int
+	Minute int
+	Custom []PartitionValue
+}
+
+// Parses the partition segments of `key`, an object of `stream`.
+func ParseObjectKey(stream string, key string) (ObjectPartition, error) {
+	partition := ObjectPartition{Key: key, Hour: -1, Minute: -1}
+	rest, ok := strings.CutPrefix(key, stream+"/")
+	if !ok {
+		return partition, fmt.Errorf("%s is not under %s/", key, stream)
+	}
+	segments := strings.Split(rest, "/")
+	segments = segments[:len(segments)-1]
+	if len(segments) == 0 {
+		return partition, fmt.Errorf("%s has no date segment", key)
+	}
+
+	for i, segment := range segments {
+		column, value, ok := strings.Cut(segment, "=")
+		if !ok || column == "" || value == "" {
+			return partition, fmt.Errorf("segment %q of %s is not a {column}={value} pair", segment, key)
+		}
+		var err error
+		switch {
+		case i == 0:
+			if column != "date" {
+				return partition, fmt.Errorf("%s starts with %q instead of a date segment", key, segment)
+			}
+			partition.Date, err = time.Parse("2006-01-02", value)
+		case column == "hour" && i == 1:
+			partition.Hour, err = parsePartitionNumber(value, 23)
+		case column == "minute" && i == 2 && partition.Hour >= 0:
+			partition.Minute, err = parsePartitionNumber(value, 59)
+		default:
+			partition.Custom = append(partition.Custom, PartitionValue{column, value})
+		}
+		if err != nil {
+			return partition, fmt.Errorf("segment %q of %s: %w", segment, key, err)
+		}
+	}
+	return partition, nil
+}
+
+func parsePartitionNumber(value string, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("%q is not between 0 and %d", value, max)
+	}
+	return n, nil
+}
+
+// Time span the rows of the object must fall in: its minute, hour or day,
+// depending on how far its key goes.
+func (partition ObjectPartition) Window() (time.Time, time.Time) {
+	switch {
+	case partition.Minute >= 0:
+		start := partition.Date.Add(time.Duration(partition.Hour)*time.Hour + time.Duration(partition.Minute)*time.Minute)
+		return start, start.Add(time.Minute)
+	case partition.Hour >= 0:
+		start := partition.Date.Add(time.Duration(partition.Hour) * time.Hour)
+		return start, start.Add(time.Hour)
+	}
+	return partition.Date, partition.Date.AddDate(0, 0, 1)
+}
+
+// Something in an object that doesn't match its key. `Row` is -1 when it's
+// about the key itself.
+type LayoutViolation struct {
+	Key    string
+	Row    int
+	Reason string
+}
+
+func (violation LayoutViolation) String() string {
+	if violation.Row < 0 {
+		return fmt.Sprintf("%s: %s", violation.Key, violation.Reason)
+	}
+	return fmt.Sprintf("%s, row %d: %s", violation.Key, violation.Row, violation.Reason)
+}
+
+// Checks that the custom partitions of `partition` are those of `layout` and
+// that every row of `rows` belongs in it.
+func checkObjectRows(layout StreamLayout, schema StreamSchema, partition ObjectPartition, rows []Record) []LayoutViolation {
+	var violations []LayoutViolation
+	violate := func(row int, format string, args ...interface{}) {
+		violations = append(violations, LayoutViolation{partition.Key, row, fmt.Sprintf(format, args...)})
+	}
+
+	columns := make([]string, 0, len(partition.Custom))
+	for _, value := range partition.Custom {
+		columns = append(columns, value.Column)
+	}
+	if strings.Join(columns, ",") != strings.Join(layout.CustomPartitions, ",") {
+		violate(-1, "custom partitions %v, expected %v", columns, layout.CustomPartitions)
+		return violations
+	}
+
+	timeField, _ := schema.Field(layout.TimeColumn)
+	start, end := partition.Window()
+	for i, row := range rows {
+		value, err := coerceValue(timeField, row[layout.TimeColumn])
+		ts, ok := value.(time.Time)
+		switch {
+		case err != nil:
+			violate(i, "%s: %s", layout.TimeColumn, err)
+		case !ok:
+			violate(i, "%s is %v, not a timestamp", layout.TimeColumn, row[layout.TimeColumn])
+		case ts.Before(start) || !ts.Before(end):
+			violate(i, "%s %s is outside %s - %s", layout.TimeColumn, ts.Format(time.RFC3339Nano), start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+
+		for _, expected := range partition.Custom {
+			field, _ := schema.Field(expected.Column)
+			value, err := coerceValue(field, row[expected.Column])
+			if err != nil {
+				violate(i, "%s: %s", expected.Column, err)
+			} else if actual := fmt.Sprint(value); value == nil || actual != expected.Value {
+				violate(i, "%s is %v", expected.Column, value)
+			}
+		}
+	}
+	return violations
+}
+
+// Checks every parquet object of `stream` in `store` against its key.
+func ValidateObjectLayout(client HTTPClient, store ObjectStore, stream string) ([]LayoutViolation, error) {
+	layout, err := FetchStreamLayout(client, stream)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := FetchStreamSchema(client, stream)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := listParquetObjects(stream, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []LayoutViolation
+	partitions := make(map[string]ObjectPartition, len(keys))
+	for _, key := range keys {
+		partition, err := ParseObjectKey(stream, key)
+		if err != nil {
+			violations = append(violations, LayoutViolation{key, -1, err.Error()})
+			continue
+		}
+		partitions[key] = partition
+	}
+	objectViolations, err := readParquetObjects(sortedKeys(partitions), func(key string) ([]LayoutViolation, error) {
+		rows, err := readParquetObject(store, key)
+		if err != nil {
+			return nil, err
+		}
+		return checkObjectRows(layout, schema, partitions[key], rows), nil
+	})
+	if err != nil {
+		return violations, err
+	}
+	for _, key := range sortedKeys(objectViolations) {
+		violations = append(violations, objectViolations[key]...)
+	}
+	return violations, nil
+}
+
+// Waits for `stream` to have parquet objects in `store` and checks that
+// each of them holds only rows of the partition its key names.
+func AssertObjectLayout(t *testing.T, client HTTPClient, store ObjectStore, stream string) {
+	WaitForParquetObjects(t, store, stream, 1, syncTimeout)
+	violations, err := ValidateObjectLayout(client, store, stream)
+	require.NoErrorf(t, err, "Couldn't validate the objects of %s: %s", stream, err)
+	if len(violations) > 0 {
+		t.Fatalf("%d rows of %s are in objects of the wrong partition:%s", len(violations), stream, formatIssues(violations))
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseObjectKey(t *testing.T) {
+	partition, err := ParseObjectKey("app", "app/date=2024-05-10/hour=03/minute=15/level=error/os=Linux/host.data.parquet")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC), partition.Date)
+	require.Equal(t, 3, partition.Hour)
+	require.Equal(t, 15, partition.Minute)
+	require.Equal(t, []PartitionValue{{"level", "error"}, {"os", "Linux"}}, partition.Custom)
+
+	start, end := partition.Window()
+	require.Equal(t, time.Date(2024, 5, 10, 3, 15, 0, 0, time.UTC), start)
+	require.Equal(t, time.Date(2024, 5, 10, 3, 16, 0, 0, time.UTC), end)
+
+	partition, err = ParseObjectKey("app", "app/date=2024-05-10/host.data.parquet")
+	require.NoError(t, err)
+	require.Equal(t, -1, partition.Hour)
+	start, end = partition.Window()
+	require.Equal(t, 24*time.Hour, end.Sub(start))
+
+	for _, key := range []string{
+		"other/date=2024-05-10/hour=03/minute=15/host.data.parquet",
+		"app/host.data.parquet",
+		"app/hour=03/date=2024-05-10/host.data.parquet",
+		"app/date=2024-13-10/host.data.parquet",
+		"app/date=2024-05-10/hour=24/minute=00/host.data.parquet",
+		"app/date=2024-05-10/hour=03/minute=15/level/host.data.parquet",
+	} {
+		_, err := ParseObjectKey("app", key)
+		require.Error(t, err, key)
+	}
+}
+
+func TestLayoutFromInfo(t *testing.T) {
+	require.Equal(t, StreamLayout{TimeColumn: "p_timestamp"}, layoutFromInfo(parseable.StreamInfo{}))
+	require.Equal(t,
+		StreamLayout{TimeColumn: "source_time", CustomPartitions: []string{"level", "os"}},
+		layoutFromInfo(parseable.StreamInfo{TimePartition: "source_time", CustomPartition: "level, os"}))
+}
+
+func TestFetchStreamLayout(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	header := map[string]string{"X-P-Time-Partition": "source_time", "X-P-Custom-Partition": "level"}
+	require.NoError(t, client.API().CreateStream("partitioned", parseable.StreamOptions{Header: header}))
+
+	layout, err := FetchStreamLayout(client, "partitioned")
+	require.NoError(t, err)
+	require.Equal(t, StreamLayout{TimeColumn: "source_time", CustomPartitions: []string{"level"}}, layout)
+}
+
+func TestCheckObjectRows(t *testing.T) {
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"fields": [
+		{"name": "source_time", "data_type": {"Timestamp": ["Millisecond", null]}},
+		{"name": "level", "data_type": "Utf8"}
+	]}`), &schema))
+	layout := StreamLayout{TimeColumn: "source_time", CustomPartitions: []string{"level"}}
+	partition, err := ParseObjectKey("app", "app/date=2024-05-10/hour=03/minute=15/level=error/host.data.parquet")
+	require.NoError(t, err)
+	at := func(minute int, second int) int64 {
+		return time.Date(2024, 5, 10, 3, minute, second, 0, time.UTC).UnixMilli()
+	}
+
+	rows := []Record{
+		{"source_time": at(15, 0), "level": "error"},
+		{"source_time": at(15, 59), "level": "error"},
+	}
+	require.Empty(t, checkObjectRows(layout, schema, partition, rows))
+
+	rows = []Record{
+		{"source_time": at(16, 0), "level": "error"},
+		{"source_time": at(15, 30), "level": "info"},
+		{"level": "error"},
+		{"source_time": "2024-05-10T03:15:10.000Z"},
+	}
+	violations := checkObjectRows(layout, schema, partition, rows)
+	require.Len(t, violations, 4)
+	require.Equal(t, 0, violations[0].Row)
+	require.Contains(t, violations[0].Reason, "outside")
+	require.Equal(t, 1, violations[1].Row)
+	require.Equal(t, "level is info", violations[1].Reason)
+	require.Equal(t, 2, violations[2].Row)
+	require.Equal(t, 3, violations[3].Row)
+	require.Equal(t, "level is <nil>", violations[3].Reason)
+
+	layout.CustomPartitions = []string{"os"}
+	violations = checkObjectRows(layout, schema, partition, rows)
+	require.Equal(t, []LayoutViolation{{partition.Key, -1, "custom partitions [level], expected [os]"}}, violations)
+	require.Equal(t, partition.Key+": custom partitions [level], expected [os]", violations[0].String())
+}
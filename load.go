@@ -0,0 +1,531 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event generation below is a port of the k6 scripts load_batch_events.js and
+// load_single_event.js quest used to run, so that streams loaded by either end
+// up with the same set of overlapping schemas.
+
+type loadField struct {
+	name string
+	gen  func(r *rand.Rand, now time.Time) interface{}
+}
+
+func randomItem[T any](items ...T) func(r *rand.Rand, now time.Time) interface{} {
+	return func(r *rand.Rand, now time.Time) interface{} {
+		return items[r.Intn(len(items))]
+	}
+}
+
+func randomIntBetween(min, max int) func(r *rand.Rand, now time.Time) interface{} {
+	return func(r *rand.Rand, now time.Time) interface{} {
+		return min + r.Intn(max-min+1)
+	}
+}
+
+func randomString(length int) func(r *rand.Rand, now time.Time) interface{} {
+	const charset = "abcdefghijklmnopqrstuvwxyz"
+	return func(r *rand.Rand, now time.Time) interface{} {
+		b := make([]byte, length)
+		for i := range b {
+			b[i] = charset[r.Intn(len(charset))]
+		}
+		return string(b)
+	}
+}
+
+func uuidv4(r *rand.Rand, now time.Time) interface{} {
+	var b [16]byte
+	r.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func currentTime(r *rand.Rand, now time.Time) interface{} {
+	return now.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+var commonLoadSchema = []loadField{
+	{"source_time", currentTime},
+	{"level", randomItem("info", "warn", "error")},
+	{"message", randomItem("Application started", "Application is failing", "Logging a request")},
+	{"version", randomItem("1.0.0", "1.1.0", "1.2.0")},
+	{"user_id", randomIntBetween(10000, 100000)},
+	{"device_id", randomIntBetween(0, 5000)},
+	{"session_id", randomItem("abc", "pqr", "xyz")},
+	{"os", randomItem("macOS", "Linux", "Windows")},
+	{"host", randomItem("192.168.1.100", "112.168.1.110", "172.162.1.120")},
+	{"uuid", uuidv4},
+}
+
+var additionalLoadFields = map[string]func(r *rand.Rand, now time.Time) interface{}{
+	"location":      randomString(16),
+	"timezone":      randomString(3),
+	"user_agent":    randomItem("Banana", "PineApple", "PearOS", "OrangeOS", "Kiwi"),
+	"runtime":       randomString(3),
+	"request_body":  randomString(100),
+	"status_code":   randomItem(200, 300, 400, 500),
+	"response_time": randomItem(12, 22, 34, 56, 70, 112),
+	"process_id":    randomIntBetween(100, 1000),
+	"app_meta":      randomString(24),
+}
+
+var additionalLoadFieldsPermutations = [][]string{
+	{"location", "request_body", "status_code", "app_meta"},
+	{"timezone", "user_agent", "runtime", "app_meta"},
+	{"timezone", "request_body", "response_time", "process_id"},
+	{"timezone", "user_agent", "request_body", "process_id"},
+	{"runtime", "status_code", "response_time", "process_id"},
+	{"location", "user_agent", "runtime", "process_id"},
+	{"location", "timezone", "request_body", "response_time"},
+	{"timezone", "user_agent", "status_code", "process_id"},
+	{"timezone", "runtime", "request_body", "response_time"},
+	{"timezone", "status_code", "response_time", "process_id"},
+	{"timezone", "runtime", "status_code", "response_time"},
+	{"location", "timezone", "response_time", "process_id"},
+	{"location", "timezone", "runtime", "process_id"},
+	{"user_agent", "runtime", "status_code", "process_id"},
+	{"timezone", "response_time", "process_id", "app_meta"},
+	{"location", "user_agent", "status_code", "response_time"},
+	{"timezone", "user_agent", "runtime", "status_code"},
+	{"request_body", "status_code", "process_id", "app_meta"},
+	{"location", "user_agent", "runtime", "request_body"},
+	{"location", "timezone", "status_code", "response_time"},
+	{"location", "user_agent", "response_time", "process_id"},
+	{"timezone", "runtime", "response_time", "process_id"},
+	{"location", "timezone", "user_agent", "runtime"},
+	{"user_agent", "request_body", "status_code", "process_id"},
+	{"runtime", "request_body", "response_time", "process_id"},
+	{"location", "runtime", "request_body", "app_meta"},
+	{"runtime", "response_time", "process_id", "app_meta"},
+	{"location", "runtime", "status_code", "app_meta"},
+	{"location", "runtime", "process_id", "app_meta"},
+	{"location", "request_body", "process_id", "app_meta"},
+	{"location", "timezone", "runtime", "request_body"},
+	{"timezone", "user_agent", "response_time", "app_meta"},
+	{"runtime", "request_body", "status_code", "response_time"},
+	{"location", "timezone", "user_agent", "response_time"},
+	{"location", "runtime", "request_body", "status_code"},
+	{"location", "user_agent", "request_body", "response_time"},
+	{"location", "status_code", "process_id", "app_meta"},
+	{"user_agent", "status_code", "response_time", "app_meta"},
+	{"timezone", "request_body", "status_code", "response_time"},
+	{"user_agent", "runtime", "request_body", "process_id"},
+	{"user_agent", "runtime", "response_time", "app_meta"},
+	{"user_agent", "request_body", "response_time", "app_meta"},
+}
+
+// Builds `count` schemas that all share `commonLoadSchema` and each add a
+// different set of four fields.
+func overlappingLoadSchemas(count int) [][]loadField {
+	if count <= 0 {
+		count = 5
+	}
+	if count > len(additionalLoadFieldsPermutations) {
+		count = len(additionalLoadFieldsPermutations)
+	}
+
+	schemas := make([][]loadField, 0, count)
+	for _, fields := range additionalLoadFieldsPermutations[:count] {
+		schema := append([]loadField{}, commonLoadSchema...)
+		for _, name := range fields {
+			schema = append(schema, loadField{name, additionalLoadFields[name]})
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas
+}
+
+func generateLoadEvent(r *rand.Rand, now time.Time, schema []loadField) map[string]interface{} {
+	event := make(map[string]interface{}, len(schema))
+	for _, field := range schema {
+		event[field.name] = field.gen(r, now)
+	}
+	return event
+}
+
+// Generates `perSchema` events for each of the schemas, in schema order.
+func generateLoadEvents(r *rand.Rand, now time.Time, schemas [][]loadField, perSchema int) []map[string]interface{} {
+	events := make([]map[string]interface{}, 0, perSchema*len(schemas))
+	for i := 0; i < perSchema; i++ {
+		for _, schema := range schemas {
+			events = append(events, generateLoadEvent(r, now, schema))
+		}
+	}
+	return events
+}
+
+type LoadExecutor string
+
+const (
+	// A fixed number of VUs run iterations back to back for the duration.
+	ConstantVUs LoadExecutor = "constant-vus"
+	// Iterations are started at a fixed rate for the duration, regardless of
+	// how long each one takes, using at most `VUs` concurrent iterations.
+	ConstantArrivalRate LoadExecutor = "constant-arrival-rate"
+	// VUs run `Iterations` iterations between them, back to back, stopping
+	// early only if the duration runs out.
+	SharedIterations LoadExecutor = "shared-iterations"
+)
+
+type LoadOptions struct {
+	Executor LoadExecutor
+	VUs      int
+	Duration time.Duration
+	// Iterations per second, only used by `ConstantArrivalRate`.
+	Rate int
+	// Iterations in all, only used by `SharedIterations`.
+	Iterations int
+	// Pause between iterations of a VU, used by `ConstantVUs` and
+	// `SharedIterations`.
+	Pause time.Duration
+	// Send all events of an iteration as one JSON array, or one request per event.
+	Batch bool
+	// Send the events of an iteration both ways, as one JSON array and then
+	// one request per event. Overrides `Batch`.
+	BatchAndSingle bool
+	SchemaCount    int
+	// Events generated per schema in an iteration. Single event mode always
+	// generates one.
+	EventsCount int
+	// Set `source_time` a month in the past, like the k6 script load_historical_batch_events.js did.
+	Historical bool
+	Headers    map[string]string
+	Seed       int64
+}
+
+// Same as the defaults of the k6 scripts.
+func DefaultLoadOptions() LoadOptions {
+	return LoadOptions{
+		Executor:    ConstantVUs,
+		VUs:         10,
+		Duration:    5 * time.Minute,
+		Pause:       100 * time.Millisecond,
+		Batch:       true,
+		SchemaCount: 5,
+		EventsCount: 10,
+		Headers: map[string]string{
+			"X-P-META-Host":           "10.116.0.3",
+			"X-P-META-Source":         "quest-test",
+			"X-P-META-ContainerName":  "log-generator",
+			"X-P-META-ContainerImage": "ghcr.io/parseablehq/quest",
+			"X-P-META-Namespace":      "go-apasdp",
+			"X-P-META-PodLabels":      "app=go-app,pod-template-hash=6c87bc9cc9",
+		},
+		Seed: time.Now().UnixNano(),
+	}
+}
+
+func (options LoadOptions) validate() error {
+	if options.VUs <= 0 {
+		return errors.New("load: VUs must be positive")
+	}
+	if options.Duration <= 0 {
+		return errors.New("load: duration must be positive")
+	}
+	switch options.Executor {
+	case ConstantVUs:
+	case ConstantArrivalRate:
+		if options.Rate <= 0 {
+			return errors.New("load: rate must be positive for constant-arrival-rate")
+		}
+	case SharedIterations:
+		if options.Iterations <= 0 {
+			return errors.New("load: iterations must be positive for shared-iterations")
+		}
+	default:
+		return fmt.Errorf("load: unknown executor %q", options.Executor)
+	}
+	return nil
+}
+
+type LatencyStats struct {
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P95  time.Duration `json:"p95"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+type LoadResult struct {
+	Requests uint64 `json:"requests"`
+	// Requests that failed to complete or didn't return 200.
+	Errors uint64 `json:"errors"`
+	// Events in requests that returned 200.
+	Events uint64 `json:"events"`
+	// Request body bytes sent.
+	Bytes      uint64 `json:"bytes"`
+	Iterations uint64 `json:"iterations"`
+	// Iterations `ConstantArrivalRate` couldn't start because all VUs were busy.
+	DroppedIterations uint64         `json:"dropped_iterations"`
+	StatusCodes       map[int]uint64 `json:"status_codes"`
+	Duration          time.Duration  `json:"duration"`
+	Latency           LatencyStats   `json:"latency"`
+	// Events accepted by each ingestor of the pool the load was sent to.
+	Accepted []uint64 `json:"accepted"`
+}
+
+func (result LoadResult) ErrorRate() float64 {
+	if result.Requests == 0 {
+		return 0
+	}
+	return float64(result.Errors) / float64(result.Requests)
+}
+
+func (result LoadResult) EventsPerSecond() float64 {
+	if result.Duration <= 0 {
+		return 0
+	}
+	return float64(result.Events) / result.Duration.Seconds()
+}
+
+func (result LoadResult) String() string {
+	return fmt.Sprintf("requests=%d errors=%d events=%d bytes=%d iterations=%d dropped=%d events/s=%.1f latency(p50=%s p95=%s p99=%s max=%s) status=%v",
+		result.Requests, result.Errors, result.Events, result.Bytes, result.Iterations, result.DroppedIterations,
+		result.EventsPerSecond(), result.Latency.P50, result.Latency.P95, result.Latency.P99, result.Latency.Max, result.StatusCodes)
+}
+
+type loadRecorder struct {
+	requests, errors, events, bytes, iterations, dropped atomic.Uint64
+
+	mu          sync.Mutex
+	latencies   []time.Duration
+	statusCodes map[int]uint64
+	lastErr     error
+	accepted    IngestorTally
+}
+
+func (rec *loadRecorder) record(node int, status int, latency time.Duration, events int, size int, err error) {
+	rec.requests.Add(1)
+	rec.bytes.Add(uint64(size))
+	if err != nil || status != http.StatusOK {
+		rec.errors.Add(1)
+	} else {
+		rec.events.Add(uint64(events))
+		rec.accepted.Add(node, events)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if err != nil {
+		rec.lastErr = err
+		return
+	}
+	rec.statusCodes[status]++
+	rec.latencies = append(rec.latencies, latency)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx]
+}
+
+func latencyStats(latencies []time.Duration) LatencyStats {
+	if len(latencies) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	return LatencyStats{
+		Min:  sorted[0],
+		Mean: total / time.Duration(len(sorted)),
+		P50:  percentile(sorted, 0.50),
+		P90:  percentile(sorted, 0.90),
+		P95:  percentile(sorted, 0.95),
+		P99:  percentile(sorted, 0.99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+func (rec *loadRecorder) result(elapsed time.Duration) LoadResult {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	statusCodes := make(map[int]uint64, len(rec.statusCodes))
+	for k, v := range rec.statusCodes {
+		statusCodes[k] = v
+	}
+	return LoadResult{
+		Requests:          rec.requests.Load(),
+		Errors:            rec.errors.Load(),
+		Events:            rec.events.Load(),
+		Bytes:             rec.bytes.Load(),
+		Iterations:        rec.iterations.Load(),
+		DroppedIterations: rec.dropped.Load(),
+		StatusCodes:       statusCodes,
+		Duration:          elapsed,
+		Latency:           latencyStats(rec.latencies),
+		Accepted:          rec.accepted.Counts(),
+	}
+}
+
+func sendLoadPayload(ingestors *IngestorPool, stream string, headers map[string]string, payload []byte, events int, rec *loadRecorder) {
+	node, client := ingestors.Pick(stream)
+	req, err := client.NewRequest("POST", "ingest", bytes.NewReader(payload))
+	if err != nil {
+		rec.record(node, 0, 0, events, len(payload), err)
+		return
+	}
+	req.Header.Add("X-P-Stream", stream)
+	for k, v := range headers {
+		req.Header.Add(k, v)
+	}
+
+	start := time.Now()
+	response, err := client.Do(req)
+	if err != nil {
+		rec.record(node, 0, 0, events, len(payload), err)
+		return
+	}
+	io.Copy(io.Discard, response.Body)
+	response.Body.Close()
+	rec.record(node, response.StatusCode, time.Since(start), events, len(payload), nil)
+}
+
+// One iteration of the k6 scripts' default function.
+func loadIteration(ingestors *IngestorPool, stream string, options LoadOptions, schemas [][]loadField, r *rand.Rand, rec *loadRecorder) {
+	rec.iterations.Add(1)
+	now := time.Now()
+	if options.Historical {
+		now = now.AddDate(0, -1, 0)
+	}
+
+	var events []map[string]interface{}
+	if options.Batch || options.BatchAndSingle {
+		perSchema := options.EventsCount
+		if perSchema <= 0 {
+			perSchema = 10
+		}
+		events = generateLoadEvents(r, now, schemas, perSchema)
+		payload, _ := json.Marshal(events)
+		sendLoadPayload(ingestors, stream, options.Headers, payload, len(events), rec)
+		if !options.BatchAndSingle {
+			return
+		}
+	} else {
+		events = generateLoadEvents(r, now, schemas, 1)
+	}
+
+	for _, event := range events {
+		payload, _ := json.Marshal(event)
+		sendLoadPayload(ingestors, stream, options.Headers, payload, 1, rec)
+	}
+}
+
+// Generates load against `stream` through `client` as described by `options`
+// and returns what happened. Failed requests don't stop the run, they are
+// counted in the result for the caller to assert on.
+func RunLoad(client HTTPClient, stream string, options LoadOptions) (LoadResult, error) {
+	return RunLoadOn(NewIngestorPool(RoundRobin, 0, client), stream, options)
+}
+
+// Same as `RunLoad`, spreading the requests across `ingestors`.
+func RunLoadOn(ingestors *IngestorPool, stream string, options LoadOptions) (LoadResult, error) {
+	if err := options.validate(); err != nil {
+		return LoadResult{}, err
+	}
+
+	schemas := overlappingLoadSchemas(options.SchemaCount)
+	rec := &loadRecorder{statusCodes: make(map[int]uint64), accepted: ingestors.NewTally()}
+	start := time.Now()
+	deadline := start.Add(options.Duration)
+	var wg sync.WaitGroup
+
+	switch options.Executor {
+	case ConstantVUs:
+		for vu := 0; vu < options.VUs; vu++ {
+			wg.Add(1)
+			go func(r *rand.Rand) {
+				defer wg.Done()
+				for time.Now().Before(deadline) {
+					loadIteration(ingestors, stream, options, schemas, r, rec)
+					time.Sleep(options.Pause)
+				}
+			}(rand.New(rand.NewSource(options.Seed + int64(vu))))
+		}
+
+	case SharedIterations:
+		var started atomic.Int64
+		for vu := 0; vu < options.VUs; vu++ {
+			wg.Add(1)
+			go func(r *rand.Rand) {
+				defer wg.Done()
+				for time.Now().Before(deadline) && started.Add(1) <= int64(options.Iterations) {
+					loadIteration(ingestors, stream, options, schemas, r, rec)
+					time.Sleep(options.Pause)
+				}
+			}(rand.New(rand.NewSource(options.Seed + int64(vu))))
+		}
+
+	case ConstantArrivalRate:
+		// Each VU owns its random source, so a VU is a token handed from one
+		// iteration to the next.
+		idle := make(chan *rand.Rand, options.VUs)
+		for vu := 0; vu < options.VUs; vu++ {
+			idle <- rand.New(rand.NewSource(options.Seed + int64(vu)))
+		}
+		ticker := time.NewTicker(time.Second / time.Duration(options.Rate))
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if !now.Before(deadline) {
+				break
+			}
+			select {
+			case r := <-idle:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					loadIteration(ingestors, stream, options, schemas, r, rec)
+					idle <- r
+				}()
+			default:
+				rec.dropped.Add(1)
+			}
+		}
+	}
+
+	wg.Wait()
+	result := rec.result(time.Since(start))
+	if result.Requests > 0 && result.Errors == result.Requests && rec.lastErr != nil {
+		return result, fmt.Errorf("load: every request failed, last error: %w", rec.lastErr)
+	}
+	return result, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestServer(t *testing.T, status int) (HTTPClient, *atomic.Int64) {
+	var events atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/ingest", r.URL.Path)
+		require.Equal(t, "loadstream", r.Header.Get("X-P-Stream"))
+		var batch []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			events.Add(1)
+		} else {
+			events.Add(int64(len(batch)))
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return DefaultClient(*u, "admin", "admin"), &events
+}
+
+func TestOverlappingLoadSchemas(t *testing.T) {
+	schemas := overlappingLoadSchemas(10)
+	require.Len(t, schemas, 10)
+	for _, schema := range schemas {
+		require.Len(t, schema, len(commonLoadSchema)+4)
+	}
+	require.Len(t, overlappingLoadSchemas(0), 5)
+	require.Len(t, overlappingLoadSchemas(1000), len(additionalLoadFieldsPermutations))
+}
+
+func TestRunLoadConstantVUs(t *testing.T) {
+	client, received := loadTestServer(t, http.StatusOK)
+	options := DefaultLoadOptions()
+	options.VUs = 2
+	options.Duration = 300 * time.Millisecond
+	options.Pause = 10 * time.Millisecond
+	options.SchemaCount = 3
+	options.EventsCount = 2
+
+	result, err := RunLoad(client, "loadstream", options)
+	require.NoError(t, err)
+	require.NotZero(t, result.Requests)
+	require.Zero(t, result.Errors)
+	require.Equal(t, result.Requests*6, result.Events)
+	require.Equal(t, int64(result.Events), received.Load())
+	require.Equal(t, result.Requests, result.StatusCodes[200])
+	require.LessOrEqual(t, result.Latency.Min, result.Latency.P50)
+	require.LessOrEqual(t, result.Latency.P50, result.Latency.P99)
+	require.LessOrEqual(t, result.Latency.P99, result.Latency.Max)
+}
+
+func TestRunLoadConstantArrivalRateSingleEvents(t *testing.T) {
+	client, _ := loadTestServer(t, http.StatusOK)
+	options := DefaultLoadOptions()
+	options.Executor = ConstantArrivalRate
+	options.Rate = 20
+	options.VUs = 4
+	options.Duration = 500 * time.Millisecond
+	options.Batch = false
+	options.SchemaCount = 2
+
+	result, err := RunLoad(client, "loadstream", options)
+	require.NoError(t, err)
+	require.InDelta(t, 10, result.Iterations, 3)
+	require.Equal(t, result.Iterations*2, result.Requests)
+	require.Equal(t, result.Requests, result.Events)
+}
+
+func TestRunLoadSharedIterationsBatchAndSingle(t *testing.T) {
+	client, received := loadTestServer(t, http.StatusOK)
+	options := DefaultLoadOptions()
+	options.Executor = SharedIterations
+	options.Iterations = 7
+	options.VUs = 3
+	options.Duration = time.Minute
+	options.Pause = 0
+	options.BatchAndSingle = true
+	options.SchemaCount = 2
+	options.EventsCount = 1
+
+	result, err := RunLoad(client, "loadstream", options)
+	require.NoError(t, err)
+	require.Equal(t, uint64(7), result.Iterations)
+	// A batch of 2 events, then each of them on its own.
+	require.Equal(t, uint64(7*3), result.Requests)
+	require.Equal(t, uint64(7*4), result.Events)
+	require.Equal(t, int64(result.Events), received.Load())
+	require.Less(t, result.Duration, time.Minute)
+}
+
+func TestRunLoadCountsErrors(t *testing.T) {
+	client, _ := loadTestServer(t, http.StatusInternalServerError)
+	options := DefaultLoadOptions()
+	options.VUs = 1
+	options.Duration = 100 * time.Millisecond
+
+	result, err := RunLoad(client, "loadstream", options)
+	require.NoError(t, err)
+	require.NotZero(t, result.Requests)
+	require.Equal(t, result.Requests, result.Errors)
+	require.Zero(t, result.Events)
+	require.Equal(t, 1.0, result.ErrorRate())
+}
+
+func TestRunLoadInvalidOptions(t *testing.T) {
+	options := DefaultLoadOptions()
+	options.Executor = ConstantArrivalRate
+	_, err := RunLoad(HTTPClient{}, "loadstream", options)
+	require.Error(t, err)
+
+	options.Executor = SharedIterations
+	_, err = RunLoad(HTTPClient{}, "loadstream", options)
+	require.ErrorContains(t, err, "iterations")
+}
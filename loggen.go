@@ -0,0 +1,176 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Formats `FormatFlog` writes events in, like flog's `--format`.
+const (
+	LogFormatApacheCommon   = "apache-common"
+	LogFormatApacheCombined = "apache-combined"
+	LogFormatRFC3164        = "rfc3164"
+	LogFormatRFC5424        = "rfc5424"
+	LogFormatJSON           = "json"
+)
+
+// Layout of `Flog.Timestamp`, as in the common log format.
+const flogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+var (
+	flogMethods   = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	flogProtocols = []string{"HTTP/1.0", "HTTP/1.1", "HTTP/2.0"}
+	// Repeated statuses come up more often.
+	flogStatuses = []uint16{200, 200, 200, 200, 201, 204, 301, 302, 304, 400, 401, 403, 404, 404, 405, 500, 501, 502, 503}
+	flogWords    = []string{"alpha", "api", "archive", "billing", "cart", "cloud", "data", "docs", "edge", "files", "grid", "health", "index", "login", "metrics", "orders", "portal", "search", "static", "users"}
+	flogTLDs     = []string{"com", "net", "org", "io", "dev"}
+	flogAgents   = []string{
+		"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36",
+		"curl/8.4.0",
+		"Go-http-client/1.1",
+	}
+)
+
+// Generates flog-like access log events in process. The same seed always
+// gives the same events; only their times follow the start time.
+type LogGenerator struct {
+	seed int64
+	r    *rand.Rand
+	now  time.Time
+}
+
+// A generator seeded with `seed`, or a random seed when it's 0, whose events
+// are a few milliseconds apart from `start` on.
+func NewLogGenerator(seed int64, start time.Time) *LogGenerator {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &LogGenerator{seed: seed, r: rand.New(rand.NewSource(seed)), now: start}
+}
+
+// Seed to pass to `NewLogGenerator`, or `-log-seed`, to replay the events.
+func (g *LogGenerator) Seed() int64 {
+	return g.seed
+}
+
+func (g *LogGenerator) pick(items []string) string {
+	return items[g.r.Intn(len(items))]
+}
+
+func (g *LogGenerator) path() string {
+	segments := make([]string, 1+g.r.Intn(3))
+	for i := range segments {
+		segments[i] = g.pick(flogWords)
+	}
+	path := "/" + strings.Join(segments, "/")
+	if g.r.Intn(3) == 0 {
+		path += g.pick([]string{".html", ".php", ".json", ".png"})
+	}
+	return path
+}
+
+// Next event.
+func (g *LogGenerator) Flog() Flog {
+	g.now = g.now.Add(time.Duration(1+g.r.Intn(50)) * time.Millisecond)
+	flog := Flog{
+		Host:      fmt.Sprintf("%d.%d.%d.%d", 1+g.r.Intn(254), g.r.Intn(256), g.r.Intn(256), 1+g.r.Intn(254)),
+		UserId:    "-",
+		Timestamp: g.now.Format(flogTimeLayout),
+		Method:    g.pick(flogMethods),
+		Request:   g.path(),
+		Protocol:  g.pick(flogProtocols),
+		Status:    flogStatuses[g.r.Intn(len(flogStatuses))],
+		ByteCount: uint64(g.r.Intn(50000)),
+		Referer:   "-",
+		UserAgent: g.pick(flogAgents),
+	}
+	if g.r.Intn(2) == 0 {
+		flog.UserId = fmt.Sprintf("%s%d", g.pick(flogWords), g.r.Intn(10000))
+	}
+	if g.r.Intn(2) == 0 {
+		flog.Referer = fmt.Sprintf("https://www.%s.%s%s", g.pick(flogWords), g.pick(flogTLDs), g.path())
+	}
+	return flog
+}
+
+// Next `n` events.
+func (g *LogGenerator) Flogs(n int) []Flog {
+	flogs := make([]Flog, n)
+	for i := range flogs {
+		flogs[i] = g.Flog()
+	}
+	return flogs
+}
+
+// Next `n` events as lines of `format`.
+func (g *LogGenerator) Lines(format string, n int) ([]string, error) {
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := FormatFlog(format, g.Flog())
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Syslog severity of a response with `status`: error for 5xx, warning for
+// 4xx and informational otherwise, with the local0 facility.
+func syslogPriority(status uint16) int {
+	const local0 = 16
+	switch {
+	case status >= 500:
+		return local0*8 + 3
+	case status >= 400:
+		return local0*8 + 4
+	}
+	return local0*8 + 6
+}
+
+// `flog` as a line of `format`.
+func FormatFlog(format string, flog Flog) (string, error) {
+	request := fmt.Sprintf(`"%s %s %s" %d %d`, flog.Method, flog.Request, flog.Protocol, flog.Status, flog.ByteCount)
+	common := fmt.Sprintf("%s - %s [%s] %s", flog.Host, flog.UserId, flog.Timestamp, request)
+
+	switch format {
+	case LogFormatApacheCommon:
+		return common, nil
+	case LogFormatApacheCombined:
+		return fmt.Sprintf(`%s "%s" "%s"`, common, flog.Referer, flog.UserAgent), nil
+	case LogFormatRFC3164, LogFormatRFC5424:
+		at, err := time.Parse(flogTimeLayout, flog.Timestamp)
+		if err != nil {
+			return "", err
+		}
+		if format == LogFormatRFC3164 {
+			return fmt.Sprintf("<%d>%s %s quest: %s", syslogPriority(flog.Status), at.Format(time.Stamp), flog.Host, request), nil
+		}
+		return fmt.Sprintf("<%d>1 %s %s quest - - - %s", syslogPriority(flog.Status), at.Format("2006-01-02T15:04:05.000Z07:00"), flog.Host, request), nil
+	case LogFormatJSON:
+		line, err := json.Marshal(flog)
+		return string(line), err
+	}
+	return "", fmt.Errorf("unknown log format %q", format)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+var logStart = time.Date(2024, 5, 10, 3, 15, 0, 0, time.UTC)
+
+func TestLogGeneratorReplay(t *testing.T) {
+	flogs := NewLogGenerator(42, logStart).Flogs(100)
+	require.Equal(t, flogs, NewLogGenerator(42, logStart).Flogs(100))
+	require.NotEqual(t, flogs, NewLogGenerator(43, logStart).Flogs(100))
+
+	// Only the times follow the start.
+	later := NewLogGenerator(42, logStart.Add(time.Hour)).Flogs(100)
+	for i := range flogs {
+		require.NotEqual(t, flogs[i].Timestamp, later[i].Timestamp)
+		later[i].Timestamp = flogs[i].Timestamp
+	}
+	require.Equal(t, flogs, later)
+
+	generator := NewLogGenerator(0, logStart)
+	require.NotZero(t, generator.Seed())
+	require.Equal(t, generator.Flogs(10), NewLogGenerator(generator.Seed(), logStart).Flogs(10))
+}
+
+func TestLogGeneratorFlog(t *testing.T) {
+	var previous time.Time
+	for i, flog := range NewLogGenerator(7, logStart).Flogs(200) {
+		at, err := time.Parse(flogTimeLayout, flog.Timestamp)
+		require.NoError(t, err)
+		require.False(t, at.Before(previous), "event %d is before the previous one", i)
+		previous = at
+
+		require.Regexp(t, `^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`, flog.Host)
+		require.Contains(t, flogMethods, flog.Method)
+		require.Contains(t, flogProtocols, flog.Protocol)
+		require.Contains(t, flogStatuses, flog.Status)
+		require.Regexp(t, `^/[a-z]`, flog.Request)
+		require.Regexp(t, `^(-|https://www\.)`, flog.Referer)
+		require.NotEmpty(t, flog.UserId)
+		require.NotEmpty(t, flog.UserAgent)
+	}
+}
+
+func TestLogGeneratorFields(t *testing.T) {
+	data, err := json.Marshal(NewLogGenerator(1, logStart).Flog())
+	require.NoError(t, err)
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &event))
+	fields := make([]string, 0, len(event))
+	for field := range event {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	// The fields of `FlogJsonSchema`, less those the server adds.
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(FlogJsonSchema), &schema))
+	var expected []string
+	for _, field := range schema.Fields {
+		if !serverColumns[field.Name] {
+			expected = append(expected, field.Name)
+		}
+	}
+	sort.Strings(expected)
+	require.Equal(t, expected, fields)
+}
+
+func TestFormatFlog(t *testing.T) {
+	flog := Flog{
+		Host:      "10.0.0.1",
+		UserId:    "alice",
+		Timestamp: "10/May/2024:03:15:00 +0000",
+		Method:    "GET",
+		Request:   "/index.html",
+		Protocol:  "HTTP/1.1",
+		Status:    404,
+		ByteCount: 512,
+		Referer:   "https://www.example.com/",
+		UserAgent: "curl/8.4.0",
+	}
+	for format, expected := range map[string]string{
+		LogFormatApacheCommon:   `10.0.0.1 - alice [10/May/2024:03:15:00 +0000] "GET /index.html HTTP/1.1" 404 512`,
+		LogFormatApacheCombined: `10.0.0.1 - alice [10/May/2024:03:15:00 +0000] "GET /index.html HTTP/1.1" 404 512 "https://www.example.com/" "curl/8.4.0"`,
+		LogFormatRFC3164:        `<132>May 10 03:15:00 10.0.0.1 quest: "GET /index.html HTTP/1.1" 404 512`,
+		LogFormatRFC5424:        `<132>1 2024-05-10T03:15:00.000Z 10.0.0.1 quest - - - "GET /index.html HTTP/1.1" 404 512`,
+		LogFormatJSON:           `{"host":"10.0.0.1","user-identifier":"alice","datetime":"10/May/2024:03:15:00 +0000","method":"GET","request":"/index.html","protocol":"HTTP/1.1","status":404,"bytes":512,"referer":"https://www.example.com/"}`,
+	} {
+		line, err := FormatFlog(format, flog)
+		require.NoError(t, err, format)
+		require.Equal(t, expected, line, format)
+	}
+
+	flog.Status = 503
+	line, err := FormatFlog(LogFormatRFC5424, flog)
+	require.NoError(t, err)
+	require.Regexp(t, `^<131>1 `, line)
+
+	_, err = FormatFlog("apache-error", flog)
+	require.ErrorContains(t, err, "unknown log format")
+}
+
+func TestLogGeneratorLines(t *testing.T) {
+	patterns := map[string]*regexp.Regexp{
+		LogFormatApacheCommon:   regexp.MustCompile(`^\S+ - \S+ \[[^\]]+\] "[A-Z]+ \S+ HTTP/\d\.\d" \d{3} \d+$`),
+		LogFormatApacheCombined: regexp.MustCompile(`^\S+ - \S+ \[[^\]]+\] "[A-Z]+ \S+ HTTP/\d\.\d" \d{3} \d+ "[^"]+" "[^"]+"$`),
+		LogFormatRFC3164:        regexp.MustCompile(`^<1[2-3]\d>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2} \S+ quest: `),
+		LogFormatRFC5424:        regexp.MustCompile(`^<1[2-3]\d>1 \d{4}-\d{2}-\d{2}T\S+ \S+ quest - - - `),
+		LogFormatJSON:           regexp.MustCompile(`^\{.*\}$`),
+	}
+	for format, pattern := range patterns {
+		lines, err := NewLogGenerator(3, logStart).Lines(format, 50)
+		require.NoError(t, err)
+		require.Len(t, lines, 50, format)
+		for _, line := range lines {
+			require.Regexp(t, pattern, line, format)
+		}
+	}
+	_, err := NewLogGenerator(3, logStart).Lines("xml", 1)
+	require.Error(t, err)
+}
+
+func TestIngestFlogs(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	require.NoError(t, client.API().CreateStream("app", parseable.StreamOptions{}))
+
+	flogs := NewLogGenerator(5, time.Now()).Flogs(50)
+	run := ingestFlogs(t, NewIngestorPool(RoundRobin, 1, client), "app", flogs)
+	require.Len(t, run.Events, 50)
+	require.Equal(t, uint64(50), sumCounts(run.Accepted))
+
+	events := fake.Events("app")
+	require.Len(t, events, 50, "Every generated event is sent once, and nothing else")
+	for i, event := range events {
+		require.Equal(t, flogs[i].Request, event["request"])
+	}
+}
@@ -18,26 +18,84 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"net/url"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func main() {
-	println("hello")
+	flag.Usage = usage
+	os.Exit(runCommand(flag.Args()))
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: quest [flags] <command>\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.help)
+	}
+	fmt.Fprintf(out, "\nFlags (the -test.* flags of `go test` are also accepted):\n")
+
+	// Leave out the `-test.*` flags registered by `testing.Init`, they
+	// would drown out ours.
+	ours := flag.NewFlagSet("quest", flag.ContinueOnError)
+	ours.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") {
+			ours.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	ours.PrintDefaults()
 }
 
 type Glob struct {
-	QueryUrl         url.URL
-	QueryUsername    string
-	QueryPassword    string
+	QueryUrl      url.URL
+	QueryUsername string
+	QueryPassword string
+	// First of the configured ingestors, if any.
 	IngestorUrl      url.URL
 	IngestorUsername string
 	IngestorPassword string
 	Stream           string
 	QueryClient      HTTPClient
 	IngestorClient   HTTPClient
-	Mode             string
+	// Nodes events are sent to: the ingestors when configured, the query node
+	// otherwise.
+	Ingestors *IngestorPool
+	Mode      string
 	MinIoConfig
+	// Where Parseable keeps stream data, built from `MinIoConfig` or the
+	// local store directory.
+	Store ObjectStore
+	// Baseline load results are checked against, nil when not configured.
+	Perf *PerfGate
+	// Settings the above were built from.
+	Config Config
+}
+
+// Client events should be sent through when a single one is needed: the first
+// ingestor when one is configured, the query node otherwise.
+func (g *Glob) IngestClient() HTTPClient {
+	if g.IngestorUrl.String() == "" {
+		return g.QueryClient
+	}
+	return g.IngestorClient
+}
+
+// Copy of the glob whose clients record their requests as `t`'s, see
+// `ObserveClient`.
+func (g Glob) For(t *testing.T) Glob {
+	g.QueryClient = ObserveClient(t, g.QueryClient)
+	g.IngestorClient = ObserveClient(t, g.IngestorClient)
+	clients := make([]HTTPClient, 0, len(g.Ingestors.Clients))
+	for _, client := range g.Ingestors.Clients {
+		clients = append(clients, ObserveClient(t, client))
+	}
+	g.Ingestors = NewIngestorPool(g.Ingestors.Distribution, time.Now().UnixNano(), clients...)
+	return g
 }
 
 type MinIoConfig struct {
@@ -45,91 +103,39 @@ type MinIoConfig struct {
 	User   string
 	Pass   string
 	Bucket string
+	TLS    bool
+	// PEM file of the CA to trust besides the system ones.
+	CACert string
+	// `auto`, `path` or `virtual-host`.
+	BucketLookup string
+	Region       string
 }
 
 var NewGlob = func() Glob {
 	testing.Init()
-	var targetQueryUrl string
-	var queryUsername string
-	var queryPassword string
-
-	var targetIngestorUrl string
-	var ingestorUsername string
-	var ingestorPassword string
-
-	var stream string
-	var mode string
-	// XXX
-	var minioUrl string
-	var minioUser string
-	var minioPass string
-	var minioBucket string
-
-	flag.StringVar(&targetQueryUrl, "query-url", "http://localhost:8000", "Specify url. Default is root")
-	flag.StringVar(&queryUsername, "query-user", "admin", "Specify username. Default is admin")
-	flag.StringVar(&queryPassword, "query-pass", "admin", "Specify pass. Default is admin")
-
-	flag.StringVar(&targetIngestorUrl, "ingestor-url", "", "Specify url. Default is root")
-	flag.StringVar(&ingestorUsername, "ingestor-user", "admin", "Specify username. Default is admin")
-	flag.StringVar(&ingestorPassword, "ingestor-pass", "admin", "Specify pass. Default is admin")
-
-	flag.StringVar(&stream, "stream", "app", "Specify stream. Default is app")
-	flag.StringVar(&mode, "mode", "smoke", "Specify mode. Default is smoke")
-
-	flag.StringVar(&minioUrl, "minio-url", "localhost:9000", "Specify MinIO URL. Default is localhost:9000")
-	flag.StringVar(&minioUser, "minio-user", "minioadmin", "Specify MinIO User. Default is `minioadmin`")
-	flag.StringVar(&minioPass, "minio-pass", "minioadmin", "Specify MinIO Password. Default is `minioadmin`")
-	flag.StringVar(&minioBucket, "minio-bucket", "parseable", "Specify the name of MinIO Bucket. Default is `integrity-test`")
-
+	var flags Config
+	bindConfigFlags(flag.CommandLine, &flags)
+	configPath := flag.String("config", "", "Path of a YAML or JSON config file. Can also be set with QUEST_CONFIG")
+	profile := flag.String("profile", "", "Profile of the config file to use. Can also be set with QUEST_PROFILE")
 	flag.Parse()
 
-	parsedQueryTargetUrl, err := url.Parse(targetQueryUrl)
-	if err != nil {
-		panic("Could not parse url")
-	}
-
-	queryClient := DefaultClient(*parsedQueryTargetUrl, queryUsername, queryPassword)
-
-	if targetIngestorUrl != "" {
-		parsedIngestorTargetUrl, err := url.Parse(targetIngestorUrl)
-		if err != nil {
-			panic("Could not parse url")
+	// Only the flags given on the command line override the config file
+	// and the environment.
+	given := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "config" && f.Name != "profile" && !strings.HasPrefix(f.Name, "test.") {
+			given[f.Name] = f.Value.String()
 		}
+	})
 
-		ingestorClient := DefaultClient(*parsedIngestorTargetUrl, ingestorUsername, ingestorPassword)
-		return Glob{
-			QueryUrl:         *parsedQueryTargetUrl,
-			QueryUsername:    queryUsername,
-			QueryPassword:    queryPassword,
-			QueryClient:      queryClient,
-			IngestorUrl:      *parsedIngestorTargetUrl,
-			IngestorUsername: ingestorUsername,
-			IngestorPassword: ingestorPassword,
-			IngestorClient:   ingestorClient,
-			Stream:           stream,
-			Mode:             mode,
-			MinIoConfig: MinIoConfig{
-				Url:    minioUrl,
-				User:   minioUser,
-				Pass:   minioPass,
-				Bucket: minioBucket,
-			},
-		}
-	} else {
-		return Glob{
-			QueryUrl:      *parsedQueryTargetUrl,
-			QueryUsername: queryUsername,
-			QueryPassword: queryPassword,
-			QueryClient:   queryClient,
-			Stream:        stream,
-			Mode:          mode,
-			MinIoConfig: MinIoConfig{
-				Url:    minioUrl,
-				User:   minioUser,
-				Pass:   minioPass,
-				Bucket: minioBucket,
-			},
-		}
+	var glob Glob
+	config, err := LoadConfig(*configPath, *profile, os.LookupEnv, given)
+	if err == nil {
+		glob, err = NewGlobFromConfig(config)
 	}
-
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quest: invalid configuration:\n%s\n", err)
+		os.Exit(exitUsage)
+	}
+	return glob
 }()
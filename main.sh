@@ -39,9 +39,18 @@ ingestor_username=${13}
 ingestor_password=${14}
 stream_name=$(head /dev/urandom | tr -dc a-z | head -c10)
 
+# Kept for existing callers using positional arguments, new ones should
+# call ./quest directly. As before, load runs the smoke tests first.
 run () {
-  ./quest.test -test.v -mode="$mode" -query-url="$endpoint" -stream="$stream_name" -query-user="$username" -query-pass="$password" -minio-url="$minio_url" -minio-user="$minio_access_key" -minio-pass="$minio_secret_key" -minio-bucket="$minio_bucket" -ingestor-url="$ingestor_endpoint" -ingestor-user="$ingestor_username" -ingestor-pass="$ingestor_password"
+  ./quest -query-url="$endpoint" -stream="$stream_name" -query-user="$username" -query-pass="$password" -minio-url="$minio_url" -minio-user="$minio_access_key" -minio-pass="$minio_secret_key" -minio-bucket="$minio_bucket" -ingestor-url="$ingestor_endpoint" -ingestor-user="$ingestor_username" -ingestor-pass="$ingestor_password" "$1"
   return $?
 }
 
-run
+if [ "$mode" = "load" ]; then
+  run smoke
+  smoke_status=$?
+  run load || exit $?
+  exit $smoke_status
+else
+  run "$mode"
+fi
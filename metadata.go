@@ -0,0 +1,301 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"quest/parseable"
+)
+
+// The parts of a stream's `.stream.json` that point to its data.
+type StreamMetadata struct {
+	Snapshot struct {
+		ManifestList []ManifestItem `json:"manifest_list"`
+	} `json:"snapshot"`
+}
+
+// A manifest in the snapshot of a stream.
+type ManifestItem struct {
+	ManifestPath   string `json:"manifest_path"`
+	TimeLowerBound string `json:"time_lower_bound"`
+	TimeUpperBound string `json:"time_upper_bound"`
+	EventsIngested uint64 `json:"events_ingested"`
+	IngestionSize  uint64 `json:"ingestion_size"`
+	StorageSize    uint64 `json:"storage_size"`
+}
+
+// A `manifest.json`, listing the parquet files of a day.
+type Manifest struct {
+	Version string         `json:"version"`
+	Files   []ManifestFile `json:"files"`
+}
+
+type ManifestFile struct {
+	FilePath string `json:"file_path"`
+	NumRows  uint64 `json:"num_rows"`
+	FileSize uint64 `json:"file_size"`
+	Columns  []struct {
+		Name string `json:"name"`
+	} `json:"columns"`
+}
+
+// What is stored under a stream's prefix, keyed by object key.
+type StreamObjects struct {
+	// Size of every object.
+	Sizes       map[string]int64
+	StreamJSONs map[string]StreamMetadata
+	Manifests   map[string]Manifest
+	// Row counts in the footers of the parquet objects.
+	ParquetRows map[string]int64
+}
+
+func isStreamJSON(key string) bool {
+	return strings.HasSuffix(key, "stream.json")
+}
+
+func isManifest(key string) bool {
+	name := path.Base(key)
+	return strings.HasPrefix(name, "manifest") && strings.HasSuffix(name, ".json")
+}
+
+// A disagreement between a stream's metadata and its data, the API or
+// itself.
+type MetadataIssue struct {
+	Object string
+	Reason string
+}
+
+func (issue MetadataIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Object, issue.Reason)
+}
+
+// Leading number of a size the stats endpoint returns, e.g. `1024 Bytes`.
+func parseStatsSize(size string) (uint64, error) {
+	number, _, _ := strings.Cut(strings.TrimSpace(size), " ")
+	return strconv.ParseUint(number, 10, 64)
+}
+
+// Cross checks the metadata of a stream against its objects, `schema` and
+// `stats`:
+//   - every manifest a `.stream.json` lists exists and is listed once, with
+//     as many events as its files have rows
+//   - every file a manifest lists exists, with the size and footer row count
+//     the manifest has, and only columns of `schema`
+//   - every manifest and parquet object is listed
+//   - the stats count as many events and bytes as the manifests
+func checkStreamMetadata(store ObjectStore, objects StreamObjects, schema StreamSchema, stats parseable.Stats) []MetadataIssue {
+	var issues []MetadataIssue
+	issue := func(object string, format string, args ...interface{}) {
+		issues = append(issues, MetadataIssue{object, fmt.Sprintf(format, args...)})
+	}
+
+	listedManifests := make(map[string]string)
+	for _, key := range sortedKeys(objects.StreamJSONs) {
+		for _, item := range objects.StreamJSONs[key].Snapshot.ManifestList {
+			manifestKey := store.Key(item.ManifestPath)
+			if other, ok := listedManifests[manifestKey]; ok {
+				issue(key, "lists manifest %s, which %s lists too", manifestKey, other)
+				continue
+			}
+			listedManifests[manifestKey] = key
+			manifest, ok := objects.Manifests[manifestKey]
+			if !ok {
+				issue(key, "lists manifest %s, which doesn't exist", manifestKey)
+				continue
+			}
+			var rows uint64
+			for _, file := range manifest.Files {
+				rows += file.NumRows
+			}
+			if rows != item.EventsIngested {
+				issue(key, "counts %d events in manifest %s, whose files have %d rows", item.EventsIngested, manifestKey, rows)
+			}
+		}
+	}
+
+	listedFiles := make(map[string]string)
+	var manifestRows, manifestSize uint64
+	for _, key := range sortedKeys(objects.Manifests) {
+		if _, ok := listedManifests[key]; !ok {
+			issue(key, "isn't listed in any stream.json")
+		}
+		for _, file := range objects.Manifests[key].Files {
+			fileKey := store.Key(file.FilePath)
+			if other, ok := listedFiles[fileKey]; ok {
+				issue(key, "lists %s, which %s lists too", fileKey, other)
+				continue
+			}
+			listedFiles[fileKey] = key
+			manifestRows += file.NumRows
+			manifestSize += file.FileSize
+
+			size, ok := objects.Sizes[fileKey]
+			if !ok {
+				issue(key, "lists %s, which doesn't exist", fileKey)
+				continue
+			}
+			if uint64(size) != file.FileSize {
+				issue(key, "has %d bytes for %s, which has %d", file.FileSize, fileKey, size)
+			}
+			if rows, ok := objects.ParquetRows[fileKey]; ok && uint64(rows) != file.NumRows {
+				issue(key, "has %d rows for %s, whose footer has %d", file.NumRows, fileKey, rows)
+			}
+			for _, column := range file.Columns {
+				if _, ok := schema.Field(column.Name); !ok {
+					issue(key, "has column %s for %s, which isn't in the schema", column.Name, fileKey)
+				}
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(objects.ParquetRows) {
+		if _, ok := listedFiles[key]; !ok {
+			issue(key, "isn't listed in any manifest")
+		}
+	}
+
+	if stats.Ingestion.Count != manifestRows {
+		issue("stats", "count %d events, the manifests %d", stats.Ingestion.Count, manifestRows)
+	}
+	if size, err := parseStatsSize(stats.Storage.Size); err != nil {
+		issue("stats", "storage size %q: %s", stats.Storage.Size, err)
+	} else if size != manifestSize {
+		issue("stats", "count %d bytes in storage, the manifests %d", size, manifestSize)
+	}
+	return issues
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func readJSONObject(store ObjectStore, key string, v interface{}) error {
+	object, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// Lists the objects under the `stream` prefix, reading its metadata and the
+// footers of its parquet objects.
+func FetchStreamObjects(store ObjectStore, stream string) (StreamObjects, error) {
+	objects := StreamObjects{
+		Sizes:       map[string]int64{},
+		StreamJSONs: map[string]StreamMetadata{},
+		Manifests:   map[string]Manifest{},
+		ParquetRows: map[string]int64{},
+	}
+	listed, err := store.List(stream + "/")
+	if err != nil {
+		return objects, err
+	}
+	for _, object := range listed {
+		objects.Sizes[object.Key] = object.Size
+	}
+
+	for key := range objects.Sizes {
+		switch {
+		case isStreamJSON(key):
+			var metadata StreamMetadata
+			if err := readJSONObject(store, key, &metadata); err != nil {
+				return objects, err
+			}
+			objects.StreamJSONs[key] = metadata
+		case isManifest(key):
+			var manifest Manifest
+			if err := readJSONObject(store, key, &manifest); err != nil {
+				return objects, err
+			}
+			objects.Manifests[key] = manifest
+		}
+	}
+
+	var parquetKeys []string
+	for key := range objects.Sizes {
+		if isParquetFile(key) {
+			parquetKeys = append(parquetKeys, key)
+		}
+	}
+	objects.ParquetRows, err = readParquetObjects(parquetKeys, func(key string) (int64, error) {
+		return readParquetObjectRowCount(store, key)
+	})
+	return objects, err
+}
+
+// Longest list of issues a failure message has.
+const maxReportedIssues = 20
+
+// Lists `issues`, one per line, up to `maxReportedIssues`.
+func formatIssues[T fmt.Stringer](issues []T) string {
+	var report strings.Builder
+	for i, issue := range issues {
+		if i == maxReportedIssues {
+			fmt.Fprintf(&report, "\n... and %d more", len(issues)-i)
+			break
+		}
+		report.WriteString("\n" + issue.String())
+	}
+	return report.String()
+}
+
+// Waits until the metadata of `stream` in `store` agrees with its parquet
+// objects, schema and stats, failing with the disagreements if it doesn't.
+func VerifyStreamMetadata(t *testing.T, client HTTPClient, store ObjectStore, stream string) {
+	var issues []MetadataIssue
+	state, err := pollUntil(syncTimeout, func() (bool, string) {
+		schema, err := FetchStreamSchema(client, stream)
+		if err != nil {
+			return false, err.Error()
+		}
+		stats, err := client.API().Stats(stream)
+		if err != nil {
+			return false, err.Error()
+		}
+		objects, err := FetchStreamObjects(store, stream)
+		if err != nil {
+			return false, err.Error()
+		}
+		issues = checkStreamMetadata(store, objects, schema, stats)
+		return len(issues) == 0, fmt.Sprintf("%d issues", len(issues))
+	})
+	if err != nil {
+		t.Fatalf("Metadata of stream %s doesn't match its data after %s; last observed: %s%s",
+			stream, syncTimeout, state, formatIssues(issues))
+	}
+}
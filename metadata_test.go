@@ -0,0 +1,197 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A stream with one manifest of two parquet files, and everything agreeing.
+func consistentStreamObjects(t *testing.T) (StreamObjects, StreamSchema, parseable.Stats) {
+	var metadata StreamMetadata
+	require.NoError(t, json.Unmarshal([]byte(`{"snapshot": {"manifest_list": [
+		{"manifest_path": "s3://logs/app/date=2024-05-10/manifest.json", "events_ingested": 30}
+	]}}`), &metadata))
+	var manifest Manifest
+	require.NoError(t, json.Unmarshal([]byte(`{"version": "v1", "files": [
+		{"file_path": "app/date=2024-05-10/hour=03/minute=15/a.parquet", "num_rows": 10, "file_size": 100, "columns": [{"name": "host"}]},
+		{"file_path": "app/date=2024-05-10/hour=03/minute=16/b.parquet", "num_rows": 20, "file_size": 200, "columns": [{"name": "p_timestamp"}]}
+	]}`), &manifest))
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(FlogJsonSchema), &schema))
+	var stats parseable.Stats
+	stats.Ingestion.Count = 30
+	stats.Storage.Size = "300 Bytes"
+
+	objects := StreamObjects{
+		Sizes: map[string]int64{
+			"app/.stream/.stream.json":                        50,
+			"app/date=2024-05-10/manifest.json":               60,
+			"app/date=2024-05-10/hour=03/minute=15/a.parquet": 100,
+			"app/date=2024-05-10/hour=03/minute=16/b.parquet": 200,
+		},
+		StreamJSONs: map[string]StreamMetadata{"app/.stream/.stream.json": metadata},
+		Manifests:   map[string]Manifest{"app/date=2024-05-10/manifest.json": manifest},
+		ParquetRows: map[string]int64{
+			"app/date=2024-05-10/hour=03/minute=15/a.parquet": 10,
+			"app/date=2024-05-10/hour=03/minute=16/b.parquet": 20,
+		},
+	}
+	return objects, schema, stats
+}
+
+var logsStore = &FakeObjectStore{Bucket: "logs"}
+
+func requireIssues(t *testing.T, issues []MetadataIssue, expected ...string) {
+	t.Helper()
+	actual := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		actual = append(actual, issue.String())
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestCheckStreamMetadata(t *testing.T) {
+	objects, schema, stats := consistentStreamObjects(t)
+	require.Empty(t, checkStreamMetadata(logsStore, objects, schema, stats))
+
+	t.Run("dangling manifest", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		delete(objects.Manifests, "app/date=2024-05-10/manifest.json")
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/.stream/.stream.json: lists manifest app/date=2024-05-10/manifest.json, which doesn't exist",
+			"app/date=2024-05-10/hour=03/minute=15/a.parquet: isn't listed in any manifest",
+			"app/date=2024-05-10/hour=03/minute=16/b.parquet: isn't listed in any manifest",
+			"stats: count 30 events, the manifests 0",
+			"stats: count 300 bytes in storage, the manifests 0",
+		)
+	})
+
+	t.Run("unlisted manifest", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		objects.StreamJSONs["app/.stream/.stream.json"] = StreamMetadata{}
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/date=2024-05-10/manifest.json: isn't listed in any stream.json",
+		)
+	})
+
+	t.Run("dangling file", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		delete(objects.Sizes, "app/date=2024-05-10/hour=03/minute=16/b.parquet")
+		delete(objects.ParquetRows, "app/date=2024-05-10/hour=03/minute=16/b.parquet")
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/date=2024-05-10/manifest.json: lists app/date=2024-05-10/hour=03/minute=16/b.parquet, which doesn't exist",
+		)
+	})
+
+	t.Run("unlisted file", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		objects.Sizes["app/date=2024-05-10/hour=03/minute=17/c.parquet"] = 10
+		objects.ParquetRows["app/date=2024-05-10/hour=03/minute=17/c.parquet"] = 1
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/date=2024-05-10/hour=03/minute=17/c.parquet: isn't listed in any manifest",
+		)
+	})
+
+	t.Run("mismatched counts", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		objects.ParquetRows["app/date=2024-05-10/hour=03/minute=15/a.parquet"] = 9
+		objects.Sizes["app/date=2024-05-10/hour=03/minute=16/b.parquet"] = 201
+		objects.StreamJSONs["app/.stream/.stream.json"].Snapshot.ManifestList[0].EventsIngested = 31
+		stats.Ingestion.Count = 29
+		stats.Storage.Size = "lots"
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/.stream/.stream.json: counts 31 events in manifest app/date=2024-05-10/manifest.json, whose files have 30 rows",
+			"app/date=2024-05-10/manifest.json: has 10 rows for app/date=2024-05-10/hour=03/minute=15/a.parquet, whose footer has 9",
+			"app/date=2024-05-10/manifest.json: has 200 bytes for app/date=2024-05-10/hour=03/minute=16/b.parquet, which has 201",
+			"stats: count 29 events, the manifests 30",
+			`stats: storage size "lots": strconv.ParseUint: parsing "lots": invalid syntax`,
+		)
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		objects, schema, stats := consistentStreamObjects(t)
+		objects.Manifests["app/date=2024-05-10/manifest.json"].Files[0].Columns[0].Name = "hostname"
+		requireIssues(t, checkStreamMetadata(logsStore, objects, schema, stats),
+			"app/date=2024-05-10/manifest.json: has column hostname for app/date=2024-05-10/hour=03/minute=15/a.parquet, which isn't in the schema",
+		)
+	})
+}
+
+func TestMetadataObjectKinds(t *testing.T) {
+	require.True(t, isStreamJSON("app/.stream/.stream.json"))
+	require.True(t, isStreamJSON("app/.stream/.ingestor.abc.stream.json"))
+	require.True(t, isManifest("app/date=2024-05-10/manifest.json"))
+	require.True(t, isManifest("app/date=2024-05-10/manifest.ingestor.abc.json"))
+	require.False(t, isManifest("app/date=2024-05-10/hour=03/minute=15/a.parquet"))
+	require.False(t, isStreamJSON("app/.stream/.schema"))
+}
+
+func TestParseStatsSize(t *testing.T) {
+	size, err := parseStatsSize("1024 Bytes")
+	require.NoError(t, err)
+	require.Equal(t, uint64(1024), size)
+	_, err = parseStatsSize("")
+	require.Error(t, err)
+}
+
+func TestFetchStreamObjects(t *testing.T) {
+	objects, _, _ := consistentStreamObjects(t)
+	store := NewFakeObjectStore()
+	data, err := json.Marshal(objects.StreamJSONs["app/.stream/.stream.json"])
+	require.NoError(t, err)
+	store.Put("app/.stream/.stream.json", data)
+	data, err = json.Marshal(objects.Manifests["app/date=2024-05-10/manifest.json"])
+	require.NoError(t, err)
+	store.Put("app/date=2024-05-10/manifest.json", data)
+	parquet, err := os.ReadFile(writeFlogParquet(t, `{"p_timestamp": 1700000000000}`, `{"p_timestamp": 1700000000001}`, `{"status": 200}`))
+	require.NoError(t, err)
+	store.Put("app/date=2024-05-10/hour=03/minute=15/a.parquet", parquet)
+	store.Put("app/date=2024-05-10/hour=03/minute=16/b.parquet", parquet)
+
+	fetched, err := FetchStreamObjects(store, "app")
+	require.NoError(t, err)
+	require.Equal(t, objects.StreamJSONs, fetched.StreamJSONs)
+	require.Equal(t, objects.Manifests, fetched.Manifests)
+	require.Equal(t, map[string]int64{
+		"app/date=2024-05-10/hour=03/minute=15/a.parquet": 3,
+		"app/date=2024-05-10/hour=03/minute=16/b.parquet": 3,
+	}, fetched.ParquetRows)
+	require.Len(t, fetched.Sizes, 4)
+	require.Equal(t, int64(len(parquet)), fetched.Sizes["app/date=2024-05-10/hour=03/minute=15/a.parquet"])
+
+	store.Put("app/date=2024-05-10/hour=03/minute=17/c.parquet", []byte("not parquet"))
+	_, err = FetchStreamObjects(store, "app")
+	require.ErrorContains(t, err, "c.parquet")
+}
+
+func TestFormatIssues(t *testing.T) {
+	issues := make([]MetadataIssue, maxReportedIssues+5)
+	for i := range issues {
+		issues[i] = MetadataIssue{"app/x.parquet", "isn't listed in any manifest"}
+	}
+	report := formatIssues(issues)
+	require.Equal(t, maxReportedIssues+1, strings.Count(report, "\n"))
+	require.True(t, strings.HasSuffix(report, "\n... and 5 more"), report)
+}
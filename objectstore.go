@@ -0,0 +1,256 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+)
+
+// An object under a stream's prefix.
+type StoredObject struct {
+	Key  string
+	Size int64
+}
+
+// An object being read. Reads after a seek only fetch what they need.
+type ObjectReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Where Parseable keeps the data of streams: an S3 compatible bucket, or
+// the data directory of its local store.
+type ObjectStore interface {
+	// Objects under `prefix`, recursively, sorted by key.
+	List(prefix string) ([]StoredObject, error)
+	Get(key string) (ObjectReader, error)
+	// Key of the object a path or URL in Parseable's metadata refers to.
+	Key(ref string) string
+	// Checks that the store can be read.
+	Check() error
+	String() string
+}
+
+// Builds the object store `config` points to.
+func NewObjectStore(config Config) (ObjectStore, error) {
+	switch config.Store {
+	case "s3":
+		return NewS3Store(MinIoConfigOf(config))
+	case "local":
+		store := NewLocalStore(config.StoreDir)
+		if config.StoreServerDir != "" {
+			store.ServerDir = config.StoreServerDir
+		}
+		return store, nil
+	}
+	return nil, fmt.Errorf("unknown store %q, expected s3 or local", config.Store)
+}
+
+// Object key of `ref` relative to `root`, where `ref` can also be a URL,
+// e.g. `s3://{bucket}/{key}`.
+func metadataObjectKey(root string, ref string) string {
+	if _, rest, ok := strings.Cut(ref, "://"); ok {
+		ref = rest
+	}
+	ref = strings.TrimPrefix(ref, "/")
+	if rest, ok := strings.CutPrefix(ref, strings.Trim(root, "/")+"/"); ok {
+		ref = rest
+	}
+	return ref
+}
+
+var bucketLookups = map[string]minio.BucketLookupType{
+	"auto":         minio.BucketLookupAuto,
+	"path":         minio.BucketLookupPath,
+	"virtual-host": minio.BucketLookupDNS,
+}
+
+// A bucket of MinIO or another S3 compatible store.
+type S3Store struct {
+	client   *minio.Client
+	endpoint string
+	bucket   string
+}
+
+func NewS3Store(config MinIoConfig) (*S3Store, error) {
+	lookup, ok := bucketLookups[config.BucketLookup]
+	if !ok && config.BucketLookup != "" {
+		return nil, fmt.Errorf("unknown bucket lookup %q, expected auto, path or virtual-host", config.BucketLookup)
+	}
+	client, err := minio.NewWithOptions(config.Url, &minio.Options{
+		Creds:        credentials.NewStaticV4(config.User, config.Pass, ""),
+		Secure:       config.TLS,
+		Region:       config.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if config.CACert != "" {
+		pool, err := loadCACert(config.CACert)
+		if err != nil {
+			return nil, err
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		client.SetCustomTransport(transport)
+	}
+	return &S3Store{client: client, endpoint: config.Url, bucket: config.Bucket}, nil
+}
+
+// The system roots along with the PEM certificates in `path`.
+func loadCACert(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+func (store *S3Store) List(prefix string) ([]StoredObject, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	objects := make([]StoredObject, 0, 10)
+	for objectInfo := range store.client.ListObjectsV2(store.bucket, prefix, true, done) {
+		if objectInfo.Err != nil {
+			return objects, objectInfo.Err
+		}
+		objects = append(objects, StoredObject{objectInfo.Key, objectInfo.Size})
+	}
+	return objects, nil
+}
+
+func (store *S3Store) Get(key string) (ObjectReader, error) {
+	object, err := store.client.GetObject(store.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (store *S3Store) Key(ref string) string {
+	return metadataObjectKey(store.bucket, ref)
+}
+
+func (store *S3Store) Check() error {
+	exists, err := store.client.BucketExists(store.bucket)
+	if err == nil && !exists {
+		err = fmt.Errorf("bucket %s does not exist", store.bucket)
+	}
+	return err
+}
+
+func (store *S3Store) String() string {
+	return fmt.Sprintf("bucket %s at %s", store.bucket, store.endpoint)
+}
+
+// The data directory of Parseable's local store, `--fs-dir`, which must be
+// mounted where quest runs.
+type LocalStore struct {
+	Dir string
+	// Where Parseable sees `Dir`, which the paths in its metadata start
+	// with.
+	ServerDir string
+}
+
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{Dir: dir, ServerDir: dir}
+}
+
+func (store *LocalStore) List(prefix string) ([]StoredObject, error) {
+	// `prefix` can end within a file name, as it can on S3.
+	dir := filepath.Join(store.Dir, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+	objects := make([]StoredObject, 0, 10)
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(store.Dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StoredObject{key, info.Size()})
+		return nil
+	})
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, err
+}
+
+func (store *LocalStore) Get(key string) (ObjectReader, error) {
+	file, err := os.Open(filepath.Join(store.Dir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (store *LocalStore) Key(ref string) string {
+	root, err := filepath.Abs(store.ServerDir)
+	if err != nil {
+		root = store.ServerDir
+	}
+	return metadataObjectKey(filepath.ToSlash(root), ref)
+}
+
+func (store *LocalStore) Check() error {
+	info, err := os.Stat(store.Dir)
+	if err == nil && !info.IsDir() {
+		err = fmt.Errorf("%s is not a directory", store.Dir)
+	}
+	return err
+}
+
+func (store *LocalStore) String() string {
+	return "directory " + store.Dir
+}
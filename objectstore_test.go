@@ -0,0 +1,214 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataObjectKey(t *testing.T) {
+	for ref, expected := range map[string]string{
+		"app/date=2024-05-10/manifest.json":              "app/date=2024-05-10/manifest.json",
+		"s3://logs/app/date=2024-05-10/manifest.json":    "app/date=2024-05-10/manifest.json",
+		"/logs/app/date=2024-05-10/manifest.json":        "app/date=2024-05-10/manifest.json",
+		"file:///data/app/date=2024-05-10/manifest.json": "data/app/date=2024-05-10/manifest.json",
+	} {
+		require.Equal(t, expected, metadataObjectKey("logs", ref), ref)
+	}
+	require.Equal(t, "app/manifest.json", metadataObjectKey("/parseable/data", "file:///parseable/data/app/manifest.json"))
+}
+
+func readObject(t *testing.T, store ObjectStore, key string) string {
+	object, err := store.Get(key)
+	require.NoError(t, err)
+	defer object.Close()
+	data, err := io.ReadAll(object)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestLocalStore(t *testing.T) {
+	dir := t.TempDir()
+	for key, data := range map[string]string{
+		"app/.stream/.stream.json":                        "{}",
+		"app/date=2024-05-10/hour=03/minute=15/a.parquet": "abc",
+		"application/date=2024-05-10/b.parquet":           "de",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	store := NewLocalStore(dir)
+	require.NoError(t, store.Check())
+
+	objects, err := store.List("app/")
+	require.NoError(t, err)
+	require.Equal(t, []StoredObject{
+		{"app/.stream/.stream.json", 2},
+		{"app/date=2024-05-10/hour=03/minute=15/a.parquet", 3},
+	}, objects)
+
+	objects, err = store.List("app")
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+
+	objects, err = store.List("missing/")
+	require.NoError(t, err)
+	require.Empty(t, objects)
+
+	require.Equal(t, "abc", readObject(t, store, "app/date=2024-05-10/hour=03/minute=15/a.parquet"))
+	require.Equal(t, "app/date=2024-05-10/manifest.json", store.Key("file://"+filepath.ToSlash(dir)+"/app/date=2024-05-10/manifest.json"))
+
+	require.Error(t, NewLocalStore(filepath.Join(dir, "missing")).Check())
+
+	// Parseable's --fs-dir mounted elsewhere for quest.
+	store.ServerDir = "/parseable/data"
+	require.Equal(t, "app/date=2024-05-10/manifest.json", store.Key("/parseable/data/app/date=2024-05-10/manifest.json"))
+	require.Equal(t, "abc", readObject(t, store, store.Key("/parseable/data/app/date=2024-05-10/hour=03/minute=15/a.parquet")))
+}
+
+// Answers the requests minio-go sends to list and get objects of the `logs`
+// bucket, path style.
+func fakeS3Handler(objects map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			fmt.Fprint(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+			return
+		}
+		if r.URL.Path == "/logs/" || r.URL.Path == "/logs" {
+			prefix := r.URL.Query().Get("prefix")
+			var contents strings.Builder
+			for key, data := range objects {
+				if strings.HasPrefix(key, prefix) {
+					fmt.Fprintf(&contents, "<Contents><Key>%s</Key><Size>%d</Size></Contents>", key, len(data))
+				}
+			}
+			fmt.Fprintf(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>logs</Name><Prefix>%s</Prefix><MaxKeys>1000</MaxKeys><IsTruncated>false</IsTruncated>%s</ListBucketResult>`, prefix, contents.String())
+			return
+		}
+		data, ok := objects[strings.TrimPrefix(r.URL.Path, "/logs/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		fmt.Fprint(w, data)
+	}
+}
+
+func TestS3StoreTLS(t *testing.T) {
+	server := httptest.NewTLSServer(fakeS3Handler(map[string]string{"app/a.parquet": "abc"}))
+	defer server.Close()
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caCert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o644))
+	config := MinIoConfig{
+		Url:          strings.TrimPrefix(server.URL, "https://"),
+		User:         "minioadmin",
+		Pass:         "minioadmin",
+		Bucket:       "logs",
+		TLS:          true,
+		CACert:       caCert,
+		BucketLookup: "path",
+		Region:       "us-east-1",
+	}
+
+	store, err := NewS3Store(config)
+	require.NoError(t, err)
+	objects, err := store.List("app/")
+	require.NoError(t, err)
+	require.Equal(t, []StoredObject{{"app/a.parquet", 3}}, objects)
+	require.Equal(t, "abc", readObject(t, store, "app/a.parquet"))
+	require.Equal(t, "app/a.parquet", store.Key("s3://logs/app/a.parquet"))
+
+	// Without the CA the server's certificate isn't trusted.
+	config.CACert = ""
+	untrusted, err := NewS3Store(config)
+	require.NoError(t, err)
+	_, err = untrusted.List("app/")
+	require.ErrorContains(t, err, "certificate")
+
+	config.BucketLookup = "dns"
+	_, err = NewS3Store(config)
+	require.Error(t, err)
+}
+
+func TestNewObjectStore(t *testing.T) {
+	store, err := NewObjectStore(Config{Store: "local", StoreDir: "/data"})
+	require.NoError(t, err)
+	require.Equal(t, "directory /data", store.String())
+	require.Equal(t, "app/manifest.json", store.Key("/data/app/manifest.json"))
+
+	store, err = NewObjectStore(Config{Store: "local", StoreDir: "/data", StoreServerDir: "/parseable/data"})
+	require.NoError(t, err)
+	require.Equal(t, "app/manifest.json", store.Key("/parseable/data/app/manifest.json"))
+
+	store, err = NewObjectStore(Config{Store: "s3", MinioUrl: "minio:9000", MinioBucket: "logs", MinioBucketLookup: "auto"})
+	require.NoError(t, err)
+	require.Equal(t, "bucket logs at minio:9000", store.String())
+
+	_, err = NewObjectStore(Config{Store: "gcs"})
+	require.Error(t, err)
+}
+
+func TestParquetObjectsOfStore(t *testing.T) {
+	store := NewFakeObjectStore()
+	store.Put("app/date=2024-05-10/hour=03/minute=15/a.parquet", []byte("abc"))
+	store.Put("app/date=2024-05-10/manifest.json", []byte("{}"))
+	store.Put("other/date=2024-05-10/hour=03/minute=15/b.parquet", []byte("de"))
+
+	keys, err := listParquetObjects("app", store)
+	require.NoError(t, err)
+	require.Equal(t, []string{"app/date=2024-05-10/hour=03/minute=15/a.parquet"}, keys)
+	require.Equal(t, keys, WaitForParquetObjects(t, store, "app", 1, 0))
+
+}
+
+func TestValidateObjectLayoutOfStore(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	require.NoError(t, client.API().CreateStream("app", parseable.StreamOptions{Header: map[string]string{"X-P-Custom-Partition": "host"}}))
+	fakeIngest(t, client, "app", []map[string]interface{}{{"host": "10.0.0.1"}})
+
+	// 1700000000000 is 2023-11-14T22:13:20Z.
+	parquet, err := os.ReadFile(writeFlogParquet(t, `{"p_timestamp": 1700000000000, "host": "10.0.0.1"}`))
+	require.NoError(t, err)
+	store := NewFakeObjectStore()
+	store.Put("app/date=2023-11-14/hour=22/minute=13/host=10.0.0.1/a.parquet", parquet)
+	violations, err := ValidateObjectLayout(client, store, "app")
+	require.NoError(t, err)
+	require.Empty(t, violations)
+
+	store.Put("app/date=2023-11-14/hour=22/minute=14/host=10.0.0.2/b.parquet", parquet)
+	violations, err = ValidateObjectLayout(client, store, "app")
+	require.NoError(t, err)
+	require.Len(t, violations, 2)
+	require.Contains(t, violations[0].Reason, "outside")
+	require.Equal(t, "host is 10.0.0.1", violations[1].Reason)
+}
@@ -0,0 +1,410 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quest/parseable"
+)
+
+// An event as the harness ingested it. The server stamps `p_timestamp` on
+// the event somewhere between `Sent` and `Acked`.
+type ModelEvent struct {
+	Fields Record
+	Sent   time.Time
+	Acked  time.Time
+}
+
+// What a batch of ingestion sent and where it went.
+type IngestRun struct {
+	// Events accepted by each ingestor of the pool, indexed like `Clients`.
+	Accepted []uint64
+	Events   []ModelEvent
+}
+
+// In-memory copy of the events ingested into each stream, to compute what
+// queries over them should return. Safe for concurrent use.
+type EventModel struct {
+	mu      sync.Mutex
+	streams map[string][]ModelEvent
+}
+
+func NewEventModel() *EventModel {
+	return &EventModel{streams: make(map[string][]ModelEvent)}
+}
+
+func (model *EventModel) Add(stream string, events ...ModelEvent) {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	model.streams[stream] = append(model.streams[stream], events...)
+}
+
+func (model *EventModel) Events(stream string) []ModelEvent {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	return append([]ModelEvent{}, model.streams[stream]...)
+}
+
+// Drops the events of `stream`, e.g. once it's deleted.
+func (model *EventModel) Forget(stream string) {
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	delete(model.streams, stream)
+}
+
+type Row = map[string]interface{}
+
+// A query whose result is computed from the events ingested.
+type OracleQuery struct {
+	Name string
+	// SQL with a `%s` for the stream.
+	SQL string
+	// The rows the query returns over `events`, in order. False when they
+	// can't be predicted.
+	Expected func(events []ModelEvent) ([]Row, bool)
+	// Describes what is wrong with the `rows` the query returned over
+	// `events`, or returns "" if they are right. For queries whose rows
+	// depend on `p_timestamp`, used instead of `Expected`.
+	Check func(events []ModelEvent, rows []Row) string
+}
+
+func eventInt(event ModelEvent, field string) int64 {
+	v, _ := toInt64(event.Fields[field])
+	return v
+}
+
+func eventString(event ModelEvent, field string) string {
+	s, _ := event.Fields[field].(string)
+	return s
+}
+
+func countWhere(events []ModelEvent, keep func(ModelEvent) bool) int64 {
+	var count int64
+	for _, e := range events {
+		if keep(e) {
+			count++
+		}
+	}
+	return count
+}
+
+// Groups `events` by method, in the order of the methods.
+func byMethod(events []ModelEvent) ([]string, map[string][]ModelEvent) {
+	groups := make(map[string][]ModelEvent)
+	for _, e := range events {
+		method := eventString(e, "method")
+		groups[method] = append(groups[method], e)
+	}
+	methods := make([]string, 0, len(groups))
+	for method := range groups {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods, groups
+}
+
+type intStats struct{ min, max, sum int64 }
+
+func statsOf(events []ModelEvent, field string) intStats {
+	var s intStats
+	for i, e := range events {
+		v := eventInt(e, field)
+		if i == 0 || v < s.min {
+			s.min = v
+		}
+		if i == 0 || v > s.max {
+			s.max = v
+		}
+		s.sum += v
+	}
+	return s
+}
+
+// Queries over flog events checked by `AssertOracleQueries`.
+func FlogOracleQueries() []OracleQuery {
+	return []OracleQuery{
+		{
+			Name: "Count",
+			SQL:  "SELECT COUNT(*) AS count FROM %s",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				return []Row{{"count": len(events)}}, true
+			},
+		},
+		{
+			Name: "GroupByMethod",
+			SQL:  "SELECT method, COUNT(*) AS count FROM %s GROUP BY method ORDER BY method",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				methods, groups := byMethod(events)
+				rows := make([]Row, 0, len(methods))
+				for _, method := range methods {
+					rows = append(rows, Row{"method": method, "count": len(groups[method])})
+				}
+				return rows, true
+			},
+		},
+		{
+			Name: "WhereStatus",
+			SQL:  "SELECT COUNT(*) AS count FROM %s WHERE status >= 400",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				return []Row{{"count": countWhere(events, func(e ModelEvent) bool { return eventInt(e, "status") >= 400 })}}, true
+			},
+		},
+		{
+			Name: "WhereMethodAndBytes",
+			SQL:  "SELECT COUNT(*) AS count FROM %s WHERE method = 'GET' AND bytes > 10000",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				return []Row{{"count": countWhere(events, func(e ModelEvent) bool {
+					return eventString(e, "method") == "GET" && eventInt(e, "bytes") > 10000
+				})}}, true
+			},
+		},
+		{
+			Name:  "DateTruncMinute",
+			SQL:   "SELECT DATE_TRUNC('minute', p_timestamp) AS minute, COUNT(*) AS count FROM %s GROUP BY minute ORDER BY minute",
+			Check: checkMinuteCounts,
+		},
+		{
+			Name: "MinMaxSum",
+			SQL: "SELECT MIN(status) AS min_status, MAX(status) AS max_status, SUM(status) AS sum_status, " +
+				"MIN(bytes) AS min_bytes, MAX(bytes) AS max_bytes, SUM(bytes) AS sum_bytes FROM %s",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				if len(events) == 0 {
+					return []Row{{"min_status": nil, "max_status": nil, "sum_status": nil, "min_bytes": nil, "max_bytes": nil, "sum_bytes": nil}}, true
+				}
+				status, bytes := statsOf(events, "status"), statsOf(events, "bytes")
+				return []Row{{
+					"min_status": status.min, "max_status": status.max, "sum_status": status.sum,
+					"min_bytes": bytes.min, "max_bytes": bytes.max, "sum_bytes": bytes.sum,
+				}}, true
+			},
+		},
+		{
+			Name: "GroupByMethodAggregates",
+			SQL:  "SELECT method, MAX(status) AS max_status, SUM(bytes) AS sum_bytes FROM %s GROUP BY method ORDER BY method",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				methods, groups := byMethod(events)
+				rows := make([]Row, 0, len(methods))
+				for _, method := range methods {
+					rows = append(rows, Row{
+						"method":     method,
+						"max_status": statsOf(groups[method], "status").max,
+						"sum_bytes":  statsOf(groups[method], "bytes").sum,
+					})
+				}
+				return rows, true
+			},
+		},
+		{
+			Name: "OffsetLimit",
+			SQL:  "SELECT host, method, request, status, bytes FROM %s ORDER BY host, method, request, status, bytes LIMIT 10 OFFSET 20",
+			Expected: func(events []ModelEvent) ([]Row, bool) {
+				rows := make([]Row, 0, len(events))
+				for _, e := range events {
+					rows = append(rows, Row{
+						"host":    eventString(e, "host"),
+						"method":  eventString(e, "method"),
+						"request": eventString(e, "request"),
+						"status":  eventInt(e, "status"),
+						"bytes":   eventInt(e, "bytes"),
+					})
+				}
+				sort.SliceStable(rows, func(i, j int) bool {
+					a, b := rows[i], rows[j]
+					for _, key := range []string{"host", "method", "request"} {
+						if a[key] != b[key] {
+							return a[key].(string) < b[key].(string)
+						}
+					}
+					if a["status"] != b["status"] {
+						return a["status"].(int64) < b["status"].(int64)
+					}
+					return a["bytes"].(int64) < b["bytes"].(int64)
+				})
+				return window(rows, 20, 10), true
+			},
+		},
+	}
+}
+
+// Rows `offset` to `offset+limit` of `rows`.
+func window(rows []Row, offset int, limit int) []Row {
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	end := offset + limit
+	if end > len(rows) {
+		end = len(rows)
+	}
+	return rows[offset:end]
+}
+
+// Layouts timestamps come back from the query API in.
+var queryTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}
+
+// Parses a timestamp the query API returned.
+func parseQueryTime(value interface{}) (time.Time, bool) {
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range queryTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func valueMatches(expected interface{}, actual interface{}) bool {
+	switch e := expected.(type) {
+	case nil:
+		return actual == nil
+	case time.Time:
+		t, ok := parseQueryTime(actual)
+		return ok && t.Equal(e)
+	case int, int64, uint64, float64:
+		if actual == nil {
+			return false
+		}
+		a, err := toFloat64(actual)
+		if err != nil {
+			return false
+		}
+		want, _ := toFloat64(e)
+		// Float sums depend on the order values are added in.
+		return a == want || math.Abs(a-want) <= 1e-9*math.Max(math.Abs(a), math.Abs(want))
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+// Describes how `actual` differs from `expected`, or returns "" if the rows
+// match, in order.
+func diffRows(expected []Row, actual []Row) string {
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("expected %d rows, got %d: %v", len(expected), len(actual), actual)
+	}
+	for i := range expected {
+		var problems []string
+		for column, value := range expected[i] {
+			got, ok := actual[i][column]
+			if !ok && value != nil {
+				problems = append(problems, fmt.Sprintf("column %s missing", column))
+			} else if ok && !valueMatches(value, got) {
+				problems = append(problems, fmt.Sprintf("%s: expected %v, got %v", column, value, got))
+			}
+		}
+		for column := range actual[i] {
+			if _, ok := expected[i][column]; !ok {
+				problems = append(problems, fmt.Sprintf("unexpected column %s", column))
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			return fmt.Sprintf("row %d: %s", i, strings.Join(problems, ", "))
+		}
+	}
+	return ""
+}
+
+// Checks the `minute` and `count` rows of events per minute of `p_timestamp`.
+// The server stamps an event between its `Sent` and `Acked`, so it may be
+// counted in any minute from the one it was sent in to the one it was acked
+// in. Minutes are handed out to events in order, those that must be counted
+// earliest first.
+func checkMinuteCounts(events []ModelEvent, rows []Row) string {
+	type window struct{ first, last time.Time }
+	windows := make([]window, 0, len(events))
+	for _, e := range events {
+		windows = append(windows, window{e.Sent.UTC().Truncate(time.Minute), e.Acked.UTC().Truncate(time.Minute)})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].first.Before(windows[j].first) })
+
+	var pending []window
+	var previous time.Time
+	for i, row := range rows {
+		minute, ok := parseQueryTime(row["minute"])
+		if !ok {
+			return fmt.Sprintf("row %d: minute %v isn't a timestamp", i, row["minute"])
+		}
+		count, err := toInt64(row["count"])
+		if err != nil {
+			return fmt.Sprintf("row %d: count %v isn't a number", i, row["count"])
+		}
+		if i > 0 && !minute.After(previous) {
+			return fmt.Sprintf("row %d: minute %s isn't after %s", i, minute, previous)
+		}
+		previous = minute
+
+		for len(windows) > 0 && !windows[0].first.After(minute) {
+			pending = append(pending, windows[0])
+			windows = windows[1:]
+		}
+		sort.Slice(pending, func(i, j int) bool { return pending[i].last.Before(pending[j].last) })
+		if len(pending) > 0 && pending[0].last.Before(minute) {
+			return fmt.Sprintf("an event acked in minute %s isn't counted by then", pending[0].last)
+		}
+		if int64(len(pending)) < count {
+			return fmt.Sprintf("row %d: %d events in minute %s, at most %d can be", i, count, minute, len(pending))
+		}
+		pending = pending[count:]
+	}
+	if missing := len(pending) + len(windows); missing > 0 {
+		return fmt.Sprintf("%d of %d events aren't counted in any minute: %v", missing, len(events), rows)
+	}
+	return ""
+}
+
+// Runs `queries` over `stream` and checks each returns the rows computed from
+// `events`, the events ingested into it.
+func AssertOracleQueries(t *testing.T, client HTTPClient, stream string, events []ModelEvent, queries []OracleQuery) {
+	start := time.Now()
+	for _, e := range events {
+		if e.Sent.Before(start) {
+			start = e.Sent
+		}
+	}
+	start = start.Add(-time.Minute)
+
+	for _, q := range queries {
+		t.Run(q.Name, func(t *testing.T) {
+			check := q.Check
+			if check == nil {
+				expected, ok := q.Expected(events)
+				if !ok {
+					t.Skipf("Can't predict the result of %s for these events", q.Name)
+				}
+				check = func(_ []ModelEvent, rows []Row) string { return diffRows(expected, rows) }
+			}
+			sql := fmt.Sprintf(q.SQL, stream)
+			rows, err := client.API().Query(parseable.Query{SQL: sql, StartTime: start, EndTime: time.Now().Add(time.Second)})
+			if err != nil {
+				t.Fatalf("Query %s failed: %s", sql, err)
+			}
+			if diff := check(events, rows); diff != "" {
+				t.Errorf("Query %s returned wrong results: %s", sql, diff)
+			}
+		})
+	}
+}
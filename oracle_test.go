@@ -0,0 +1,180 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var oracleSent = time.Date(2026, 10, 17, 10, 0, 30, 0, time.UTC)
+
+// Flog events with a few methods, statuses and sizes, all stamped at
+// `oracleSent`.
+func oracleEvents(t *testing.T, n int) []ModelEvent {
+	flogs := fakeFlogs(n)
+	methods := []string{"GET", "POST", "DELETE"}
+	for i := range flogs {
+		flogs[i].Method = methods[i%len(methods)]
+		flogs[i].Status = uint16(200 + 100*(i%4))
+		flogs[i].ByteCount = uint64(5000 * i)
+		flogs[i].Host = []string{"b.example", "a.example"}[i%2]
+	}
+	records, err := toRecords(flogs)
+	require.NoError(t, err)
+	events := make([]ModelEvent, 0, n)
+	for _, record := range records {
+		events = append(events, ModelEvent{Fields: record, Sent: oracleSent, Acked: oracleSent.Add(time.Millisecond)})
+	}
+	return events
+}
+
+func oracleQuery(t *testing.T, name string) OracleQuery {
+	for _, q := range FlogOracleQueries() {
+		if q.Name == name {
+			return q
+		}
+	}
+	t.Fatalf("No oracle query named %s", name)
+	return OracleQuery{}
+}
+
+func TestOracleExpectedResults(t *testing.T) {
+	events := oracleEvents(t, 30)
+	expect := func(name string) []Row {
+		rows, ok := oracleQuery(t, name).Expected(events)
+		require.True(t, ok)
+		return rows
+	}
+
+	require.Equal(t, []Row{{"count": 30}}, expect("Count"))
+	require.Equal(t, []Row{
+		{"method": "DELETE", "count": 10},
+		{"method": "GET", "count": 10},
+		{"method": "POST", "count": 10},
+	}, expect("GroupByMethod"))
+	// Statuses cycle through 200, 300, 400 and 500.
+	require.Equal(t, []Row{{"count": int64(14)}}, expect("WhereStatus"))
+	// GETs are every third event, and bytes > 10000 from the fourth event on.
+	require.Equal(t, []Row{{"count": int64(9)}}, expect("WhereMethodAndBytes"))
+	require.Equal(t, []Row{{
+		"min_status": int64(200), "max_status": int64(500), "sum_status": int64(10300),
+		"min_bytes": int64(0), "max_bytes": int64(145000), "sum_bytes": int64(2175000),
+	}}, expect("MinMaxSum"))
+
+	window := expect("OffsetLimit")
+	require.Len(t, window, 10)
+	// 15 events on each host, so rows 20 to 29 are on b.example.
+	for _, row := range window {
+		require.Equal(t, "b.example", row["host"])
+	}
+	require.Equal(t, "GET", window[0]["method"])
+	require.Equal(t, "POST", window[9]["method"])
+
+	rows, ok := oracleQuery(t, "OffsetLimit").Expected(events[:15])
+	require.True(t, ok)
+	require.Empty(t, rows)
+}
+
+func TestCheckMinuteCounts(t *testing.T) {
+	minute := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	events := oracleEvents(t, 3)
+	// Acked in the next minute, so counted in either.
+	events[1].Sent = minute.Add(59*time.Second + 900*time.Millisecond)
+	events[1].Acked = events[1].Sent.Add(200 * time.Millisecond)
+	events[2].Sent = minute.Add(90 * time.Second)
+	events[2].Acked = events[2].Sent
+	check := oracleQuery(t, "DateTruncMinute").Check
+	counts := func(first, second int) []Row {
+		return []Row{
+			{"minute": "2026-10-17T10:00:00", "count": json.Number(fmt.Sprint(first))},
+			{"minute": "2026-10-17T10:01:00", "count": json.Number(fmt.Sprint(second))},
+		}
+	}
+
+	require.Empty(t, check(events, counts(2, 1)))
+	require.Empty(t, check(events, counts(1, 2)))
+	require.Contains(t, check(events, counts(3, 0)), "at most 2 can be")
+	require.Contains(t, check(events, counts(0, 3)), "isn't counted by then")
+	require.Contains(t, check(events, counts(1, 1)), "1 of 3 events aren't counted")
+	require.Contains(t, check(events, []Row{counts(1, 2)[0], counts(1, 2)[0]}), "isn't after")
+}
+
+func TestDiffRows(t *testing.T) {
+	minute := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	expected := []Row{{"minute": minute, "count": 3, "sum": int64(12), "method": "GET"}}
+
+	require.Empty(t, diffRows(expected, []Row{{"minute": "2026-10-17T10:00:00", "count": json.Number("3"), "sum": 12.0, "method": "GET"}}))
+	require.Empty(t, diffRows(expected, []Row{{"minute": "2026-10-17T10:00:00.000Z", "count": json.Number("3"), "sum": json.Number("12"), "method": "GET"}}))
+	require.Equal(t, "row 0: count: expected 3, got 4, unexpected column extra",
+		diffRows(expected, []Row{{"minute": "2026-10-17T10:00:00", "count": json.Number("4"), "sum": 12.0, "method": "GET", "extra": 1}}))
+	require.Equal(t, "row 0: column method missing, minute: expected 2026-10-17 10:00:00 +0000 UTC, got 2026-10-17T10:01:00",
+		diffRows(expected, []Row{{"minute": "2026-10-17T10:01:00", "count": 3, "sum": 12}}))
+	require.Contains(t, diffRows(expected, nil), "expected 1 rows, got 0")
+	require.Empty(t, diffRows([]Row{{"min": nil}}, []Row{{"min": nil}}))
+}
+
+// Answers queries with the results the oracle expects for `events`, the way
+// the query API renders them.
+func oracleServer(t *testing.T, stream string, events []ModelEvent) HTTPClient {
+	answers := make(map[string][]Row)
+	for _, q := range FlogOracleQueries() {
+		// All of `events` are sent in the same minute.
+		rows := []Row{{"minute": oracleSent.Truncate(time.Minute), "count": len(events)}}
+		if q.Expected != nil {
+			rows, _ = q.Expected(events)
+		}
+		for _, row := range rows {
+			for column, value := range row {
+				if ts, ok := value.(time.Time); ok {
+					row[column] = ts.Format("2006-01-02T15:04:05")
+				}
+			}
+		}
+		answers[fmt.Sprintf(q.SQL, stream)] = rows
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string    `json:"query"`
+			StartTime time.Time `json:"startTime"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.True(t, body.StartTime.Before(oracleSent), "The query window starts after the events were sent")
+		rows, ok := answers[body.Query]
+		if !ok {
+			http.Error(w, "unknown query "+body.Query, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return DefaultClient(*u, "admin", "admin")
+}
+
+func TestAssertOracleQueries(t *testing.T) {
+	events := oracleEvents(t, 40)
+	client := oracleServer(t, "app", events)
+	AssertOracleQueries(t, client, "app", events, FlogOracleQueries())
+}
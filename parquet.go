@@ -0,0 +1,163 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// Objects read at once by `readParquetObjects`.
+const parquetReadConcurrency = 8
+
+// A parquet object read straight from its store. The reader opens a handle
+// per column, each of which only fetches the ranges it seeks to.
+type storeParquetFile struct {
+	ObjectReader
+	store ObjectStore
+	key   string
+}
+
+func openParquetObject(store ObjectStore, key string) (*storeParquetFile, error) {
+	object, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return &storeParquetFile{object, store, key}, nil
+}
+
+func (file *storeParquetFile) Open(name string) (source.ParquetFile, error) {
+	if name == "" {
+		name = file.key
+	}
+	return openParquetObject(file.store, name)
+}
+
+func (file *storeParquetFile) Create(name string) (source.ParquetFile, error) {
+	return nil, errors.New("parquet objects are read only")
+}
+
+func (file *storeParquetFile) Write(p []byte) (int, error) {
+	return 0, errors.New("parquet objects are read only")
+}
+
+// Reads all rows of a parquet file without knowing its schema up front. Null
+// values are left out of the records.
+func readParquet(file source.ParquetFile, name string) ([]Record, error) {
+	pr, err := reader.NewParquetColumnReader(file, 4)
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+
+	numRows := pr.GetNumRows()
+	records := make([]Record, numRows)
+	for i := range records {
+		records[i] = Record{}
+	}
+
+	for i, inPath := range pr.SchemaHandler.ValueColumns {
+		exPath := common.StrToPath(pr.SchemaHandler.InPathToExPath[inPath])
+		column := exPath[len(exPath)-1]
+
+		values, _, _, err := pr.ReadColumnByIndex(int64(i), numRows)
+		if err != nil {
+			return nil, fmt.Errorf("reading column %s of %s: %w", column, name, err)
+		}
+		if int64(len(values)) != numRows {
+			return nil, fmt.Errorf("column %s of %s has %d values for %d rows", column, name, len(values), numRows)
+		}
+		for row, value := range values {
+			if value != nil {
+				records[row][column] = value
+			}
+		}
+	}
+	return records, nil
+}
+
+// Reads all rows of the parquet object `key`, see `readParquet`.
+func readParquetObject(store ObjectStore, key string) ([]Record, error) {
+	file, err := openParquetObject(store, key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readParquet(file, key)
+}
+
+// Row count in the footer of the parquet object `key`, which is all that is
+// fetched of it.
+func readParquetObjectRowCount(store ObjectStore, key string) (int64, error) {
+	file, err := openParquetObject(store, key)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return 0, err
+	}
+	defer pr.ReadStop()
+	return pr.GetNumRows(), nil
+}
+
+// Calls `read` for every key of `keys`, `parquetReadConcurrency` at a time,
+// and returns the results by key. The first error stops further reads.
+func readParquetObjects[T any](keys []string, read func(key string) (T, error)) (map[string]T, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	results := make(map[string]T, len(keys))
+	work := make(chan string)
+	for i := 0; i < min(parquetReadConcurrency, len(keys)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range work {
+				result, err := read(key)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("reading %s: %w", key, err)
+				} else if err == nil {
+					results[key] = result
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+		work <- key
+	}
+	close(work)
+	wg.Wait()
+	return results, firstErr
+}
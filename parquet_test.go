@@ -0,0 +1,118 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadParquetObject(t *testing.T) {
+	parquet, err := os.ReadFile(writeFlogParquet(t,
+		`{"p_timestamp": 1700000000000, "host": "10.0.0.1", "status": 200}`,
+		`{"p_timestamp": 1700000000001, "host": "10.0.0.2"}`,
+	))
+	require.NoError(t, err)
+	store := NewFakeObjectStore()
+	store.Put("app/a.parquet", parquet)
+
+	records, err := readParquetObject(store, "app/a.parquet")
+	require.NoError(t, err)
+	require.Equal(t, []Record{
+		{"p_timestamp": int64(1700000000000), "host": "10.0.0.1", "status": int64(200)},
+		{"p_timestamp": int64(1700000000001), "host": "10.0.0.2"},
+	}, records)
+
+	rows, err := readParquetObjectRowCount(store, "app/a.parquet")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), rows)
+
+	_, err = readParquetObject(store, "app/missing.parquet")
+	require.Error(t, err)
+}
+
+func TestLoadStreamRecords(t *testing.T) {
+	store := NewFakeObjectStore()
+	for i := 0; i < 20; i++ {
+		parquet, err := os.ReadFile(writeFlogParquet(t, fmt.Sprintf(`{"p_timestamp": %d}`, 1700000000000+i)))
+		require.NoError(t, err)
+		store.Put(fmt.Sprintf("app/date=2023-11-14/%02d.parquet", i), parquet)
+	}
+	store.Put("app/.stream/.stream.json", []byte("{}"))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	before, err := os.ReadDir(wd)
+	require.NoError(t, err)
+
+	records, err := loadStreamRecords("app", store)
+	require.NoError(t, err)
+	require.Len(t, records, 20)
+	for i, record := range records {
+		require.Equal(t, int64(1700000000000+i), record["p_timestamp"])
+	}
+
+	after, err := os.ReadDir(wd)
+	require.NoError(t, err)
+	require.Equal(t, len(before), len(after), "Reading parquet objects left files in the working directory")
+}
+
+func TestReadParquetObjectsConcurrently(t *testing.T) {
+	keys := make([]string, 4*parquetReadConcurrency)
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+	}
+	var running, peak atomic.Int32
+	results, err := readParquetObjects(keys, func(key string) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return "read " + key, nil
+	})
+	require.NoError(t, err)
+	require.Len(t, results, len(keys))
+	require.Equal(t, "read 3", results["3"])
+	require.Equal(t, int32(parquetReadConcurrency), peak.Load())
+
+	var reads atomic.Int32
+	_, err = readParquetObjects(keys, func(key string) (string, error) {
+		reads.Add(1)
+		if key == "0" {
+			return "", errors.New("broken footer")
+		}
+		time.Sleep(10 * time.Millisecond)
+		return key, nil
+	})
+	require.ErrorContains(t, err, "reading 0: broken footer")
+	require.Less(t, int(reads.Load()), len(keys))
+
+	results, err = readParquetObjects(nil, func(key string) (string, error) { return key, nil })
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
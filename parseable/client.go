@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package parseable is a typed client for the Parseable HTTP API.
+package parseable
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Builds and sends requests to the API. Paths are relative to `api/v1/`.
+// quest's `*HTTPClient` implements it.
+type Transport interface {
+	NewRequest(method string, path string, body io.Reader) (*http.Request, error)
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	transport Transport
+}
+
+func New(transport Transport) *Client {
+	return &Client{transport: transport}
+}
+
+// Returned when the server answers with a status other than the one the
+// call expects.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("%s %s returned http code: %s and response: %s", err.Method, err.Path, err.Status, err.Body)
+}
+
+// Whether `err` is an `APIError` with status `code`.
+func IsStatus(err error, code int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+func IsForbidden(err error) bool {
+	return IsStatus(err, http.StatusForbidden)
+}
+
+// Sends a request and returns the response body, or an `*APIError` if the
+// status isn't 200. `body` is sent as is if it is a `[]byte`, and marshalled
+// to JSON otherwise.
+func (c *Client) send(method string, path string, body interface{}, header map[string]string) ([]byte, error) {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := c.transport.NewRequest(method, path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	response, err := c.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: reading response: %w", method, path, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &APIError{Method: method, Path: path, StatusCode: response.StatusCode, Status: response.Status, Body: string(data)}
+	}
+	return data, nil
+}
+
+// Sends a request and decodes the JSON response into `out`.
+func (c *Client) sendJSON(method string, path string, body interface{}, out interface{}) error {
+	data, err := c.send(method, path, body, nil)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response %q: %w", method, path, data, err)
+	}
+	return nil
+}
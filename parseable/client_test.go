@@ -0,0 +1,181 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parseable
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	base string
+}
+
+func (tr testTransport) NewRequest(method string, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequest(method, tr.base+"/api/v1/"+path, body)
+}
+
+func (tr testTransport) Do(req *http.Request) (*http.Response, error) {
+	return http.DefaultClient.Do(req)
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// Answers every request with `status` and `body`, recording what was sent.
+func testServer(t *testing.T, status int, body string) (*Client, *[]recordedRequest) {
+	requests := make([]recordedRequest, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		requests = append(requests, recordedRequest{r.Method, r.URL.Path, r.Header, string(data)})
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return New(testTransport{server.URL}), &requests
+}
+
+func TestAPIError(t *testing.T) {
+	client, _ := testServer(t, http.StatusNotFound, "stream not found")
+	err := client.DeleteStream("app")
+	require.Error(t, err)
+	require.True(t, IsNotFound(err))
+	require.False(t, IsForbidden(err))
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "stream not found", apiErr.Body)
+	require.Equal(t, "DELETE logstream/app returned http code: 404 Not Found and response: stream not found", err.Error())
+}
+
+func TestCreateStream(t *testing.T) {
+	client, requests := testServer(t, http.StatusOK, "")
+	err := client.CreateStream("app", StreamOptions{
+		TimePartition:      "source_time",
+		TimePartitionLimit: "365d",
+		CustomPartition:    []string{"level", "os"},
+		StaticSchema:       []StaticField{{Name: "level", DataType: "string"}},
+		Header:             map[string]string{"X-P-Update-Stream": "false"},
+	})
+	require.NoError(t, err)
+	require.Len(t, *requests, 1)
+
+	req := (*requests)[0]
+	require.Equal(t, "PUT", req.Method)
+	require.Equal(t, "/api/v1/logstream/app", req.Path)
+	require.Equal(t, "source_time", req.Header.Get("X-P-Time-Partition"))
+	require.Equal(t, "365d", req.Header.Get("X-P-Time-Partition-Limit"))
+	require.Equal(t, "level,os", req.Header.Get("X-P-Custom-Partition"))
+	require.Equal(t, "true", req.Header.Get("X-P-Static-Schema-Flag"))
+	require.Equal(t, "false", req.Header.Get("X-P-Update-Stream"))
+	require.JSONEq(t, `{"fields": [{"name": "level", "data_type": "string"}]}`, req.Body)
+
+	require.NoError(t, client.CreateStream("plain", StreamOptions{}))
+	require.Empty(t, (*requests)[1].Body)
+	require.Empty(t, (*requests)[1].Header.Get("X-P-Static-Schema-Flag"))
+}
+
+func TestQuery(t *testing.T) {
+	client, requests := testServer(t, http.StatusOK, `[{"count":42}]`)
+	start := time.Date(2024, 3, 26, 18, 0, 0, 0, time.UTC)
+	count, err := client.Count("app", start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 42, count)
+	require.JSONEq(t, `{"query": "select count(*) as count from app", "startTime": "2024-03-26T18:00:00Z", "endTime": "2024-03-26T19:00:00Z"}`, (*requests)[0].Body)
+
+	rows, err := client.Query(Query{SQL: "select * from app", StartTime: start, EndTime: start})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]interface{}{{"count": json.Number("42")}}, rows)
+
+	client, _ = testServer(t, http.StatusOK, `[]`)
+	_, err = client.Count("app", start, start)
+	require.ErrorContains(t, err, "returned 0 rows")
+}
+
+func TestStreamInfo(t *testing.T) {
+	client, requests := testServer(t, http.StatusOK, `{"created-at": "2024-03-26T18:00:00.5+00:00", "first-event-at": null, "time_partition": "source_time", "custom_partition": "level,os", "static_schema_flag": "false"}`)
+	info, err := client.Info("app")
+	require.NoError(t, err)
+	require.Equal(t, "/api/v1/logstream/app/info", (*requests)[0].Path)
+	require.Equal(t, StreamInfo{CreatedAt: "2024-03-26T18:00:00.5+00:00", TimePartition: "source_time", CustomPartition: "level,os"}, info)
+}
+
+func TestIngest(t *testing.T) {
+	client, requests := testServer(t, http.StatusOK, "")
+	require.NoError(t, client.Ingest("app", []map[string]int{{"a": 1}}, map[string]string{"X-P-TAG-env": "test"}))
+	require.NoError(t, client.Ingest("app", []byte(`{"b":2}`), nil))
+
+	require.Equal(t, "/api/v1/ingest", (*requests)[0].Path)
+	require.Equal(t, "app", (*requests)[0].Header.Get("X-P-Stream"))
+	require.Equal(t, "test", (*requests)[0].Header.Get("X-P-TAG-env"))
+	require.Equal(t, `[{"a":1}]`, (*requests)[0].Body)
+	require.Equal(t, `{"b":2}`, (*requests)[1].Body)
+}
+
+func TestUsersAndRoles(t *testing.T) {
+	client, requests := testServer(t, http.StatusOK, "s3cret")
+	password, err := client.CreateUser("alice", nil)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", password)
+	require.Empty(t, (*requests)[0].Body)
+
+	_, err = client.CreateUser("bob", []string{"reader"})
+	require.NoError(t, err)
+	require.Equal(t, `["reader"]`, (*requests)[1].Body)
+
+	require.NoError(t, client.SetDefaultRole("reader"))
+	require.Equal(t, `"reader"`, (*requests)[2].Body)
+
+	client, _ = testServer(t, http.StatusOK, `{"reader": [{"privilege": "reader", "resource": {"stream": "app", "tag": null}}]}`)
+	roles, err := client.UserRoles("bob")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]Privilege{"reader": {{Privilege: PrivilegeReader, Resource: &Resource{Stream: "app"}}}}, roles)
+
+	client, _ = testServer(t, http.StatusOK, `null`)
+	role, err := client.DefaultRole()
+	require.NoError(t, err)
+	require.Empty(t, role)
+}
+
+func TestSchemaFieldType(t *testing.T) {
+	client, _ := testServer(t, http.StatusOK, `{"fields": [
+		{"name": "host", "data_type": "Utf8", "nullable": true},
+		{"name": "p_timestamp", "data_type": {"Timestamp": ["Millisecond", null]}, "nullable": true}
+	], "metadata": {}}`)
+	schema, err := client.Schema("app")
+	require.NoError(t, err)
+
+	host, ok := schema.Field("host")
+	require.True(t, ok)
+	require.Equal(t, "Utf8", host.Type())
+	timestamp, ok := schema.Field("p_timestamp")
+	require.True(t, ok)
+	require.Equal(t, "Timestamp", timestamp.Type())
+	_, ok = schema.Field("missing")
+	require.False(t, ok)
+}
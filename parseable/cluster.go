@@ -0,0 +1,68 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parseable
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (c *Client) Liveness() error {
+	_, err := c.send(http.MethodGet, "liveness", nil, nil)
+	return err
+}
+
+func (c *Client) Readiness() error {
+	_, err := c.send(http.MethodGet, "readiness", nil, nil)
+	return err
+}
+
+// Server info returned by `about`.
+type About struct {
+	Version         string `json:"version"`
+	UIVersion       string `json:"uiVersion"`
+	Commit          string `json:"commit"`
+	DeploymentID    string `json:"deploymentId"`
+	Mode            string `json:"mode"`
+	Staging         string `json:"staging"`
+	GrpcPort        int    `json:"grpcPort"`
+	OIDCActive      bool   `json:"oidcActive"`
+	License         string `json:"license"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+	// Kind and location of the object store; the shape varies by version.
+	Store json.RawMessage `json:"store"`
+}
+
+func (c *Client) About() (About, error) {
+	var about About
+	err := c.sendJSON(http.MethodGet, "about", nil, &about)
+	return about, err
+}
+
+// A node of a distributed deployment, as listed by `cluster/info`.
+type NodeInfo struct {
+	DomainName string `json:"domain_name"`
+	Reachable  bool   `json:"reachable"`
+	Error      string `json:"error"`
+	Status     string `json:"status"`
+}
+
+func (c *Client) ClusterInfo() ([]NodeInfo, error) {
+	var nodes []NodeInfo
+	err := c.sendJSON(http.MethodGet, "cluster/info", nil, &nodes)
+	return nodes, err
+}
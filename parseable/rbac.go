@@ -0,0 +1,134 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parseable
+
+import (
+	"net/http"
+)
+
+// Privileges that can be granted by a role.
+const (
+	PrivilegeAdmin    = "admin"
+	PrivilegeEditor   = "editor"
+	PrivilegeWriter   = "writer"
+	PrivilegeReader   = "reader"
+	PrivilegeIngestor = "ingestor"
+)
+
+type Privilege struct {
+	Privilege string `json:"privilege"`
+	// Streams the privilege is limited to; all of them when nil.
+	Resource *Resource `json:"resource,omitempty"`
+}
+
+type Resource struct {
+	Stream string  `json:"stream"`
+	Tag    *string `json:"tag,omitempty"`
+}
+
+func (c *Client) PutRole(name string, privileges []Privilege) error {
+	_, err := c.send(http.MethodPut, "role/"+name, privileges, nil)
+	return err
+}
+
+// Creates or replaces role `name` from a raw JSON body, sent as is.
+func (c *Client) PutRoleJSON(name string, body []byte) error {
+	_, err := c.send(http.MethodPut, "role/"+name, body, nil)
+	return err
+}
+
+func (c *Client) Role(name string) ([]Privilege, error) {
+	var privileges []Privilege
+	err := c.sendJSON(http.MethodGet, "role/"+name, nil, &privileges)
+	return privileges, err
+}
+
+// Returns the privileges of role `name` as the raw JSON body.
+func (c *Client) RoleJSON(name string) ([]byte, error) {
+	return c.send(http.MethodGet, "role/"+name, nil, nil)
+}
+
+func (c *Client) DeleteRole(name string) error {
+	_, err := c.send(http.MethodDelete, "role/"+name, nil, nil)
+	return err
+}
+
+func (c *Client) ListRoles() (map[string][]Privilege, error) {
+	var roles map[string][]Privilege
+	err := c.sendJSON(http.MethodGet, "role", nil, &roles)
+	return roles, err
+}
+
+// Sets the role new OAuth users get.
+func (c *Client) SetDefaultRole(name string) error {
+	_, err := c.send(http.MethodPut, "role/default", name, nil)
+	return err
+}
+
+func (c *Client) DefaultRole() (string, error) {
+	var name *string
+	err := c.sendJSON(http.MethodGet, "role/default", nil, &name)
+	if name == nil {
+		return "", err
+	}
+	return *name, err
+}
+
+// Creates a user with `roles` and returns its password.
+func (c *Client) CreateUser(name string, roles []string) (string, error) {
+	var body interface{}
+	if roles != nil {
+		body = roles
+	}
+	password, err := c.send(http.MethodPost, "user/"+name, body, nil)
+	return string(password), err
+}
+
+func (c *Client) DeleteUser(name string) error {
+	_, err := c.send(http.MethodDelete, "user/"+name, nil, nil)
+	return err
+}
+
+func (c *Client) ListUsers() ([]string, error) {
+	var users []string
+	err := c.sendJSON(http.MethodGet, "user", nil, &users)
+	return users, err
+}
+
+// Replaces the roles of a user.
+func (c *Client) SetUserRoles(name string, roles []string) error {
+	_, err := c.send(http.MethodPut, "user/"+name+"/role", roles, nil)
+	return err
+}
+
+// Roles of a user, with their privileges.
+func (c *Client) UserRoles(name string) (map[string][]Privilege, error) {
+	var roles map[string][]Privilege
+	err := c.sendJSON(http.MethodGet, "user/"+name+"/role", nil, &roles)
+	return roles, err
+}
+
+// Returns the roles of user `name` as the raw JSON body.
+func (c *Client) UserRolesJSON(name string) ([]byte, error) {
+	return c.send(http.MethodGet, "user/"+name+"/role", nil, nil)
+}
+
+// Generates a new password for a user and returns it.
+func (c *Client) ResetPassword(name string) (string, error) {
+	password, err := c.send(http.MethodPost, "user/"+name+"/generate-new-password", nil, nil)
+	return string(password), err
+}
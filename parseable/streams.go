@@ -0,0 +1,308 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package parseable
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Stream struct {
+	Name string `json:"name"`
+}
+
+// Field of a static schema, as sent when creating a stream.
+type StaticField struct {
+	Name string `json:"name"`
+	// One of `string`, `int`, `float`, `boolean` or `datetime`.
+	DataType string `json:"data_type"`
+}
+
+type StreamOptions struct {
+	TimePartition string
+	// e.g. `30d`.
+	TimePartitionLimit string
+	CustomPartition    []string
+	// Fixes the schema of the stream when set.
+	StaticSchema []StaticField
+	// Any other headers to send.
+	Header map[string]string
+}
+
+func (options StreamOptions) header() map[string]string {
+	header := make(map[string]string, len(options.Header)+4)
+	for k, v := range options.Header {
+		header[k] = v
+	}
+	if options.TimePartition != "" {
+		header["X-P-Time-Partition"] = options.TimePartition
+	}
+	if options.TimePartitionLimit != "" {
+		header["X-P-Time-Partition-Limit"] = options.TimePartitionLimit
+	}
+	if len(options.CustomPartition) > 0 {
+		header["X-P-Custom-Partition"] = strings.Join(options.CustomPartition, ",")
+	}
+	if len(options.StaticSchema) > 0 {
+		header["X-P-Static-Schema-Flag"] = "true"
+	}
+	return header
+}
+
+func (c *Client) CreateStream(name string, options StreamOptions) error {
+	var body interface{}
+	if len(options.StaticSchema) > 0 {
+		body = map[string]interface{}{"fields": options.StaticSchema}
+	}
+	_, err := c.send(http.MethodPut, "logstream/"+name, body, options.header())
+	return err
+}
+
+func (c *Client) DeleteStream(name string) error {
+	_, err := c.send(http.MethodDelete, "logstream/"+name, nil, nil)
+	return err
+}
+
+func (c *Client) ListStreams() ([]Stream, error) {
+	var streams []Stream
+	err := c.sendJSON(http.MethodGet, "logstream", nil, &streams)
+	return streams, err
+}
+
+// Arrow schema of a stream.
+type Schema struct {
+	Fields []Field `json:"fields"`
+}
+
+type Field struct {
+	Name     string          `json:"name"`
+	DataType json.RawMessage `json:"data_type"`
+	Nullable bool            `json:"nullable"`
+}
+
+// Name of the Arrow type of the field, e.g. `Utf8`, `Int64` or `Timestamp`.
+func (field Field) Type() string {
+	var name string
+	if json.Unmarshal(field.DataType, &name) == nil {
+		return name
+	}
+	// Parameterised types are objects with a single key, e.g.
+	// `{"Timestamp": ["Millisecond", null]}`.
+	var parameterised map[string]json.RawMessage
+	if json.Unmarshal(field.DataType, &parameterised) == nil {
+		for name := range parameterised {
+			return name
+		}
+	}
+	return string(field.DataType)
+}
+
+func (schema Schema) Field(name string) (Field, bool) {
+	for _, field := range schema.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+func (c *Client) Schema(stream string) (Schema, error) {
+	var schema Schema
+	err := c.sendJSON(http.MethodGet, "logstream/"+stream+"/schema", nil, &schema)
+	return schema, err
+}
+
+// Returns the schema of `stream` as the raw JSON body, with all its fields.
+func (c *Client) SchemaJSON(stream string) ([]byte, error) {
+	return c.send(http.MethodGet, "logstream/"+stream+"/schema", nil, nil)
+}
+
+type Stats struct {
+	Stream    string `json:"stream"`
+	Time      string `json:"time"`
+	Ingestion struct {
+		Count  uint64 `json:"count"`
+		Size   string `json:"size"`
+		Format string `json:"format"`
+	} `json:"ingestion"`
+	Storage struct {
+		Size   string `json:"size"`
+		Format string `json:"format"`
+	} `json:"storage"`
+}
+
+func (c *Client) Stats(stream string) (Stats, error) {
+	var stats Stats
+	err := c.sendJSON(http.MethodGet, "logstream/"+stream+"/stats", nil, &stats)
+	return stats, err
+}
+
+type StreamInfo struct {
+	// RFC 3339 times; empty when not known, e.g. no event was ingested yet.
+	CreatedAt     string `json:"created-at"`
+	FirstEventAt  string `json:"first-event-at"`
+	TimePartition string `json:"time_partition"`
+	// Comma separated field names.
+	CustomPartition string `json:"custom_partition"`
+}
+
+func (c *Client) Info(stream string) (StreamInfo, error) {
+	var info StreamInfo
+	err := c.sendJSON(http.MethodGet, "logstream/"+stream+"/info", nil, &info)
+	return info, err
+}
+
+// Sends `events` (anything that marshals to a JSON object or array, or raw
+// JSON as `[]byte`) to `stream` through the `ingest` endpoint, which creates
+// the stream if needed. `header` can carry `X-P-META-*` and `X-P-TAG-*`
+// values.
+func (c *Client) Ingest(stream string, events interface{}, header map[string]string) error {
+	h := map[string]string{"X-P-Stream": stream}
+	for k, v := range header {
+		h[k] = v
+	}
+	_, err := c.send(http.MethodPost, "ingest", events, h)
+	return err
+}
+
+// Sends `events` to an existing `stream`.
+func (c *Client) PostEvents(stream string, events interface{}) error {
+	_, err := c.send(http.MethodPost, "logstream/"+stream, events, nil)
+	return err
+}
+
+type Query struct {
+	SQL       string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+func (q Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"query":     q.SQL,
+		"startTime": q.StartTime.Format(time.RFC3339Nano),
+		"endTime":   q.EndTime.Format(time.RFC3339Nano),
+	})
+}
+
+// Runs `q` and returns the rows. Numbers are returned as `json.Number`.
+func (c *Client) Query(q Query) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := c.sendJSON(http.MethodPost, "query", q, &rows)
+	return rows, err
+}
+
+// Number of events in `stream` between `start` and `end`.
+func (c *Client) Count(stream string, start time.Time, end time.Time) (uint64, error) {
+	var rows []struct {
+		Count uint64 `json:"count"`
+	}
+	err := c.sendJSON(http.MethodPost, "query", Query{SQL: "select count(*) as count from " + stream, StartTime: start, EndTime: end}, &rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) != 1 {
+		return 0, fmt.Errorf("count query of %s returned %d rows", stream, len(rows))
+	}
+	return rows[0].Count, nil
+}
+
+type AlertConfig struct {
+	Version string  `json:"version"`
+	Alerts  []Alert `json:"alerts"`
+}
+
+type Alert struct {
+	Name    string        `json:"name"`
+	Message string        `json:"message"`
+	Rule    AlertRule     `json:"rule"`
+	Targets []AlertTarget `json:"targets"`
+}
+
+type AlertRule struct {
+	Type string `json:"type"`
+	// Shape depends on `Type`: a string for `composite` rules, an object
+	// for `column` rules.
+	Config json.RawMessage `json:"config"`
+}
+
+type AlertTarget struct {
+	Type         string            `json:"type"`
+	Endpoint     string            `json:"endpoint"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	SkipTLSCheck bool              `json:"skip_tls_check,omitempty"`
+	Repeat       *AlertRepeat      `json:"repeat,omitempty"`
+}
+
+type AlertRepeat struct {
+	Interval string `json:"interval"`
+	Times    int    `json:"times"`
+}
+
+func (c *Client) SetAlert(stream string, config AlertConfig) error {
+	_, err := c.send(http.MethodPut, "logstream/"+stream+"/alert", config, nil)
+	return err
+}
+
+// Sets the alert config of `stream` from a raw JSON body, sent as is.
+func (c *Client) SetAlertJSON(stream string, body []byte) error {
+	_, err := c.send(http.MethodPut, "logstream/"+stream+"/alert", body, nil)
+	return err
+}
+
+func (c *Client) Alert(stream string) (AlertConfig, error) {
+	var config AlertConfig
+	err := c.sendJSON(http.MethodGet, "logstream/"+stream+"/alert", nil, &config)
+	return config, err
+}
+
+// Returns the alert config of `stream` as the raw JSON body.
+func (c *Client) AlertJSON(stream string) ([]byte, error) {
+	return c.send(http.MethodGet, "logstream/"+stream+"/alert", nil, nil)
+}
+
+type RetentionRule struct {
+	Description string `json:"description"`
+	Action      string `json:"action"`
+	// e.g. `20d`.
+	Duration string `json:"duration"`
+}
+
+func (c *Client) SetRetention(stream string, rules []RetentionRule) error {
+	_, err := c.send(http.MethodPut, "logstream/"+stream+"/retention", rules, nil)
+	return err
+}
+
+// Sets the retention rules of `stream` from a raw JSON body, sent as is.
+func (c *Client) SetRetentionJSON(stream string, body []byte) error {
+	_, err := c.send(http.MethodPut, "logstream/"+stream+"/retention", body, nil)
+	return err
+}
+
+func (c *Client) Retention(stream string) ([]RetentionRule, error) {
+	var rules []RetentionRule
+	err := c.sendJSON(http.MethodGet, "logstream/"+stream+"/retention", nil, &rules)
+	return rules, err
+}
+
+// Returns the retention rules of `stream` as the raw JSON body.
+func (c *Client) RetentionJSON(stream string) ([]byte, error) {
+	return c.send(http.MethodGet, "logstream/"+stream+"/retention", nil, nil)
+}
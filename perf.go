@@ -0,0 +1,177 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Numbers of a load run that are compared against a baseline.
+type PerfMetrics struct {
+	EventsPerSecond float64 `json:"events_per_second"`
+	P50Ms           float64 `json:"p50_ms"`
+	P95Ms           float64 `json:"p95_ms"`
+	P99Ms           float64 `json:"p99_ms"`
+	ErrorRate       float64 `json:"error_rate"`
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func PerfMetricsOf(result LoadResult) PerfMetrics {
+	return PerfMetrics{
+		EventsPerSecond: result.EventsPerSecond(),
+		P50Ms:           millis(result.Latency.P50),
+		P95Ms:           millis(result.Latency.P95),
+		P99Ms:           millis(result.Latency.P99),
+		ErrorRate:       result.ErrorRate(),
+	}
+}
+
+func (metrics PerfMetrics) String() string {
+	return fmt.Sprintf("events/s=%.1f p50=%.1fms p95=%.1fms p99=%.1fms errors=%.2f%%",
+		metrics.EventsPerSecond, metrics.P50Ms, metrics.P95Ms, metrics.P99Ms, metrics.ErrorRate*100)
+}
+
+// Load results of a run, by test name.
+type PerfBaseline struct {
+	Saved   time.Time              `json:"saved"`
+	Results map[string]PerfMetrics `json:"results"`
+}
+
+func LoadPerfBaseline(path string) (PerfBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PerfBaseline{}, err
+	}
+	var baseline PerfBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return PerfBaseline{}, fmt.Errorf("parsing baseline %s: %w", path, err)
+	}
+	if baseline.Results == nil {
+		baseline.Results = make(map[string]PerfMetrics)
+	}
+	return baseline, nil
+}
+
+func (baseline PerfBaseline) Save(path string) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// How much worse than the baseline a run may be. Throughput and latency are
+// relative (0.1 allows 10% fewer events/s or 10% more latency), the error
+// rate is absolute (0.01 allows one more failed request in a hundred).
+type PerfTolerances struct {
+	Throughput float64
+	Latency    float64
+	ErrorRate  float64
+}
+
+type PerfRegression struct {
+	Metric   string
+	Baseline float64
+	Current  float64
+}
+
+func (r PerfRegression) String() string {
+	return fmt.Sprintf("%s went from %.2f to %.2f", r.Metric, r.Baseline, r.Current)
+}
+
+// The metrics of `current` that are worse than `baseline` beyond `tolerances`.
+func ComparePerf(baseline PerfMetrics, current PerfMetrics, tolerances PerfTolerances) []PerfRegression {
+	var regressions []PerfRegression
+	if current.EventsPerSecond < baseline.EventsPerSecond*(1-tolerances.Throughput) {
+		regressions = append(regressions, PerfRegression{"events/s", baseline.EventsPerSecond, current.EventsPerSecond})
+	}
+	latencies := []struct {
+		metric            string
+		baseline, current float64
+	}{
+		{"p50 latency (ms)", baseline.P50Ms, current.P50Ms},
+		{"p95 latency (ms)", baseline.P95Ms, current.P95Ms},
+		{"p99 latency (ms)", baseline.P99Ms, current.P99Ms},
+	}
+	for _, l := range latencies {
+		if l.current > l.baseline*(1+tolerances.Latency) {
+			regressions = append(regressions, PerfRegression{l.metric, l.baseline, l.current})
+		}
+	}
+	if current.ErrorRate > baseline.ErrorRate+tolerances.ErrorRate {
+		regressions = append(regressions, PerfRegression{"error rate", baseline.ErrorRate, current.ErrorRate})
+	}
+	return regressions
+}
+
+func formatRegressions(regressions []PerfRegression) string {
+	lines := make([]string, 0, len(regressions))
+	for _, r := range regressions {
+		lines = append(lines, r.String())
+	}
+	return strings.Join(lines, ", ")
+}
+
+// Compares load results against the baseline at `BaselinePath` and saves them
+// to `SavePath`, each when set.
+type PerfGate struct {
+	BaselinePath string
+	SavePath     string
+	Tolerances   PerfTolerances
+
+	mu sync.Mutex
+}
+
+// Adds the metrics of test `name` to the baseline at `SavePath`, keeping the
+// results of other tests already saved there.
+func (gate *PerfGate) Record(name string, metrics PerfMetrics) error {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+	baseline, err := LoadPerfBaseline(gate.SavePath)
+	if errors.Is(err, os.ErrNotExist) {
+		baseline, err = PerfBaseline{Results: make(map[string]PerfMetrics)}, nil
+	}
+	if err != nil {
+		return err
+	}
+	baseline.Saved = time.Now().UTC()
+	baseline.Results[name] = metrics
+	return baseline.Save(gate.SavePath)
+}
+
+// Compares the metrics of test `name` against the baseline. `found` is false
+// when the baseline has no results for the test.
+func (gate *PerfGate) Compare(name string, metrics PerfMetrics) (regressions []PerfRegression, found bool, err error) {
+	baseline, err := LoadPerfBaseline(gate.BaselinePath)
+	if err != nil {
+		return nil, false, err
+	}
+	base, found := baseline.Results[name]
+	if !found {
+		return nil, false, nil
+	}
+	return ComparePerf(base, metrics, gate.Tolerances), true, nil
+}
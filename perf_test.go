@@ -0,0 +1,96 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testTolerances = PerfTolerances{Throughput: 0.1, Latency: 0.2, ErrorRate: 0.01}
+
+func TestComparePerf(t *testing.T) {
+	baseline := PerfMetrics{EventsPerSecond: 1000, P50Ms: 10, P95Ms: 40, P99Ms: 100, ErrorRate: 0}
+
+	within := PerfMetrics{EventsPerSecond: 910, P50Ms: 11, P95Ms: 47, P99Ms: 119, ErrorRate: 0.005}
+	require.Empty(t, ComparePerf(baseline, within, testTolerances))
+
+	better := PerfMetrics{EventsPerSecond: 2000, P50Ms: 5, P95Ms: 20, P99Ms: 50}
+	require.Empty(t, ComparePerf(baseline, better, testTolerances))
+
+	worse := PerfMetrics{EventsPerSecond: 850, P50Ms: 10, P95Ms: 50, P99Ms: 100, ErrorRate: 0.02}
+	regressions := ComparePerf(baseline, worse, testTolerances)
+	require.Equal(t, []PerfRegression{
+		{"events/s", 1000, 850},
+		{"p95 latency (ms)", 40, 50},
+		{"error rate", 0, 0.02},
+	}, regressions)
+	require.Equal(t, "events/s went from 1000.00 to 850.00, p95 latency (ms) went from 40.00 to 50.00, error rate went from 0.00 to 0.02", formatRegressions(regressions))
+}
+
+func TestPerfMetricsOf(t *testing.T) {
+	metrics := PerfMetricsOf(LoadResult{
+		Requests: 100,
+		Errors:   5,
+		Events:   2000,
+		Duration: 2 * time.Second,
+		Latency:  LatencyStats{P50: 1500 * time.Microsecond, P95: 4 * time.Millisecond, P99: 9 * time.Millisecond},
+	})
+	require.Equal(t, PerfMetrics{EventsPerSecond: 1000, P50Ms: 1.5, P95Ms: 4, P99Ms: 9, ErrorRate: 0.05}, metrics)
+}
+
+func TestPerfGate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	gate := &PerfGate{BaselinePath: path, SavePath: path, Tolerances: testTolerances}
+
+	_, _, err := gate.Compare("TestLoad", PerfMetrics{})
+	require.Error(t, err, "Comparing against a missing baseline should fail")
+
+	first := PerfMetrics{EventsPerSecond: 1000, P50Ms: 10, P95Ms: 40, P99Ms: 100}
+	require.NoError(t, gate.Record("TestLoad", first))
+	require.NoError(t, gate.Record("TestOther", PerfMetrics{EventsPerSecond: 10}))
+
+	baseline, err := LoadPerfBaseline(path)
+	require.NoError(t, err)
+	require.Equal(t, first, baseline.Results["TestLoad"])
+	require.Len(t, baseline.Results, 2)
+
+	regressions, found, err := gate.Compare("TestLoad", PerfMetrics{EventsPerSecond: 500, P50Ms: 10, P95Ms: 40, P99Ms: 100})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, regressions, 1)
+
+	_, found, err = gate.Compare("TestMissing", first)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestCheckPerf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	result := LoadResult{Requests: 10, Events: 100, Duration: time.Second, Latency: LatencyStats{P50: time.Millisecond}}
+
+	CheckPerf(t, nil, result)
+	CheckPerf(t, &PerfGate{SavePath: path}, result)
+	CheckPerf(t, &PerfGate{BaselinePath: path, Tolerances: testTolerances}, result)
+
+	baseline, err := LoadPerfBaseline(path)
+	require.NoError(t, err)
+	require.Equal(t, PerfMetricsOf(result), baseline.Results[t.Name()])
+}
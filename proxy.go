@@ -0,0 +1,311 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Routes faults can be scoped to.
+const (
+	RouteIngest    = "ingest"
+	RouteQuery     = "query"
+	RouteLogstream = "logstream"
+	RouteOther     = "other"
+)
+
+// Route of a request to the Parseable API. Events posted to
+// `logstream/{stream}` count as ingestion.
+func routeOf(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	switch {
+	case path == "ingest":
+		return RouteIngest
+	case path == "query":
+		return RouteQuery
+	case strings.HasPrefix(path, "logstream"):
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+		if r.Method == http.MethodPost && len(parts) == 2 {
+			return RouteIngest
+		}
+		return RouteLogstream
+	}
+	return RouteOther
+}
+
+// Faults injected by `FaultProxy` into the requests of a route. Rates are
+// probabilities between 0 and 1, drawn independently for every request.
+type ProxyFault struct {
+	// One of the `Route*` constants; applies to every route when empty.
+	Route string
+	// Added before the request is forwarded.
+	Latency time.Duration
+	// Rate of connections reset instead of answered.
+	ResetRate float64
+	// Rate of responses whose body is cut off half way.
+	TruncateRate float64
+	// Rate of requests answered with `ErrorStatus` (503 by default).
+	ErrorRate   float64
+	ErrorStatus int
+	// Apply resets and errors after Parseable has handled the request
+	// instead of before, so the client sees a failure for a request that
+	// succeeded.
+	AfterUpstream bool
+	// Limit on request and response bodies, in bytes per second.
+	Bandwidth int
+}
+
+// Number of requests the proxy saw, and faults it injected, for a route.
+type ProxyStats struct {
+	Requests  int
+	Resets    int
+	Truncated int
+	Errors    int
+}
+
+// A reverse proxy in front of Parseable that injects network faults. Route
+// an `HTTPClient` through it with `Client`.
+type FaultProxy struct {
+	target   *url.URL
+	listener net.Listener
+	server   *http.Server
+	proxy    *httputil.ReverseProxy
+
+	mu     sync.Mutex
+	faults []ProxyFault
+	rand   *rand.Rand
+	stats  map[string]ProxyStats
+}
+
+// Starts a proxy for `target` on a random local port. `seed` makes the
+// faults that are injected reproducible.
+func NewFaultProxy(target url.URL, seed int64, faults ...ProxyFault) (*FaultProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &FaultProxy{
+		target:   &target,
+		listener: listener,
+		proxy:    httputil.NewSingleHostReverseProxy(&target),
+		faults:   faults,
+		rand:     rand.New(rand.NewSource(seed)),
+		stats:    make(map[string]ProxyStats),
+	}
+	p.proxy.ModifyResponse = func(response *http.Response) error {
+		plan, _ := response.Request.Context().Value(proxyPlanKey{}).(proxyPlan)
+		if plan.truncate {
+			response.Body = &truncatedBody{ReadCloser: response.Body, remaining: response.ContentLength / 2}
+		}
+		if plan.bandwidth > 0 {
+			response.Body = &throttledBody{ReadCloser: response.Body, bandwidth: plan.bandwidth}
+		}
+		return nil
+	}
+	p.server = &http.Server{Handler: http.HandlerFunc(p.serveHTTP)}
+	go p.server.Serve(listener)
+	return p, nil
+}
+
+func (p *FaultProxy) Url() url.URL {
+	return url.URL{Scheme: "http", Host: p.listener.Addr().String(), Path: p.target.Path}
+}
+
+// Copy of `client` that sends its requests through the proxy.
+func (p *FaultProxy) Client(client HTTPClient) HTTPClient {
+	client.Url = p.Url()
+	return client
+}
+
+func (p *FaultProxy) SetFaults(faults ...ProxyFault) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.faults = faults
+}
+
+func (p *FaultProxy) Stats(route string) ProxyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats[route]
+}
+
+func (p *FaultProxy) Close() error {
+	return p.server.Close()
+}
+
+type proxyPlanKey struct{}
+
+// What to do to a single request, decided up front so the random draws are
+// made under the lock.
+type proxyPlan struct {
+	latency       time.Duration
+	reset         bool
+	truncate      bool
+	errorStatus   int
+	afterUpstream bool
+	bandwidth     int
+}
+
+func (p *FaultProxy) plan(route string) proxyPlan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var plan proxyPlan
+	stats := p.stats[route]
+	stats.Requests++
+	for _, fault := range p.faults {
+		if fault.Route != "" && fault.Route != route {
+			continue
+		}
+		plan.latency += fault.Latency
+		if fault.Bandwidth > 0 && (plan.bandwidth == 0 || fault.Bandwidth < plan.bandwidth) {
+			plan.bandwidth = fault.Bandwidth
+		}
+		if plan.reset || plan.errorStatus != 0 {
+			continue
+		}
+		switch {
+		case p.rand.Float64() < fault.ResetRate:
+			plan.reset = true
+			plan.afterUpstream = fault.AfterUpstream
+			stats.Resets++
+		case p.rand.Float64() < fault.ErrorRate:
+			plan.errorStatus = fault.ErrorStatus
+			if plan.errorStatus == 0 {
+				plan.errorStatus = http.StatusServiceUnavailable
+			}
+			plan.afterUpstream = fault.AfterUpstream
+			stats.Errors++
+		case p.rand.Float64() < fault.TruncateRate:
+			plan.truncate = true
+			stats.Truncated++
+		}
+	}
+	p.stats[route] = stats
+	return plan
+}
+
+// Closes the client connection with a TCP reset.
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+func (p *FaultProxy) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	plan := p.plan(routeOf(r))
+	time.Sleep(plan.latency)
+
+	if !plan.afterUpstream {
+		if plan.reset {
+			resetConnection(w)
+			return
+		}
+		if plan.errorStatus != 0 {
+			http.Error(w, "injected by fault proxy", plan.errorStatus)
+			return
+		}
+	}
+
+	if plan.bandwidth > 0 && r.Body != nil {
+		r.Body = &throttledBody{ReadCloser: r.Body, bandwidth: plan.bandwidth}
+	}
+
+	if plan.afterUpstream && (plan.reset || plan.errorStatus != 0) {
+		// Let Parseable handle the request, then throw its response away.
+		recorder := &discardResponseWriter{header: make(http.Header)}
+		p.proxy.ServeHTTP(recorder, r)
+		if plan.reset {
+			resetConnection(w)
+			return
+		}
+		http.Error(w, "injected by fault proxy", plan.errorStatus)
+		return
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyPlanKey{}, plan)))
+}
+
+var errTruncated = errors.New("response truncated by fault proxy")
+
+// Ends with an error after `remaining` bytes, so the proxy aborts the
+// response with fewer bytes than its Content-Length.
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(data []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, errTruncated
+	}
+	if int64(len(data)) > b.remaining {
+		data = data[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(data)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Reads at most `bandwidth` bytes per second.
+type throttledBody struct {
+	io.ReadCloser
+	bandwidth int
+}
+
+func (b *throttledBody) Read(data []byte) (int, error) {
+	// Read in chunks of a tenth of a second's worth of bytes.
+	chunk := b.bandwidth / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if len(data) > chunk {
+		data = data[:chunk]
+	}
+	start := time.Now()
+	n, err := b.ReadCloser.Read(data)
+	wait := time.Duration(n) * time.Second / time.Duration(b.bandwidth)
+	time.Sleep(wait - time.Since(start))
+	return n, err
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header            { return w.header }
+func (w *discardResponseWriter) Write(data []byte) (int, error) { return len(data), nil }
+func (w *discardResponseWriter) WriteHeader(int)                {}
@@ -0,0 +1,138 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteOf(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		route  string
+	}{
+		{"POST", "/api/v1/ingest", RouteIngest},
+		{"POST", "/api/v1/logstream/app", RouteIngest},
+		{"PUT", "/api/v1/logstream/app", RouteLogstream},
+		{"GET", "/api/v1/logstream/app/schema", RouteLogstream},
+		{"GET", "/api/v1/logstream", RouteLogstream},
+		{"POST", "/api/v1/query", RouteQuery},
+		{"GET", "/api/v1/liveness", RouteOther},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.route, routeOf(httptest.NewRequest(c.method, c.path, nil)), "%s %s", c.method, c.path)
+	}
+}
+
+func newTestProxy(t *testing.T, fake *FakeParseable, faults ...ProxyFault) (*FaultProxy, HTTPClient) {
+	proxy, err := NewFaultProxy(fake.Client().Url, 1, faults...)
+	require.NoError(t, err)
+	t.Cleanup(func() { proxy.Close() })
+	return proxy, proxy.Client(fake.Client())
+}
+
+func postEvents(client HTTPClient, stream string, events interface{}) (int, error) {
+	payload, _ := json.Marshal(events)
+	req, _ := client.NewRequest("POST", "ingest", bytes.NewBuffer(payload))
+	req.Header.Add("X-P-Stream", stream)
+	response, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	readAsString(response.Body)
+	return response.StatusCode, nil
+}
+
+func TestFaultProxy(t *testing.T) {
+	t.Run("error before upstream", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		proxy, client := newTestProxy(t, fake, ProxyFault{Route: RouteIngest, ErrorRate: 1, ErrorStatus: http.StatusBadGateway})
+		status, err := postEvents(client, "app", fakeFlogs(3))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, status)
+		require.Empty(t, fake.Events("app"))
+		require.Equal(t, ProxyStats{Requests: 1, Errors: 1}, proxy.Stats(RouteIngest))
+
+		// Other routes go through untouched.
+		require.NoError(t, checkEndpoint(client, "logstream"))
+	})
+
+	t.Run("error after upstream", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		_, client := newTestProxy(t, fake, ProxyFault{Route: RouteIngest, ErrorRate: 1, AfterUpstream: true})
+		status, err := postEvents(client, "app", fakeFlogs(3))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, status)
+		require.Len(t, fake.Events("app"), 3)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		proxy, client := newTestProxy(t, fake, ProxyFault{Route: RouteIngest, ResetRate: 1})
+		_, err := postEvents(client, "app", fakeFlogs(3))
+		require.Error(t, err)
+		require.Empty(t, fake.Events("app"))
+		require.Equal(t, 1, proxy.Stats(RouteIngest).Resets)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		CreateStream(t, fake.Client(), "app")
+		proxy, client := newTestProxy(t, fake, ProxyFault{Route: RouteLogstream, TruncateRate: 1})
+		_, err := FetchStreamSchema(client, "app")
+		require.Error(t, err)
+		require.Equal(t, 1, proxy.Stats(RouteLogstream).Truncated)
+	})
+
+	t.Run("latency and bandwidth", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		_, client := newTestProxy(t, fake,
+			ProxyFault{Route: RouteIngest, Latency: 100 * time.Millisecond},
+			ProxyFault{Route: RouteIngest, Bandwidth: 4000},
+		)
+		events := []map[string]string{{"message": strings.Repeat("x", 2000)}}
+		start := time.Now()
+		status, err := postEvents(client, "app", events)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("no loss or duplicates with retries", func(t *testing.T) {
+		fake := NewFakeParseable(t)
+		_, client := newTestProxy(t, fake, ProxyFault{Route: RouteIngest, ErrorRate: 0.3, ResetRate: 0.2})
+		for i := 0; i < 20; i++ {
+			for attempt := 0; ; attempt++ {
+				require.Less(t, attempt, 50, "batch %d never got through", i)
+				status, err := postEvents(client, "app", fakeFlogs(5))
+				if err == nil && status == http.StatusOK {
+					break
+				}
+			}
+		}
+		require.Len(t, fake.Events("app"), 100)
+	})
+}
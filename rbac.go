@@ -0,0 +1,259 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// What an endpoint does, as far as the privileges are concerned.
+type rbacAction string
+
+const (
+	actionIngest    rbacAction = "ingest"
+	actionRead      rbacAction = "read"
+	actionConfigure rbacAction = "configure"
+	// Managing users and roles.
+	actionManage rbacAction = "manage"
+)
+
+// Actions each privilege grants, on the streams of its resource if it has one.
+var rbacGrants = map[string][]rbacAction{
+	parseable.PrivilegeAdmin:    {actionIngest, actionRead, actionConfigure, actionManage},
+	parseable.PrivilegeEditor:   {actionIngest, actionRead, actionConfigure},
+	parseable.PrivilegeWriter:   {actionIngest, actionRead, actionConfigure},
+	parseable.PrivilegeReader:   {actionRead},
+	parseable.PrivilegeIngestor: {actionIngest},
+}
+
+// Resource a privilege is granted on, relative to the stream the endpoints
+// are called on and another one.
+type RBACScope struct {
+	Name string
+	// Whether the privilege covers the stream the endpoints are called on.
+	Covers   bool
+	resource func(stream string, other string) *parseable.Resource
+}
+
+var (
+	scopeAll = RBACScope{"all", true, func(string, string) *parseable.Resource { return nil }}
+	// The stream the endpoints are called on.
+	scopeStream = RBACScope{"stream", true, func(stream string, _ string) *parseable.Resource {
+		return &parseable.Resource{Stream: stream}
+	}}
+	// Events of that stream with the `rbacTag` tag.
+	scopeTag = RBACScope{"tag", true, func(stream string, _ string) *parseable.Resource {
+		tag := rbacTag
+		return &parseable.Resource{Stream: stream, Tag: &tag}
+	}}
+	scopeOtherStream = RBACScope{"other-stream", false, func(_ string, other string) *parseable.Resource {
+		return &parseable.Resource{Stream: other}
+	}}
+)
+
+const rbacTag = "quest"
+
+// Scopes each privilege is checked with. Admins and editors aren't limited to
+// streams, and only readers to tags.
+var rbacScopes = map[string][]RBACScope{
+	parseable.PrivilegeAdmin:    {scopeAll},
+	parseable.PrivilegeEditor:   {scopeAll},
+	parseable.PrivilegeWriter:   {scopeStream, scopeOtherStream},
+	parseable.PrivilegeReader:   {scopeStream, scopeTag, scopeOtherStream},
+	parseable.PrivilegeIngestor: {scopeStream, scopeOtherStream},
+}
+
+// An API endpoint called on `stream`.
+type RBACEndpoint struct {
+	Name   string
+	Action rbacAction
+	// Whether the endpoint is about a stream, and so limited by resources.
+	StreamScoped bool
+	Method       string
+	Path         func(stream string) string
+	Body         func(stream string) string
+	Header       func(stream string) map[string]string
+}
+
+// Event sent to the streams of the matrix. `status_code` is the field the
+// alert of `AlertBody` is on.
+const rbacEvent = `[{"level": "info", "message": "rbac matrix", "status_code": 200}]`
+
+// Events sent to each stream of the matrix with the `rbacTag` tag. As many
+// are sent without it, for the tag scope to leave out.
+const rbacTaggedEvents = 1
+
+func streamPath(suffix string) func(string) string {
+	return func(stream string) string { return "logstream/" + stream + suffix }
+}
+
+func fixedPath(path string) func(string) string {
+	return func(string) string { return path }
+}
+
+func fixedBody(body string) func(string) string {
+	return func(string) string { return body }
+}
+
+var RBACEndpoints = []RBACEndpoint{
+	{Name: "ingest", Action: actionIngest, StreamScoped: true, Method: "POST", Path: fixedPath("ingest"), Body: fixedBody(rbacEvent),
+		Header: func(stream string) map[string]string { return map[string]string{"X-P-Stream": stream} }},
+	{Name: "query", Action: actionRead, StreamScoped: true, Method: "POST", Path: fixedPath("query"), Body: func(stream string) string {
+		now := time.Now().UTC()
+		body, _ := json.Marshal(parseable.Query{SQL: "select count(*) as count from " + stream, StartTime: now.Add(-time.Hour), EndTime: now.Add(time.Minute)})
+		return string(body)
+	}},
+	{Name: "schema", Action: actionRead, StreamScoped: true, Method: "GET", Path: streamPath("/schema")},
+	{Name: "stats", Action: actionRead, StreamScoped: true, Method: "GET", Path: streamPath("/stats")},
+	{Name: "get-alert", Action: actionRead, StreamScoped: true, Method: "GET", Path: streamPath("/alert")},
+	{Name: "put-alert", Action: actionConfigure, StreamScoped: true, Method: "PUT", Path: streamPath("/alert"), Body: fixedBody(AlertBody)},
+	{Name: "get-retention", Action: actionRead, StreamScoped: true, Method: "GET", Path: streamPath("/retention")},
+	{Name: "put-retention", Action: actionConfigure, StreamScoped: true, Method: "PUT", Path: streamPath("/retention"), Body: fixedBody(RetentionBody)},
+	{Name: "list-users", Action: actionManage, Method: "GET", Path: fixedPath("user")},
+	{Name: "list-roles", Action: actionManage, Method: "GET", Path: fixedPath("role")},
+}
+
+// Status a user with `privilege` on `scope` gets from `endpoint`.
+func ExpectedRBACStatus(privilege string, scope RBACScope, endpoint RBACEndpoint) int {
+	for _, action := range rbacGrants[privilege] {
+		if action == endpoint.Action && (scope.Covers || !endpoint.StreamScoped) {
+			return http.StatusOK
+		}
+	}
+	return http.StatusForbidden
+}
+
+// One cell of the matrix.
+type RBACCase struct {
+	Privilege string
+	Scope     RBACScope
+	Endpoint  RBACEndpoint
+	// 200, 401 or 403.
+	Expected int
+}
+
+func (c RBACCase) Name() string {
+	return c.Privilege + "/" + c.Scope.Name + "/" + c.Endpoint.Name
+}
+
+// Every privilege on every scope it's checked with against every endpoint.
+func RBACMatrix() []RBACCase {
+	privileges := []string{parseable.PrivilegeAdmin, parseable.PrivilegeEditor, parseable.PrivilegeWriter, parseable.PrivilegeReader, parseable.PrivilegeIngestor}
+	var cases []RBACCase
+	for _, privilege := range privileges {
+		for _, scope := range rbacScopes[privilege] {
+			for _, endpoint := range RBACEndpoints {
+				cases = append(cases, RBACCase{privilege, scope, endpoint, ExpectedRBACStatus(privilege, scope, endpoint)})
+			}
+		}
+	}
+	return cases
+}
+
+// Calls `endpoint` on `stream` and returns the status.
+func callRBACEndpoint(client HTTPClient, endpoint RBACEndpoint, stream string) (int, string, error) {
+	var body string
+	if endpoint.Body != nil {
+		body = endpoint.Body(stream)
+	}
+	req, err := client.NewRequest(endpoint.Method, endpoint.Path(stream), strings.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	if endpoint.Header != nil {
+		for k, v := range endpoint.Header(stream) {
+			req.Header.Set(k, v)
+		}
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	return response.StatusCode, readAsString(response.Body), nil
+}
+
+// Returns the body of the response.
+func assertRBACStatus(t *testing.T, client HTTPClient, endpoint RBACEndpoint, stream string, expected int) string {
+	status, body, err := callRBACEndpoint(client, endpoint, stream)
+	require.NoErrorf(t, err, "Request failed: %s", err)
+	require.Equalf(t, expected, status, "%s %s: server returned http code: %d and response: %s", endpoint.Method, endpoint.Path(stream), status, body)
+	return body
+}
+
+// Creates two streams, and a role and user for every privilege and scope of
+// `RBACMatrix`, and checks every cell of the matrix as a subtest, along with
+// every endpoint turning away a user with a wrong password. Queries on the
+// tag scope must also count only the tagged events.
+func CheckRBACMatrix(t *testing.T, admin HTTPClient) {
+	stream := NewTestStream(t, admin, parseable.StreamOptions{})
+	other := NewTestStream(t, admin, parseable.StreamOptions{})
+	tagged := map[string]string{"X-P-TAG-" + rbacTag: "true"}
+	for _, s := range []string{stream, other} {
+		for _, header := range []map[string]string{tagged, nil} {
+			for i := 0; i < rbacTaggedEvents; i++ {
+				err := admin.API().Ingest(s, []byte(rbacEvent), header)
+				require.NoErrorf(t, err, "Couldn't ingest into %s: %s", s, err)
+			}
+		}
+		SetAlert(t, admin, s, AlertBody)
+		SetRetention(t, admin, s, RetentionBody)
+	}
+	WaitForCount(t, admin, stream, 2*rbacTaggedEvents, syncTimeout)
+
+	users := make(map[string]HTTPClient)
+	for _, c := range RBACMatrix() {
+		group := c.Privilege + "/" + c.Scope.Name
+		userClient, ok := users[group]
+		if !ok {
+			privileges := []parseable.Privilege{{Privilege: c.Privilege, Resource: c.Scope.resource(stream, other)}}
+			body, _ := json.Marshal(privileges)
+			role := NewTestRole(t, admin, c.Privilege+c.Scope.Name, string(body))
+			user, password := NewTestUser(t, admin, c.Privilege+c.Scope.Name, []string{role})
+			userClient = admin
+			userClient.Username = user
+			userClient.Password = password
+			users[group] = userClient
+		}
+		c := c
+		t.Run(c.Name(), func(t *testing.T) {
+			body := assertRBACStatus(t, userClient, c.Endpoint, stream, c.Expected)
+			if c.Scope.Name == scopeTag.Name && c.Endpoint.Name == "query" && c.Expected == http.StatusOK {
+				rows := decodeJSON[[]struct{ Count uint64 }](t, body)
+				require.Lenf(t, rows, 1, "Unexpected query response: %s", body)
+				require.Equalf(t, uint64(rbacTaggedEvents), rows[0].Count, "Query on tag %s counted events without the tag", rbacTag)
+			}
+		})
+	}
+
+	wrongPassword := admin
+	wrongPassword.Password = fmt.Sprintf("not-%s", admin.Password)
+	for _, endpoint := range RBACEndpoints {
+		endpoint := endpoint
+		t.Run("wrong-password/"+endpoint.Name, func(t *testing.T) {
+			assertRBACStatus(t, wrongPassword, endpoint, stream, http.StatusUnauthorized)
+		})
+	}
+}
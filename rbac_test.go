@@ -0,0 +1,62 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRBACMatrix(t *testing.T) {
+	cases := RBACMatrix()
+	require.Len(t, cases, 9*len(RBACEndpoints))
+
+	expected := map[string]int{
+		"admin/all/list-roles":             http.StatusOK,
+		"editor/all/put-retention":         http.StatusOK,
+		"editor/all/list-users":            http.StatusForbidden,
+		"writer/stream/put-alert":          http.StatusOK,
+		"writer/other-stream/query":        http.StatusForbidden,
+		"writer/other-stream/list-users":   http.StatusForbidden,
+		"reader/tag/query":                 http.StatusOK,
+		"reader/tag/ingest":                http.StatusForbidden,
+		"reader/stream/put-retention":      http.StatusForbidden,
+		"reader/other-stream/schema":       http.StatusForbidden,
+		"ingestor/stream/ingest":           http.StatusOK,
+		"ingestor/stream/stats":            http.StatusForbidden,
+		"ingestor/other-stream/ingest":     http.StatusForbidden,
+		"reader/stream/get-retention":      http.StatusOK,
+		"writer/other-stream/get-alert":    http.StatusForbidden,
+		"editor/all/ingest":                http.StatusOK,
+		"admin/all/put-alert":              http.StatusOK,
+		"ingestor/other-stream/list-roles": http.StatusForbidden,
+	}
+	for _, c := range cases {
+		if status, ok := expected[c.Name()]; ok {
+			require.Equal(t, status, c.Expected, c.Name())
+			delete(expected, c.Name())
+		}
+	}
+	require.Empty(t, expected, "Cells missing from the matrix")
+}
+
+func TestCheckRBACMatrix(t *testing.T) {
+	fake := NewFakeParseable(t)
+	CheckRBACMatrix(t, fake.Client())
+}
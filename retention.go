@@ -0,0 +1,220 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Days of data `CheckRetentionEnforced` keeps.
+const testRetentionDays = 7
+
+// Events `CheckRetentionEnforced` sends to a day of a stream.
+type RetentionBucket struct {
+	// Days before today.
+	Age    int
+	Events int
+}
+
+// Two days well past `testRetentionDays` and two well within it, so that
+// neither the time of day nor a day's slack in enforcement matters.
+var retentionBuckets = []RetentionBucket{
+	{Age: 30, Events: 20},
+	{Age: 10, Events: 20},
+	{Age: 2, Events: 20},
+	{Age: 0, Events: 20},
+}
+
+// Headers of a stream partitioned by the `source_time` of the events
+// `retentionEvents` makes, accepting ones up to a year old.
+var retentionStreamHeader = map[string]string{"X-P-Time-Partition": "source_time", "X-P-Time-Partition-Limit": "365d"}
+
+// Retention rule deleting data older than `days`.
+func retentionRules(days int) []parseable.RetentionRule {
+	return []parseable.RetentionRule{{
+		Description: fmt.Sprintf("delete after %d days", days),
+		Action:      "delete",
+		Duration:    fmt.Sprintf("%dd", days),
+	}}
+}
+
+// Start of the UTC day `age` days before `now`.
+func retentionDay(now time.Time, age int) time.Time {
+	y, m, d := now.UTC().AddDate(0, 0, -age).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Events for `bucket`, a minute apart from the start of its day. Today's are
+// spread evenly between its start and `now`, so that they stay in today and
+// in the past even right after midnight.
+func retentionEvents(now time.Time, bucket RetentionBucket) []map[string]interface{} {
+	first := retentionDay(now, bucket.Age)
+	step := time.Minute
+	if bucket.Age == 0 {
+		step = now.Sub(first) / time.Duration(bucket.Events)
+	}
+	events := make([]map[string]interface{}, 0, bucket.Events)
+	for i := 0; i < bucket.Events; i++ {
+		events = append(events, map[string]interface{}{
+			"source_time": first.Add(time.Duration(i) * step).Format("2006-01-02T15:04:05.000Z"),
+			"level":       "info",
+			"message":     fmt.Sprintf("retention check, %d days old", bucket.Age),
+		})
+	}
+	return events
+}
+
+// Date of the `date=` segment of an object key, if it has one.
+func objectDate(key string) (time.Time, bool) {
+	for _, segment := range strings.Split(key, "/") {
+		if value, ok := strings.CutPrefix(segment, "date="); ok {
+			date, err := time.Parse("2006-01-02", value)
+			return date, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// Parquet objects of `keys` grouped by the day they are in. Keys without a
+// date are under the zero time.
+func objectsByDay(keys []string) map[time.Time][]string {
+	days := make(map[time.Time][]string)
+	for _, key := range keys {
+		if !isParquetFile(key) {
+			continue
+		}
+		date, _ := objectDate(key)
+		days[date] = append(days[date], key)
+	}
+	return days
+}
+
+// Whether a retention of `days` deletes `day` by `now`. Parseable deletes
+// whole days, those before the last `days` ones.
+func retentionExpired(day time.Time, now time.Time, days int) bool {
+	return day.Before(retentionDay(now, days))
+}
+
+// Parquet objects of `keys` in days a retention of `days` deletes, sorted.
+func expiredObjects(keys []string, now time.Time, days int) []string {
+	var expired []string
+	for day, dayKeys := range objectsByDay(keys) {
+		if !day.IsZero() && retentionExpired(day, now, days) {
+			expired = append(expired, dayKeys...)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+func retentionBucketCount(client HTTPClient, stream string, now time.Time, bucket RetentionBucket) (uint64, error) {
+	start := retentionDay(now, bucket.Age)
+	return fetchLogStreamCount(client, stream, start, start.AddDate(0, 0, 1))
+}
+
+// Sends every bucket of `retentionBuckets` to `stream` and waits until each
+// day can be queried and has parquet objects in `store`.
+func IngestRetentionBuckets(t *testing.T, client HTTPClient, store ObjectStore, stream string, now time.Time) {
+	for _, bucket := range retentionBuckets {
+		err := client.API().Ingest(stream, retentionEvents(now, bucket), nil)
+		require.NoErrorf(t, err, "Couldn't ingest events %d days old into %s: %s", bucket.Age, stream, err)
+	}
+	for _, bucket := range retentionBuckets {
+		start := retentionDay(now, bucket.Age)
+		waitForCountInWindow(t, client, stream, uint64(bucket.Events), syncTimeout, func() (time.Time, time.Time) {
+			return start, start.AddDate(0, 0, 1)
+		})
+	}
+	keys := WaitForParquetObjects(t, store, stream, len(retentionBuckets), syncTimeout)
+	days := objectsByDay(keys)
+	for _, bucket := range retentionBuckets {
+		require.NotEmptyf(t, days[retentionDay(now, bucket.Age)], "No parquet objects of %s dated %d days ago in %v", stream, bucket.Age, keys)
+	}
+}
+
+// Sets a retention of `testRetentionDays` on `stream`, holding
+// `retentionBuckets`, and waits up to `timeout` for the server to enforce it:
+// the expired days can neither be queried nor listed in `store`, while
+// the others are intact.
+func CheckRetentionEnforced(t *testing.T, client HTTPClient, store ObjectStore, stream string, now time.Time, timeout time.Duration) {
+	err := client.API().SetRetention(stream, retentionRules(testRetentionDays))
+	require.NoErrorf(t, err, "Couldn't set retention on %s: %s", stream, err)
+	rules, err := client.API().Retention(stream)
+	require.NoErrorf(t, err, "Couldn't get retention of %s: %s", stream, err)
+	require.Equal(t, retentionRules(testRetentionDays), rules, "Get retention response doesn't match the retention set")
+	if timeout <= 0 {
+		t.Skip("Parseable enforces retention once a day; set -retention-timeout to wait for it")
+	}
+
+	state, err := pollUntil(timeout, func() (bool, string) {
+		var state []string
+		for _, bucket := range retentionBuckets {
+			if !retentionExpired(retentionDay(now, bucket.Age), now, testRetentionDays) {
+				continue
+			}
+			count, err := retentionBucketCount(client, stream, now, bucket)
+			if err != nil {
+				return false, err.Error()
+			}
+			if count > 0 {
+				state = append(state, fmt.Sprintf("%d events %d days old", count, bucket.Age))
+			}
+		}
+		keys, err := listParquetObjects(stream, store)
+		if err != nil {
+			return false, err.Error()
+		}
+		if expired := expiredObjects(keys, now, testRetentionDays); len(expired) > 0 {
+			state = append(state, fmt.Sprintf("expired objects %v", expired))
+		}
+		return len(state) == 0, strings.Join(state, ", ")
+	})
+	if err != nil {
+		t.Fatalf("Waiting for retention of %d days to be enforced on %s: %s after %s; last observed: %s\n%s",
+			testRetentionDays, stream, err, timeout, state, streamDiagnostics(client, stream))
+	}
+
+	keys, err := listParquetObjects(stream, store)
+	require.NoErrorf(t, err, "Couldn't list parquet objects of %s: %s", stream, err)
+	days := objectsByDay(keys)
+	for _, bucket := range retentionBuckets {
+		if retentionExpired(retentionDay(now, bucket.Age), now, testRetentionDays) {
+			continue
+		}
+		count, err := retentionBucketCount(client, stream, now, bucket)
+		require.NoErrorf(t, err, "Couldn't count events %d days old in %s: %s", bucket.Age, stream, err)
+		require.Equalf(t, uint64(bucket.Events), count, "Retention deleted events %d days old from %s", bucket.Age, stream)
+		require.NotEmptyf(t, days[retentionDay(now, bucket.Age)], "Retention deleted parquet objects %d days old from %s", bucket.Age, stream)
+	}
+}
+
+// Checks that retention deletes old days of a time partitioned stream, and
+// only those, from both queries and the object store.
+func CheckRetentionLifecycle(t *testing.T, client HTTPClient) {
+	stream := NewTestStream(t, client, parseable.StreamOptions{Header: retentionStreamHeader})
+	now := time.Now()
+	IngestRetentionBuckets(t, client, NewGlob.Store, stream, now)
+	CheckRetentionEnforced(t, client, NewGlob.Store, stream, now, NewGlob.Config.RetentionTimeout)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionEvents(t *testing.T) {
+	now := time.Date(2024, 5, 20, 0, 30, 0, 0, time.UTC)
+
+	old := retentionEvents(now, RetentionBucket{Age: 10, Events: 3})
+	require.Len(t, old, 3)
+	require.Equal(t, "2024-05-10T00:00:00.000Z", old[0]["source_time"])
+	require.Equal(t, "2024-05-10T00:02:00.000Z", old[2]["source_time"])
+
+	// Today's events stay in today and in the past, even right after midnight.
+	today := retentionEvents(now, RetentionBucket{Age: 0, Events: 20})
+	require.Equal(t, "2024-05-20T00:00:00.000Z", today[0]["source_time"])
+	require.Equal(t, "2024-05-20T00:28:30.000Z", today[19]["source_time"])
+	today = retentionEvents(time.Date(2024, 5, 20, 0, 0, 2, 0, time.UTC), RetentionBucket{Age: 0, Events: 20})
+	require.Equal(t, "2024-05-20T00:00:00.000Z", today[0]["source_time"])
+	require.Equal(t, "2024-05-20T00:00:01.900Z", today[19]["source_time"])
+}
+
+func TestObjectDate(t *testing.T) {
+	date, ok := objectDate("stream/date=2024-05-10/hour=03/minute=15/host.data.parquet")
+	require.True(t, ok)
+	require.Equal(t, time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC), date)
+
+	_, ok = objectDate("stream/.stream.json")
+	require.False(t, ok)
+	_, ok = objectDate("stream/date=yesterday/x.parquet")
+	require.False(t, ok)
+}
+
+func TestRetentionExpired(t *testing.T) {
+	now := time.Date(2024, 5, 20, 15, 0, 0, 0, time.UTC)
+	require.True(t, retentionExpired(time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC), now, 7))
+	require.False(t, retentionExpired(time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), now, 7))
+	require.False(t, retentionExpired(time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC), now, 7))
+
+	for _, bucket := range retentionBuckets {
+		expired := retentionExpired(retentionDay(now, bucket.Age), now, testRetentionDays)
+		require.Equal(t, bucket.Age > testRetentionDays, expired, "bucket %d days old", bucket.Age)
+	}
+}
+
+func TestExpiredObjects(t *testing.T) {
+	now := time.Date(2024, 5, 20, 15, 0, 0, 0, time.UTC)
+	keys := []string{
+		"s/date=2024-05-10/hour=00/minute=00/b.parquet",
+		"s/date=2024-04-20/hour=00/minute=00/a.parquet",
+		"s/date=2024-04-20/hour=00/minute=00/a.manifest.json",
+		"s/date=2024-05-18/hour=00/minute=00/c.parquet",
+		"s/date=2024-05-20/hour=14/minute=59/d.parquet",
+		"s/e.parquet",
+	}
+	require.Equal(t, []string{
+		"s/date=2024-04-20/hour=00/minute=00/a.parquet",
+		"s/date=2024-05-10/hour=00/minute=00/b.parquet",
+	}, expiredObjects(keys, now, 7))
+
+	days := objectsByDay(keys)
+	require.Len(t, days, 5)
+	require.Equal(t, []string{"s/e.parquet"}, days[time.Time{}])
+}
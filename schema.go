@@ -0,0 +1,239 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"quest/parseable"
+)
+
+// Arrow schema of a stream, as returned by `GET logstream/{stream}/schema`.
+type StreamSchema = parseable.Schema
+
+type SchemaField = parseable.Field
+
+func FetchStreamSchema(client HTTPClient, stream string) (StreamSchema, error) {
+	return client.API().Schema(stream)
+}
+
+// Columns Parseable adds to every event.
+var serverColumns = map[string]bool{
+	"p_timestamp": true,
+	"p_tags":      true,
+	"p_metadata":  true,
+}
+
+// An event, either as sent or as read back from a parquet row, keyed by column.
+type Record map[string]interface{}
+
+// Decodes a JSON array of events, keeping numbers exact.
+func decodeRecords(data []byte) ([]Record, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var records []Record
+	err := decoder.Decode(&records)
+	return records, err
+}
+
+// Converts anything that marshals to a JSON array of objects to records.
+func toRecords(events interface{}) ([]Record, error) {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRecords(data)
+}
+
+func timestampUnit(field SchemaField) time.Duration {
+	var parameterised map[string][]interface{}
+	if json.Unmarshal(field.DataType, &parameterised) == nil {
+		if params := parameterised["Timestamp"]; len(params) > 0 {
+			switch params[0] {
+			case "Second":
+				return time.Second
+			case "Microsecond":
+				return time.Microsecond
+			case "Nanosecond":
+				return time.Nanosecond
+			}
+		}
+	}
+	return time.Millisecond
+}
+
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.000", "2006-01-02T15:04:05", "2006-01-02 15:04:05"}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("can't convert %T to an integer", value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("can't convert %T to a float", value)
+}
+
+// Converts `value` to a canonical Go value for the Arrow type of `field`, so
+// that a value sent as JSON and the same value read back from parquet compare
+// equal. `nil` stays `nil`.
+func coerceValue(field SchemaField, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch typ := field.Type(); {
+	case strings.HasPrefix(typ, "Int"), strings.HasPrefix(typ, "UInt"):
+		return toInt64(value)
+
+	case strings.HasPrefix(typ, "Float"):
+		return toFloat64(value)
+
+	case typ == "Boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+		return nil, fmt.Errorf("can't convert %T to a boolean", value)
+
+	case typ == "Timestamp":
+		if s, ok := value.(string); ok {
+			for _, layout := range timestampLayouts {
+				if ts, err := time.Parse(layout, s); err == nil {
+					return ts.UTC(), nil
+				}
+			}
+			return nil, fmt.Errorf("can't parse timestamp %q", s)
+		}
+		n, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+		unit := timestampUnit(field)
+		return time.Unix(0, 0).Add(time.Duration(n) * unit).UTC(), nil
+
+	case typ == "Utf8", typ == "LargeUtf8":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		return fmt.Sprint(value), nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+type FieldMismatch struct {
+	Field    string      `json:"field"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+func (m FieldMismatch) String() string {
+	if m.Reason != "" {
+		return fmt.Sprintf("%s: %s", m.Field, m.Reason)
+	}
+	return fmt.Sprintf("%s: expected %v, actual %v", m.Field, m.Expected, m.Actual)
+}
+
+// Compares an event that was sent with a row that was read back, field by
+// field, using the types from `schema`. Columns added by the server are
+// ignored, and columns missing from the event must be null in the row.
+func compareRecord(schema StreamSchema, expected Record, actual Record) []FieldMismatch {
+	var mismatches []FieldMismatch
+	for _, field := range schema.Fields {
+		if serverColumns[field.Name] {
+			continue
+		}
+
+		want, err := coerceValue(field, expected[field.Name])
+		if err != nil {
+			mismatches = append(mismatches, FieldMismatch{Field: field.Name, Expected: expected[field.Name], Reason: "sent value: " + err.Error()})
+			continue
+		}
+		got, err := coerceValue(field, actual[field.Name])
+		if err != nil {
+			mismatches = append(mismatches, FieldMismatch{Field: field.Name, Actual: actual[field.Name], Reason: "stored value: " + err.Error()})
+			continue
+		}
+
+		if wantTime, ok := want.(time.Time); ok {
+			gotTime, ok := got.(time.Time)
+			if !ok || !wantTime.Truncate(timestampUnit(field)).Equal(gotTime) {
+				mismatches = append(mismatches, FieldMismatch{Field: field.Name, Expected: want, Actual: got})
+			}
+			continue
+		}
+		if want != got {
+			mismatches = append(mismatches, FieldMismatch{Field: field.Name, Expected: want, Actual: got})
+		}
+	}
+
+	missing := make([]string, 0)
+	for name := range expected {
+		if _, ok := schema.Field(name); !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		mismatches = append(mismatches, FieldMismatch{Field: name, Expected: expected[name], Reason: "field is missing from the stream schema"})
+	}
+	return mismatches
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestStreamSchemaTypes(t *testing.T) {
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(FlogJsonSchema), &schema))
+
+	bytesField, ok := schema.Field("bytes")
+	require.True(t, ok)
+	require.Equal(t, "Int64", bytesField.Type())
+
+	timestamp, ok := schema.Field("p_timestamp")
+	require.True(t, ok)
+	require.Equal(t, "Timestamp", timestamp.Type())
+
+	_, ok = schema.Field("missing")
+	require.False(t, ok)
+}
+
+// Writes `rows` to a parquet file with a schema like the one Parseable
+// writes for `FlogJsonSchema`.
+func writeFlogParquet(t *testing.T, rows ...string) string {
+	path := filepath.Join(t.TempDir(), "flog.parquet")
+	parquetSchema := `{"Tag": "name=schema", "Fields": [
+		{"Tag": "name=p_timestamp, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"},
+		{"Tag": "name=host, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"},
+		{"Tag": "name=user-identifier, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"},
+		{"Tag": "name=status, type=INT64, repetitiontype=OPTIONAL"},
+		{"Tag": "name=bytes, type=INT64, repetitiontype=OPTIONAL"}
+	]}`
+	fw, err := local.NewLocalFileWriter(path)
+	require.NoError(t, err)
+	pw, err := writer.NewJSONWriter(parquetSchema, fw, 1)
+	require.NoError(t, err)
+	for _, row := range rows {
+		require.NoError(t, pw.Write(row))
+	}
+	require.NoError(t, pw.WriteStop())
+	require.NoError(t, fw.Close())
+	return path
+}
+
+func TestReadParquetLocalObject(t *testing.T) {
+	path := writeFlogParquet(t,
+		`{"p_timestamp": 1700000000000, "host": "10.0.0.1", "user-identifier": "-", "status": 200, "bytes": 5000000000}`,
+		`{"p_timestamp": 1700000000001, "host": "10.0.0.2", "status": 404}`,
+	)
+
+	records, err := readParquetObject(NewLocalStore(filepath.Dir(path)), filepath.Base(path))
+	require.NoError(t, err)
+	require.Equal(t, []Record{
+		{"p_timestamp": int64(1700000000000), "host": "10.0.0.1", "user-identifier": "-", "status": int64(200), "bytes": int64(5000000000)},
+		{"p_timestamp": int64(1700000000001), "host": "10.0.0.2", "status": int64(404)},
+	}, records)
+}
+
+func TestCompareRecord(t *testing.T) {
+	var schema StreamSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"fields": [
+		{"name": "p_timestamp", "data_type": {"Timestamp": ["Millisecond", null]}},
+		{"name": "source_time", "data_type": {"Timestamp": ["Millisecond", null]}},
+		{"name": "host", "data_type": "Utf8"},
+		{"name": "bytes", "data_type": "Int64"},
+		{"name": "ratio", "data_type": "Float64"},
+		{"name": "ok", "data_type": "Boolean"}
+	]}`), &schema))
+
+	sent, err := decodeRecords([]byte(`[{"source_time": "2024-03-26T18:08:00.434Z", "host": "a", "bytes": 5000000000, "ratio": 0.5, "ok": true}]`))
+	require.NoError(t, err)
+	stored := Record{"p_timestamp": int64(1), "source_time": int64(1711476480434), "host": "a", "bytes": int64(5000000000), "ratio": 0.5, "ok": true}
+	require.Empty(t, compareRecord(schema, sent[0], stored))
+
+	stored["bytes"] = int64(705032704)
+	delete(stored, "ok")
+	mismatches := compareRecord(schema, sent[0], stored)
+	require.Len(t, mismatches, 2)
+	require.Equal(t, "bytes", mismatches[0].Field)
+	require.Equal(t, "ok", mismatches[1].Field)
+	require.Nil(t, mismatches[1].Actual)
+
+	sent[0]["unknown"] = "x"
+	mismatches = compareRecord(schema, sent[0], Record{"source_time": int64(1711476480434), "host": "a", "bytes": int64(5000000000), "ratio": 0.5, "ok": true})
+	require.Len(t, mismatches, 1)
+	require.Equal(t, "unknown", mismatches[0].Field)
+}
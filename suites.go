@@ -0,0 +1,488 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The tests of the `smoke` and `load` commands, which quest_test.go also runs
+// as TestSmoke and TestLoad. Every test works on streams, users and roles of
+// its own, see fixtures.go, so the smoke tests run in parallel. Load tests
+// don't, they would skew each other's throughput and latency.
+
+// Load profile of the smoke load tests, as the k6 script smoke.js had it: 20 VUs
+// share the iterations, each sending an event a month old of each of 8
+// schemas as a batch and then one by one, `smokeLoadEvents` in all.
+func smokeLoadOptions() LoadOptions {
+	options := DefaultLoadOptions()
+	options.Executor = SharedIterations
+	options.VUs = 20
+	options.Iterations = smokeLoadEvents / 16
+	options.Duration = 10 * time.Minute
+	options.Pause = time.Second
+	options.BatchAndSingle = true
+	options.SchemaCount = 8
+	options.EventsCount = 1
+	options.Historical = true
+	options.Headers = map[string]string{
+		"X-P-META-Source": "quest-smoke-test",
+		"X-P-META-Test":   "Fixed-Logs",
+	}
+	return options
+}
+
+const smokeLoadEvents = 20000
+
+// Load profile of the load tests and the `load` command.
+func questLoadOptions(batch bool, historical bool) LoadOptions {
+	options := DefaultLoadOptions()
+	options.VUs = 10
+	options.Duration = 2 * time.Minute
+	options.SchemaCount = 10
+	options.EventsCount = 5
+	options.Batch = batch
+	options.Historical = historical
+	return options
+}
+
+var smokeTests = []testing.InternalTest{
+	{Name: "ListLogStream", F: smokeListLogStream},
+	{Name: "CreateStream", F: smokeCreateStream},
+	{Name: "IngestEvents", F: smokeIngestEvents},
+	{Name: "TimePartition_TimeStampMismatch", F: smokeTimePartitionTimeStampMismatch},
+	{Name: "TimePartition_NoTimePartitionInLog", F: smokeTimePartitionNoTimePartitionInLog},
+	{Name: "TimePartition_IncorrectDateTimeFormat", F: smokeTimePartitionIncorrectDateTimeFormat},
+	{Name: "StaticSchema_EventWithSameFields", F: smokeStaticSchemaEventWithSameFields},
+	{Name: "StaticSchema_EventWithNewField", F: smokeStaticSchemaEventWithNewField},
+	{Name: "QueryTwoStreams", F: smokeQueryTwoStreams},
+	{Name: "RunQueries", F: smokeRunQueries},
+	{Name: "Load", F: smokeLoad},
+	{Name: "Load_TimePartition", F: smokeLoadTimePartition},
+	{Name: "Load_CustomPartition", F: smokeLoadCustomPartition},
+	{Name: "Load_TimeAndCustomPartition", F: smokeLoadTimeAndCustomPartition},
+	{Name: "Alert", F: smokeAlert},
+	{Name: "AlertDelivery", F: smokeAlertDelivery},
+	{Name: "Retention", F: smokeRetention},
+	{Name: "RetentionEnforced", F: smokeRetentionEnforced},
+	{Name: "AllUsersAPI", F: smokeAllUsersAPI},
+	{Name: "NewUserNoRole", F: smokeNewUserNoRole},
+	{Name: "RbacBasic", F: smokeRbacBasic},
+	{Name: "Roles", F: smokeRoles},
+	{Name: "RBACMatrix", F: smokeRBACMatrix},
+	{Name: "DeleteStream", F: smokeDeleteStream},
+}
+
+var loadTests = []testing.InternalTest{
+	{Name: "BatchEvents", F: loadBatchEvents},
+	{Name: "BatchEvents_StaticSchema", F: loadBatchEventsStaticSchema},
+	{Name: "BatchEvents_Historical", F: loadBatchEventsHistorical},
+	{Name: "BatchEvents_CustomPartition", F: loadBatchEventsCustomPartition},
+	{Name: "BatchEvents_TimeAndCustomPartition", F: loadBatchEventsTimeAndCustomPartition},
+	{Name: "SingleEvents", F: loadSingleEvents},
+	{Name: "SingleEvents_Historical", F: loadSingleEventsHistorical},
+	{Name: "SingleEvents_CustomPartition", F: loadSingleEventsCustomPartition},
+	{Name: "SingleEvents_TimeAndCustomPartition", F: loadSingleEventsTimeAndCustomPartition},
+}
+
+func smokeListLogStream(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	streams, err := glob.QueryClient.API().ListStreams()
+	require.NoErrorf(t, err, "Couldn't list streams: %s", err)
+	require.Containsf(t, streams, parseable.Stream{Name: stream}, "Stream %s isn't listed", stream)
+}
+
+func smokeCreateStream(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	DeleteStream(t, glob.QueryClient, stream)
+}
+
+func smokeIngestEvents(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	run := RunFlog(t, glob.Ingestors, stream)
+
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, stream, run.Accepted)
+	QueryLogStreamCount(t, glob.QueryClient, stream, 50)
+	WaitForSchema(t, glob.QueryClient, stream, FlogJsonSchema, syncTimeout)
+}
+
+func smokeTimePartitionTimeStampMismatch(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time"}
+	historicalStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	IngestOneEventWithTimePartition_TimeStampMismatch(t, glob.IngestClient(), historicalStream)
+}
+
+func smokeTimePartitionNoTimePartitionInLog(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time"}
+	historicalStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	IngestOneEventWithTimePartition_NoTimePartitionInLog(t, glob.IngestClient(), historicalStream)
+}
+
+func smokeTimePartitionIncorrectDateTimeFormat(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time"}
+	historicalStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	IngestOneEventWithTimePartition_IncorrectDateTimeFormatTimePartitionInLog(t, glob.IngestClient(), historicalStream)
+}
+
+func smokeStaticSchemaEventWithSameFields(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	staticSchemaFlagHeader := map[string]string{"X-P-Static-Schema-Flag": "true"}
+	staticSchemaStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{StaticSchema: staticSchemaFields, Header: staticSchemaFlagHeader})
+	IngestOneEventForStaticSchemaStream_SameFieldsInLog(t, glob.IngestClient(), staticSchemaStream)
+}
+
+func smokeStaticSchemaEventWithNewField(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	staticSchemaFlagHeader := map[string]string{"X-P-Static-Schema-Flag": "true"}
+	staticSchemaStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{StaticSchema: staticSchemaFields, Header: staticSchemaFlagHeader})
+	IngestOneEventForStaticSchemaStream_NewFieldInLog(t, glob.IngestClient(), staticSchemaStream)
+}
+
+func smokeQueryTwoStreams(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream1 := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	stream2 := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	// Both streams get their events from one generator, so its seed replays
+	// the fuzzed queries along with -fuzz-seed.
+	generator := NewTestLogGenerator(t)
+	model := NewEventModel()
+	model.Add(stream1, ingestFlogs(t, glob.Ingestors, stream1, generator.Flogs(50)).Events...)
+	model.Add(stream2, ingestFlogs(t, glob.Ingestors, stream2, generator.Flogs(50)).Events...)
+	WaitForCount(t, glob.QueryClient, stream1, 50, syncTimeout)
+	WaitForCount(t, glob.QueryClient, stream2, 50, syncTimeout)
+	QueryTwoLogStreamCount(t, glob.QueryClient, stream1, stream2, 100)
+	FuzzQueries(t, glob.QueryClient, model, glob.Config.FuzzSeed, generator.Seed(), glob.Config.FuzzQueries, stream1, stream2)
+}
+
+func smokeRunQueries(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	run := RunFlog(t, glob.Ingestors, stream)
+	WaitForCount(t, glob.QueryClient, stream, 50, syncTimeout)
+	// test count
+	QueryLogStreamCount(t, glob.QueryClient, stream, 50)
+	// test yeild all values
+	AssertQueryOK(t, glob.QueryClient, "SELECT * FROM %s", stream)
+	AssertQueryOK(t, glob.QueryClient, "SELECT * FROM %s OFFSET 25 LIMIT 25", stream)
+	// test fetch single column
+	for _, item := range flogStreamFields() {
+		AssertQueryOK(t, glob.QueryClient, "SELECT %s FROM %s", item, stream)
+	}
+	// test basic filter
+	AssertQueryOK(t, glob.QueryClient, "SELECT * FROM %s WHERE method = 'POST'", stream)
+	// test group by
+	AssertQueryOK(t, glob.QueryClient, "SELECT method, COUNT(*) FROM %s GROUP BY method", stream)
+	AssertQueryOK(t, glob.QueryClient, `SELECT DATE_TRUNC('minute', p_timestamp) as minute, COUNT(*) FROM %s GROUP BY minute`, stream)
+	// test results against the events sent
+	AssertOracleQueries(t, glob.QueryClient, stream, run.Events, FlogOracleQueries())
+}
+
+func smokeLoad(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	RunLoadTest(t, glob.Ingestors, stream, smokeLoadOptions())
+	WaitForCount(t, glob.QueryClient, stream, smokeLoadEvents, syncTimeout)
+	WaitForSchema(t, glob.QueryClient, stream, SchemaBody, syncTimeout)
+}
+
+func smokeLoadTimePartition(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time", "X-P-Time-Partition-Limit": "365d"}
+	time_partition_stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	RunLoadTest(t, glob.Ingestors, time_partition_stream, smokeLoadOptions())
+	WaitForHistoricalCount(t, glob.QueryClient, time_partition_stream, smokeLoadEvents, syncTimeout)
+	AssertObjectLayout(t, glob.QueryClient, glob.Store, time_partition_stream)
+}
+
+func smokeLoadCustomPartition(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level"}
+	custom_partition_stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	RunLoadTest(t, glob.Ingestors, custom_partition_stream, smokeLoadOptions())
+	WaitForCount(t, glob.QueryClient, custom_partition_stream, smokeLoadEvents, syncTimeout)
+	AssertObjectLayout(t, glob.QueryClient, glob.Store, custom_partition_stream)
+}
+
+func smokeLoadTimeAndCustomPartition(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level", "X-P-Time-Partition": "source_time", "X-P-Time-Partition-Limit": "365d"}
+	custom_partition_stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	RunLoadTest(t, glob.Ingestors, custom_partition_stream, smokeLoadOptions())
+	WaitForHistoricalCount(t, glob.QueryClient, custom_partition_stream, smokeLoadEvents, syncTimeout)
+	AssertObjectLayout(t, glob.QueryClient, glob.Store, custom_partition_stream)
+}
+
+func smokeAlert(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	if glob.IngestorUrl.String() == "" {
+		RunFlog(t, glob.Ingestors, stream)
+		SetAlert(t, glob.QueryClient, stream, AlertBody)
+		AssertAlert(t, glob.QueryClient, stream, AlertBody)
+	}
+}
+
+func smokeAlertDelivery(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	CheckAlertLifecycle(t, glob.QueryClient)
+}
+
+func smokeRetention(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	SetRetention(t, glob.QueryClient, stream, RetentionBody)
+	AssertRetention(t, glob.QueryClient, stream, RetentionBody)
+}
+
+func smokeRetentionEnforced(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	CheckRetentionLifecycle(t, glob.QueryClient)
+}
+
+// This test calls all the User API endpoints
+// in a sequence to check if they work as expected.
+func smokeAllUsersAPI(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	role := NewTestRole(t, glob.QueryClient, "dummyrole", dummyRole)
+	AssertRole(t, glob.QueryClient, role, dummyRole)
+
+	user := UniqueName(fixturePrefix, "dummyuser")
+	TrackUser(t, glob.QueryClient, user)
+	CreateUser(t, glob.QueryClient, user)
+	AssignRolesToUser(t, glob.QueryClient, user, []string{role})
+	AssertUserRole(t, glob.QueryClient, user, role, dummyRole)
+	RegenPassword(t, glob.QueryClient, user)
+	DeleteUser(t, glob.QueryClient, user)
+
+	CreateUserWithRole(t, glob.QueryClient, user, []string{role})
+	AssertUserRole(t, glob.QueryClient, user, role, dummyRole)
+	RegenPassword(t, glob.QueryClient, user)
+	DeleteUser(t, glob.QueryClient, user)
+}
+
+// This test checks that a new user doesn't get any role by default
+// even if a default role is set.
+// Serial: the default role is the same for the whole server.
+func smokeNewUserNoRole(t *testing.T) {
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+
+	previous, err := glob.QueryClient.API().DefaultRole()
+	require.NoErrorf(t, err, "Couldn't get the default role: %s", err)
+	// Parseable can't unset the default role, so without one to restore the
+	// role stays the default and isn't deleted either.
+	role := UniqueName(fixturePrefix, "dummyrole")
+	CreateRole(t, glob.QueryClient, role, dummyRole)
+	if previous != "" {
+		TrackRole(t, glob.QueryClient, role)
+	}
+	// Runs before the role is deleted.
+	t.Cleanup(func() {
+		if previous == "" {
+			t.Errorf("No default role was set before the test and it can't be unset, %s stays the default and is kept", role)
+			return
+		}
+		if err := glob.QueryClient.API().SetDefaultRole(previous); err != nil {
+			t.Errorf("Couldn't restore the default role %s: %s", previous, err)
+		}
+	})
+	SetDefaultRole(t, glob.QueryClient, role)
+	AssertDefaultRole(t, glob.QueryClient, role)
+
+	user, password := NewTestUser(t, glob.QueryClient, "dummyuser", nil)
+	userClient := glob.QueryClient
+	userClient.Username = user
+	userClient.Password = password
+
+	PutSingleEventExpectErr(t, userClient, stream)
+}
+
+func smokeRbacBasic(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	role := NewTestRole(t, glob.QueryClient, "dummy", dummyRole)
+	AssertRole(t, glob.QueryClient, role, dummyRole)
+	user, _ := NewTestUser(t, glob.QueryClient, "dummy", []string{role})
+	userClient := glob.QueryClient
+	userClient.Username = user
+	userClient.Password = RegenPassword(t, glob.QueryClient, user)
+	checkAPIAccess(t, userClient, stream, "editor")
+}
+
+func smokeRoles(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	cases := []struct {
+		roleName string
+		body     string
+	}{
+		{
+			roleName: "editor",
+			body:     RoleEditor,
+		},
+		{
+			roleName: "reader",
+			body:     RoleReader(stream),
+		},
+		{
+			roleName: "writer",
+			body:     RoleWriter(stream),
+		},
+		{
+			roleName: "ingestor",
+			body:     Roleingestor(stream),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.roleName, func(t *testing.T) {
+			t.Parallel()
+			role := NewTestRole(t, glob.QueryClient, tc.roleName, tc.body)
+			AssertRole(t, glob.QueryClient, role, tc.body)
+			username, password := NewTestUser(t, glob.QueryClient, tc.roleName+"_user", []string{role})
+
+			userClient := glob.QueryClient
+			userClient.Username = username
+			userClient.Password = password
+			checkAPIAccess(t, userClient, stream, tc.roleName)
+		})
+	}
+}
+
+// Checks every privilege, on its own stream, another stream and a tag,
+// against every endpoint; see rbac.go.
+func smokeRBACMatrix(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	CheckRBACMatrix(t, glob.QueryClient)
+}
+
+func smokeDeleteStream(t *testing.T) {
+	t.Parallel()
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	DeleteStream(t, glob.QueryClient, stream)
+}
+
+func loadBatchEvents(t *testing.T) {
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	result := RunLoadTest(t, glob.Ingestors, stream, questLoadOptions(true, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, stream, result.Accepted)
+}
+
+func loadBatchEventsStaticSchema(t *testing.T) {
+	glob := NewGlob.For(t)
+	staticSchemaFlagHeader := map[string]string{"X-P-Static-Schema-Flag": "true"}
+	staticSchemaStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{StaticSchema: staticSchemaFields, Header: staticSchemaFlagHeader})
+	result := RunLoadTest(t, glob.Ingestors, staticSchemaStream, questLoadOptions(true, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, staticSchemaStream, result.Accepted)
+}
+
+func loadBatchEventsHistorical(t *testing.T) {
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time"}
+	historicalStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	result := RunLoadTest(t, glob.Ingestors, historicalStream, questLoadOptions(true, true))
+	CheckPerf(t, glob.Perf, result)
+}
+
+func loadBatchEventsCustomPartition(t *testing.T) {
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level,os"}
+	customPartitionStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	result := RunLoadTest(t, glob.Ingestors, customPartitionStream, questLoadOptions(true, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, customPartitionStream, result.Accepted)
+}
+
+func loadBatchEventsTimeAndCustomPartition(t *testing.T) {
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level,os", "X-P-Time-Partition": "source_time"}
+	customPartitionStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	result := RunLoadTest(t, glob.Ingestors, customPartitionStream, questLoadOptions(true, true))
+	CheckPerf(t, glob.Perf, result)
+}
+
+func loadSingleEvents(t *testing.T) {
+	glob := NewGlob.For(t)
+	stream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{})
+	result := RunLoadTest(t, glob.Ingestors, stream, questLoadOptions(false, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, stream, result.Accepted)
+}
+
+func loadSingleEventsHistorical(t *testing.T) {
+	glob := NewGlob.For(t)
+	timeHeader := map[string]string{"X-P-Time-Partition": "source_time"}
+	historicalStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: timeHeader})
+	result := RunLoadTest(t, glob.Ingestors, historicalStream, questLoadOptions(false, false))
+	CheckPerf(t, glob.Perf, result)
+}
+
+func loadSingleEventsCustomPartition(t *testing.T) {
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level,os"}
+	customPartitionStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	result := RunLoadTest(t, glob.Ingestors, customPartitionStream, questLoadOptions(false, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, customPartitionStream, result.Accepted)
+}
+
+func loadSingleEventsTimeAndCustomPartition(t *testing.T) {
+	glob := NewGlob.For(t)
+	customHeader := map[string]string{"X-P-Custom-Partition": "level,os", "X-P-Time-Partition": "source_time"}
+	customPartitionStream := NewTestStream(t, glob.QueryClient, parseable.StreamOptions{Header: customHeader})
+	result := RunLoadTest(t, glob.Ingestors, customPartitionStream, questLoadOptions(false, false))
+	CheckPerf(t, glob.Perf, result)
+	VerifyIngestorCounts(t, glob.QueryClient, glob.Ingestors, customPartitionStream, result.Accepted)
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"quest/parseable"
+)
+
+// Stream, user or role that a quest run may have left behind.
+type Orphan struct {
+	// `stream`, `user` or `role`.
+	Kind string
+	Name string
+	// Zero when not known.
+	CreatedAt time.Time
+	// Named like older versions of quest named things, rather than by
+	// `UniqueName`, see `-cleanup-legacy`.
+	Legacy bool
+}
+
+// Suffixes the tests of older versions appended to `-stream`.
+const legacyStreamSuffixes = `(1|2|historical|staticschema|timepartition|custompartition|timecustompartition|timeandcustompartition|faultproxy)`
+
+var (
+	// Streams of older runs: the ten random letters main.sh passes as
+	// `-stream`, on their own or with a suffix.
+	legacyStreamName = regexp.MustCompile(`^[a-z]{10}` + legacyStreamSuffixes + `?$`)
+	// Users and roles of older runs, which had fixed names.
+	legacyUserName = regexp.MustCompile(`^(dummy|dummyuser|(editor|reader|writer|ingestor)_user)$`)
+	legacyRoleName = regexp.MustCompile(`^(dummy|dummyrole)$`)
+	// Names made by `UniqueName`, less the prefix.
+	fixtureName = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// Whether `name` was made by `UniqueName` from one of `prefixes`, and when.
+func fixtureCreatedAt(name string, prefixes []string, now time.Time) (time.Time, bool) {
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(name, prefix) || !fixtureName.MatchString(name[len(prefix):]) {
+			continue
+		}
+		if created, ok := nameCreatedAt(name, now); ok {
+			return created, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Fields of the events quest sends: flog's, the load generator's and those of
+// `PutSingleEvent`.
+var questFields = []string{"user-identifier", "app_meta", "maxRunDistance"}
+
+// Whether `schema` is empty, as for a stream nothing was sent to, or has a
+// field of the events quest sends. The names of older versions are too common
+// to go by the name alone.
+func questSchema(schema parseable.Schema) bool {
+	empty := true
+	for _, field := range schema.Fields {
+		if serverColumns[field.Name] {
+			continue
+		}
+		empty = false
+		for _, name := range questFields {
+			if field.Name == name {
+				return true
+			}
+		}
+	}
+	return empty
+}
+
+func parseCreatedAt(info parseable.StreamInfo) time.Time {
+	for _, value := range []string{info.CreatedAt, info.FirstEventAt} {
+		if created, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return created
+		}
+	}
+	return time.Time{}
+}
+
+// Lists the streams, users and roles on the server named the way quest names
+// them: with `UniqueName` from `streamPrefix` or from main.sh's ten random
+// letters, and the fixed names of older versions. Streams with the names of
+// older versions must also hold quest's events, see `questSchema`. They are
+// ordered users first, then roles, then streams, which is the order they can
+// be deleted in.
+func FindOrphans(api *parseable.Client, streamPrefix string, now time.Time) ([]Orphan, error) {
+	var orphans []Orphan
+
+	users, err := api.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if created, ok := fixtureCreatedAt(user, []string{fixturePrefix}, now); ok {
+			orphans = append(orphans, Orphan{"user", user, created, false})
+		} else if legacyUserName.MatchString(user) {
+			orphans = append(orphans, Orphan{"user", user, time.Time{}, true})
+		}
+	}
+
+	roles, err := api.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	// Deleting the default role would leave Parseable pointing at a missing
+	// role, see `smokeNewUserNoRole`.
+	defaultRole, err := api.DefaultRole()
+	if err != nil {
+		return nil, err
+	}
+	for role := range roles {
+		if role == defaultRole {
+			continue
+		}
+		if created, ok := fixtureCreatedAt(role, []string{fixturePrefix}, now); ok {
+			orphans = append(orphans, Orphan{"role", role, created, false})
+		} else if legacyRoleName.MatchString(role) {
+			orphans = append(orphans, Orphan{"role", role, time.Time{}, true})
+		}
+	}
+
+	streams, err := api.ListStreams()
+	if err != nil {
+		return nil, err
+	}
+	// `-stream` on its own may well be a stream someone else uses.
+	prefixed := regexp.MustCompile(`^` + regexp.QuoteMeta(streamPrefix) + legacyStreamSuffixes + `$`)
+	for _, stream := range streams {
+		name := stream.Name
+		prefixes := []string{streamPrefix}
+		if len(name) > 10 && legacyStreamName.MatchString(name[:10]) {
+			prefixes = append(prefixes, name[:10])
+		}
+		if created, ok := fixtureCreatedAt(name, prefixes, now); ok {
+			orphans = append(orphans, Orphan{"stream", name, created, false})
+		} else if legacyStreamName.MatchString(name) || prefixed.MatchString(name) {
+			schema, err := api.Schema(name)
+			if err != nil || !questSchema(schema) {
+				continue
+			}
+			// Missing info only leaves the age unknown.
+			info, _ := api.Info(name)
+			orphans = append(orphans, Orphan{"stream", name, parseCreatedAt(info), true})
+		}
+	}
+
+	sortOrphans(orphans)
+	return orphans, nil
+}
+
+func sortOrphans(orphans []Orphan) {
+	rank := map[string]int{"user": 0, "role": 1, "stream": 2}
+	sort.SliceStable(orphans, func(i, j int) bool {
+		if rank[orphans[i].Kind] != rank[orphans[j].Kind] {
+			return rank[orphans[i].Kind] < rank[orphans[j].Kind]
+		}
+		return orphans[i].Name < orphans[j].Name
+	})
+}
+
+// Whether `orphan` is at least `minAge` old. Legacy orphans are kept unless
+// `legacy` is set, their names may just look like quest's and belong to
+// someone else's streams and accounts. Streams of unknown age are kept all
+// the same, legacy users and roles never have one.
+func (orphan Orphan) Expired(now time.Time, minAge time.Duration, legacy bool) bool {
+	if orphan.Legacy && !legacy {
+		return false
+	}
+	if orphan.CreatedAt.IsZero() {
+		return orphan.Legacy && orphan.Kind != "stream"
+	}
+	return now.Sub(orphan.CreatedAt) >= minAge
+}
+
+// How old `orphan` is, for the output of `cleanup`.
+func (orphan Orphan) Age(now time.Time) string {
+	if orphan.CreatedAt.IsZero() {
+		return "age unknown"
+	}
+	return "created " + now.Sub(orphan.CreatedAt).Truncate(time.Second).String() + " ago"
+}
+
+func (orphan Orphan) Delete(api *parseable.Client) error {
+	switch orphan.Kind {
+	case "user":
+		return api.DeleteUser(orphan.Name)
+	case "role":
+		return api.DeleteRole(orphan.Name)
+	default:
+		return api.DeleteStream(orphan.Name)
+	}
+}
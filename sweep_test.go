@@ -0,0 +1,126 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A name `UniqueName` would have made at `created`.
+func nameAt(prefix string, hint string, created time.Time) string {
+	return fmt.Sprintf("%s%s%06sbeef", prefix, hint, strconv.FormatInt(created.Unix(), 36))
+}
+
+func TestFindOrphans(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	now := time.Now()
+	old := now.Add(-3 * time.Hour).Truncate(time.Second)
+
+	oldStream := nameAt("app", "smokeroles", old)
+	newStream := UniqueName("app", "SmokeRoles")
+	mainStream := nameAt("qwertyuiop", "integrity", old)
+	for _, stream := range []string{oldStream, newStream, mainStream, "qwertyuiop", "asdfghjklzhistorical", "apphistorical", "app", "app1", "monitoring1", "production", "kubernetes", "kubernetesprod"} {
+		CreateStream(t, client, stream)
+	}
+	fake.Backdate("qwertyuiop", old)
+
+	oldRole := nameAt(fixturePrefix, "reader", old)
+	defaultRole := nameAt(fixturePrefix, "dummyrole", old)
+	for _, role := range []string{oldRole, defaultRole, "dummyrole", "dummyadmin", "reader"} {
+		CreateRole(t, client, role, dummyRole)
+	}
+	SetDefaultRole(t, client, defaultRole)
+	oldUser := nameAt(fixturePrefix, "readeruser", old)
+	for _, user := range []string{oldUser, "dummy", "editor_user", "support_user", "alice"} {
+		CreateUser(t, client, user)
+	}
+
+	fakeIngest(t, client, "qwertyuiop", fakeFlogs(1))
+	for _, stream := range []string{"production", "app1", "monitoring1"} {
+		fakeIngest(t, client, stream, map[string]string{"level": "info"})
+		fake.Backdate(stream, old)
+	}
+
+	orphans, err := FindOrphans(client.API(), "app", now)
+	require.NoError(t, err)
+	expected := []struct {
+		kind    string
+		name    string
+		created time.Time
+	}{
+		{"user", "dummy", time.Time{}},
+		{"user", "editor_user", time.Time{}},
+		{"user", oldUser, old},
+		{"role", "dummyrole", time.Time{}},
+		{"role", oldRole, old},
+		{"stream", "apphistorical", now},
+		{"stream", oldStream, old},
+		{"stream", newStream, now},
+		{"stream", "asdfghjklzhistorical", now},
+		{"stream", "kubernetes", now},
+		{"stream", "qwertyuiop", old},
+		{"stream", mainStream, old},
+	}
+	require.Len(t, orphans, len(expected), "%v", orphans)
+	for i, e := range expected {
+		require.Equal(t, e.kind, orphans[i].Kind)
+		require.Equal(t, e.name, orphans[i].Name)
+		require.WithinDuration(t, e.created, orphans[i].CreatedAt, 2*time.Second, e.name)
+	}
+
+	var expired, legacy []string
+	for _, orphan := range orphans {
+		if orphan.Expired(now, time.Hour, false) {
+			expired = append(expired, orphan.Name)
+		}
+		if orphan.Expired(now, time.Hour, true) {
+			legacy = append(legacy, orphan.Name)
+			require.NoError(t, orphan.Delete(client.API()))
+		}
+	}
+	require.Equal(t, []string{oldUser, oldRole, oldStream, mainStream}, expired)
+	require.Equal(t, []string{"dummy", "editor_user", oldUser, "dummyrole", oldRole, oldStream, "qwertyuiop", mainStream}, legacy)
+
+	streams, err := client.API().ListStreams()
+	require.NoError(t, err)
+	require.Len(t, streams, 9)
+	users, err := client.API().ListUsers()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"alice", "support_user"}, users)
+	roles, err := client.API().ListRoles()
+	require.NoError(t, err)
+	require.Contains(t, roles, defaultRole, "The default role is kept")
+}
+
+func TestOrphanExpired(t *testing.T) {
+	now := time.Now()
+	require.False(t, Orphan{"stream", "qwertyuiop", time.Time{}, true}.Expired(now, 0, true), "Streams of unknown age are kept")
+	require.False(t, Orphan{"stream", "qwertyuiop", now.Add(-time.Hour), true}.Expired(now, 0, false), "Legacy streams are kept")
+	require.True(t, Orphan{"stream", "qwertyuiop", now.Add(-time.Hour), true}.Expired(now, time.Hour, true))
+	require.False(t, Orphan{"user", "dummy", time.Time{}, true}.Expired(now, 0, false), "Legacy users are kept")
+	require.True(t, Orphan{"user", "dummy", time.Time{}, true}.Expired(now, time.Hour, true))
+	require.False(t, Orphan{"role", "questreader", now.Add(-time.Minute), false}.Expired(now, time.Hour, true))
+	require.True(t, Orphan{"role", "questreader", now.Add(-time.Hour), false}.Expired(now, time.Hour, false))
+	require.Equal(t, "created 1h0m0s ago", Orphan{"role", "questreader", now.Add(-time.Hour), false}.Age(now))
+	require.Equal(t, "age unknown", Orphan{"user", "dummy", time.Time{}, true}.Age(now))
+}
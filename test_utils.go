@@ -17,15 +17,15 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"os/exec"
 	"strings"
 	"testing"
 	"time"
 
+	"quest/parseable"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -61,384 +61,326 @@ func readJsonBody[T any](body io.Reader) (res T, err error) {
 	return
 }
 
+// Decodes the JSON `data` into a `T`, e.g. one of the expected bodies of
+// model.go into the type the API takes.
+func decodeJSON[T any](t *testing.T, data string) T {
+	var value T
+	err := json.Unmarshal([]byte(data), &value)
+	require.NoErrorf(t, err, "Invalid JSON %s: %s", data, err)
+	return value
+}
+
+// Fails unless `err` is an `*parseable.APIError` with status `code`.
+func requireAPIStatus(t *testing.T, err error, code int) *parseable.APIError {
+	var apiErr *parseable.APIError
+	require.ErrorAsf(t, err, &apiErr, "Expected http code %d, got: %v", code, err)
+	require.Equalf(t, code, apiErr.StatusCode, "Server returned http code: %s and response: %s", apiErr.Status, apiErr.Body)
+	return apiErr
+}
+
 func Sleep() {
 	time.Sleep(sleepDuration)
 }
 
 func CreateStream(t *testing.T, client HTTPClient, stream string) {
-	req, _ := client.NewRequest("PUT", "logstream/"+stream, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s", response.Status)
+	err := client.API().CreateStream(stream, parseable.StreamOptions{})
+	require.NoErrorf(t, err, "Couldn't create stream %s: %s", stream, err)
 }
 
 func CreateStreamWithHeader(t *testing.T, client HTTPClient, stream string, header map[string]string) {
-	req, _ := client.NewRequest("PUT", "logstream/"+stream, nil)
-	for k, v := range header {
-		req.Header.Add(k, v)
-	}
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s", response.Status)
+	err := client.API().CreateStream(stream, parseable.StreamOptions{Header: header})
+	require.NoErrorf(t, err, "Couldn't create stream %s: %s", stream, err)
+}
+
+// Static schema of the static schema streams the tests create.
+var staticSchemaFields = []parseable.StaticField{
+	{Name: "source_time", DataType: "string"},
+	{Name: "level", DataType: "string"},
+	{Name: "message", DataType: "string"},
+	{Name: "version", DataType: "string"},
+	{Name: "user_id", DataType: "int"},
+	{Name: "device_id", DataType: "int"},
+	{Name: "session_id", DataType: "string"},
+	{Name: "os", DataType: "string"},
+	{Name: "host", DataType: "string"},
+	{Name: "uuid", DataType: "string"},
+	{Name: "location", DataType: "string"},
+	{Name: "timezone", DataType: "string"},
+	{Name: "user_agent", DataType: "string"},
+	{Name: "runtime", DataType: "string"},
+	{Name: "request_body", DataType: "string"},
+	{Name: "status_code", DataType: "int"},
+	{Name: "response_time", DataType: "int"},
+	{Name: "process_id", DataType: "int"},
+	{Name: "app_meta", DataType: "string"},
 }
 
 func CreateStreamWithSchemaBody(t *testing.T, client HTTPClient, stream string, header map[string]string) {
-	var schema_payload string = `{
-		"fields":[
-		 {
-			 "name": "source_time",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "level",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "message",
-			 "data_type": "string"
-		 },
-         {
-			 "name": "version",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "user_id",
-			 "data_type": "int"
-		 },
-		 {
-			 "name": "device_id",
-			 "data_type": "int"
-		 },
-		 {
-			 "name": "session_id",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "os",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "host",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "uuid",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "location",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "timezone",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "user_agent",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "runtime",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "request_body",
-			 "data_type": "string"
-		 },
-		 {
-			 "name": "status_code",
-			 "data_type": "int"
-		 },
-		 {
-			 "name": "response_time",
-			 "data_type": "int"
-		 },
-		 {
-			 "name": "process_id",
-			 "data_type": "int"
-		 },
-		 {
-			 "name": "app_meta",
-			 "data_type": "string"
-		 }
-	 ]
-	 }`
-	req, _ := client.NewRequest("PUT", "logstream/"+stream, bytes.NewBufferString(schema_payload))
-	for k, v := range header {
-		req.Header.Add(k, v)
-	}
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s", response.Status)
+	err := client.API().CreateStream(stream, parseable.StreamOptions{StaticSchema: staticSchemaFields, Header: header})
+	require.NoErrorf(t, err, "Couldn't create stream %s: %s", stream, err)
 }
 
 func DeleteStream(t *testing.T, client HTTPClient, stream string) {
-	req, _ := client.NewRequest("DELETE", "logstream/"+stream, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s", response.Status)
-}
-
-func RunFlog(t *testing.T, client HTTPClient, stream string) {
-	cmd := exec.Command("flog", "-f", "json", "-n", "50")
-	var out strings.Builder
-	cmd.Stdout = &out
-	err := cmd.Run()
-	require.NoErrorf(t, err, "Failed to run flog: %s", err)
-
-	for _, obj := range strings.SplitN(out.String(), "\n", 50) {
-		var payload strings.Builder
-		payload.WriteRune('[')
-		payload.WriteString(obj)
-		payload.WriteRune(']')
-
-		req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(payload.String()))
-		req.Header.Add("X-P-Stream", stream)
-		response, err := client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().DeleteStream(stream)
+	require.NoErrorf(t, err, "Couldn't delete stream %s: %s", stream, err)
+}
+
+// Generator of the events a test sends, seeded with `-log-seed`. Logs the
+// seed, so that a failure can be replayed.
+func NewTestLogGenerator(t *testing.T) *LogGenerator {
+	generator := NewLogGenerator(NewGlob.Config.LogSeed, time.Now())
+	t.Logf("Generating events with -log-seed=%d", generator.Seed())
+	return generator
+}
+
+// Ingests 50 flog events into `stream`, one request each, spread across
+// `ingestors`. Returns the events sent and what each ingestor accepted.
+func RunFlog(t *testing.T, ingestors *IngestorPool, stream string) IngestRun {
+	return ingestFlogs(t, ingestors, stream, NewTestLogGenerator(t).Flogs(50))
+}
+
+func ingestFlogs(t *testing.T, ingestors *IngestorPool, stream string, flogs []Flog) IngestRun {
+	accepted := ingestors.NewTally()
+	events := make([]ModelEvent, 0, len(flogs))
+	for _, flog := range flogs {
+		payload, err := json.Marshal([]Flog{flog})
+		require.NoErrorf(t, err, "Couldn't marshal %v: %s", flog, err)
+		records, err := decodeRecords(payload)
+		require.NoErrorf(t, err, "Generated an invalid event %s: %s", payload, err)
+
+		node, client := ingestors.Pick(stream)
+		sent := time.Now()
+		err = client.API().Ingest(stream, payload, nil)
+		require.NoErrorf(t, err, "Couldn't ingest into %s through %s: %s", stream, client.Url.Host, err)
+		accepted.Add(node, len(records))
+		for _, record := range records {
+			events = append(events, ModelEvent{Fields: record, Sent: sent, Acked: time.Now()})
+		}
+	}
+	return IngestRun{Accepted: accepted.Counts(), Events: events}
+}
+
+// Runs `RunLoadOn` against `stream` and fails the test if any request failed.
+func RunLoadTest(t *testing.T, ingestors *IngestorPool, stream string, options LoadOptions) LoadResult {
+	result, err := RunLoadOn(ingestors, stream, options)
+	require.NoErrorf(t, err, "Load run failed: %s", err)
+	t.Logf("Load result for stream %s: %s", stream, result)
+	require.NotZerof(t, result.Requests, "No requests were sent to stream %s", stream)
+	require.Zerof(t, result.Errors, "%d of %d requests failed, status codes: %v", result.Errors, result.Requests, result.StatusCodes)
+	return result
+}
+
+// Saves the numbers of a load run and compares them against the baseline, as
+// `gate` is configured to, under the name of the test in suites.go. Does
+// nothing when `gate` is nil.
+func CheckPerf(t *testing.T, gate *PerfGate, result LoadResult) {
+	if gate == nil {
+		return
 	}
+	name := suiteTestName(t)
+	metrics := PerfMetricsOf(result)
+	t.Logf("Performance of %s: %s", name, metrics)
+
+	var regressions []PerfRegression
+	if gate.BaselinePath != "" {
+		var found bool
+		var err error
+		regressions, found, err = gate.Compare(name, metrics)
+		require.NoErrorf(t, err, "Couldn't read the performance baseline: %s", err)
+		if !found {
+			t.Logf("No baseline for %s in %s, not comparing", name, gate.BaselinePath)
+		}
+	}
+	if gate.SavePath != "" {
+		err := gate.Record(name, metrics)
+		require.NoErrorf(t, err, "Couldn't save the performance baseline: %s", err)
+	}
+	require.Emptyf(t, regressions, "%s regressed against the baseline in %s: %s", name, gate.BaselinePath, formatRegressions(regressions))
+}
+
+// Name of the suites.go test `t` runs, which is the same whether `quest`
+// runs it or `go test` does, as a subtest of `TestSmoke` or `TestLoad`.
+func suiteTestName(t *testing.T) string {
+	for _, parent := range []string{"TestSmoke/", "TestLoad/"} {
+		if name, ok := strings.CutPrefix(t.Name(), parent); ok {
+			return name
+		}
+	}
+	return t.Name()
+}
+
+// Waits for the query node to count as many events in `stream` as the
+// ingestors of `ingestors` accepted in total, `accepted` holding the count of
+// each ingestor.
+func VerifyIngestorCounts(t *testing.T, client HTTPClient, ingestors *IngestorPool, stream string, accepted []uint64) {
+	require.Lenf(t, accepted, len(ingestors.Clients), "Got counts for %d ingestors, the pool has %d", len(accepted), len(ingestors.Clients))
+	for i, count := range accepted {
+		t.Logf("Ingestor %s accepted %d events for stream %s", ingestors.Clients[i].Url.Host, count, stream)
+	}
+	WaitForCount(t, client, stream, sumCounts(accepted), syncTimeout)
 }
 
 func IngestOneEventWithTimePartition_TimeStampMismatch(t *testing.T, client HTTPClient, stream string) {
 	var test_payload string = `{"source_time":"2024-03-26T18:08:00.434Z","level":"info","message":"Application is failing","version":"1.2.0","user_id":13912,"device_id":4138,"session_id":"abc","os":"Windows","host":"112.168.1.110","location":"ngeuprqhynuvpxgp","request_body":"rnkmffyawtdcindtrdqruyxbndbjpfsptzpwtujbmkwcqastmxwbvjwphmyvpnhordwljnodxhtvpjesjldtifswqbpyuhlcytmm","status_code":300,"app_meta":"ckgpibhmlusqqfunnpxbfxbc", "new_field_added_by":"ingestor 8020"}`
-	req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(test_payload))
-	req.Header.Add("X-P-Stream", stream)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 400, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().Ingest(stream, []byte(test_payload), nil)
+	requireAPIStatus(t, err, 400)
 }
 
 func IngestOneEventWithTimePartition_NoTimePartitionInLog(t *testing.T, client HTTPClient, stream string) {
 	var test_payload string = `{"level":"info","message":"Application is failing","version":"1.2.0","user_id":13912,"device_id":4138,"session_id":"abc","os":"Windows","host":"112.168.1.110","location":"ngeuprqhynuvpxgp","request_body":"rnkmffyawtdcindtrdqruyxbndbjpfsptzpwtujbmkwcqastmxwbvjwphmyvpnhordwljnodxhtvpjesjldtifswqbpyuhlcytmm","status_code":300,"app_meta":"ckgpibhmlusqqfunnpxbfxbc", "new_field_added_by":"ingestor 8020"}`
-	req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(test_payload))
-	req.Header.Add("X-P-Stream", stream)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 400, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().Ingest(stream, []byte(test_payload), nil)
+	requireAPIStatus(t, err, 400)
 }
 
 func IngestOneEventWithTimePartition_IncorrectDateTimeFormatTimePartitionInLog(t *testing.T, client HTTPClient, stream string) {
 	var test_payload string = `{"source_time":"2024-03-26", "level":"info","message":"Application is failing","version":"1.2.0","user_id":13912,"device_id":4138,"session_id":"abc","os":"Windows","host":"112.168.1.110","location":"ngeuprqhynuvpxgp","request_body":"rnkmffyawtdcindtrdqruyxbndbjpfsptzpwtujbmkwcqastmxwbvjwphmyvpnhordwljnodxhtvpjesjldtifswqbpyuhlcytmm","status_code":300,"app_meta":"ckgpibhmlusqqfunnpxbfxbc", "new_field_added_by":"ingestor 8020"}`
-	req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(test_payload))
-	req.Header.Add("X-P-Stream", stream)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 400, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().Ingest(stream, []byte(test_payload), nil)
+	requireAPIStatus(t, err, 400)
 }
 
 func IngestOneEventForStaticSchemaStream_NewFieldInLog(t *testing.T, client HTTPClient, stream string) {
 	var test_payload string = `{"source_time":"2024-03-26", "level":"info","message":"Application is failing","version":"1.2.0","user_id":13912,"device_id":4138,"session_id":"abc","os":"Windows","host":"112.168.1.110","location":"ngeuprqhynuvpxgp","request_body":"rnkmffyawtdcindtrdqruyxbndbjpfsptzpwtujbmkwcqastmxwbvjwphmyvpnhordwljnodxhtvpjesjldtifswqbpyuhlcytmm","status_code":300,"app_meta":"ckgpibhmlusqqfunnpxbfxbc", "new_field_added_by":"ingestor 8020"}`
-	req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(test_payload))
-	req.Header.Add("X-P-Stream", stream)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 400, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().Ingest(stream, []byte(test_payload), nil)
+	requireAPIStatus(t, err, 400)
 }
 
 func IngestOneEventForStaticSchemaStream_SameFieldsInLog(t *testing.T, client HTTPClient, stream string) {
 	var test_payload string = `{"source_time":"2024-03-26", "level":"info","message":"Application is failing","version":"1.2.0","user_id":13912,"device_id":4138,"session_id":"abc","os":"Windows","host":"112.168.1.110","location":"ngeuprqhynuvpxgp","request_body":"rnkmffyawtdcindtrdqruyxbndbjpfsptzpwtujbmkwcqastmxwbvjwphmyvpnhordwljnodxhtvpjesjldtifswqbpyuhlcytmm","status_code":300,"app_meta":"ckgpibhmlusqqfunnpxbfxbc"}`
-	req, _ := client.NewRequest("POST", "ingest", bytes.NewBufferString(test_payload))
-	req.Header.Add("X-P-Stream", stream)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s resp %s", response.Status, readAsString(response.Body))
+	err := client.API().Ingest(stream, []byte(test_payload), nil)
+	require.NoErrorf(t, err, "Couldn't ingest into %s: %s", stream, err)
 }
 
 func QueryLogStreamCount(t *testing.T, client HTTPClient, stream string, count uint64) {
 	// Query last 30 minutes of data only
-	endTime := time.Now().Add(time.Second).Format(time.RFC3339Nano)
-	startTime := time.Now().Add(-30 * time.Minute).Format(time.RFC3339Nano)
-
-	query := map[string]interface{}{
-		"query":     "select count(*) as count from " + stream,
-		"startTime": startTime,
-		"endTime":   endTime,
-	}
-	queryJSON, _ := json.Marshal(query)
-	req, _ := client.NewRequest("POST", "query", bytes.NewBuffer(queryJSON))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	expected := fmt.Sprintf(`[{"count":%d}]`, count)
-	require.Equalf(t, expected, body, "Query count incorrect; Expected %s, Actual %s", expected, body)
+	now := time.Now()
+	actual, err := client.API().Count(stream, now.Add(-30*time.Minute), now.Add(time.Second))
+	require.NoErrorf(t, err, "Couldn't count the events of %s: %s", stream, err)
+	require.Equalf(t, count, actual, "Query count incorrect; Expected %d, Actual %d", count, actual)
 }
 
 func QueryLogStreamCount_Historical(t *testing.T, client HTTPClient, stream string, count uint64) {
-	// Query last 30 minutes of data only
+	// Query the days around a month ago only
 	now := time.Now()
-	startTime := now.AddDate(0, 0, -33).Format(time.RFC3339Nano)
-	endTime := now.AddDate(0, 0, -27).Format(time.RFC3339Nano)
-
-	query := map[string]interface{}{
-		"query":     "select count(*) as count from " + stream,
-		"startTime": startTime,
-		"endTime":   endTime,
-	}
-	queryJSON, _ := json.Marshal(query)
-	req, _ := client.NewRequest("POST", "query", bytes.NewBuffer(queryJSON))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	expected := fmt.Sprintf(`[{"count":%d}]`, count)
-	require.Equalf(t, expected, body, "Query count incorrect; Expected %s, Actual %s", expected, body)
+	actual, err := client.API().Count(stream, now.AddDate(0, 0, -33), now.AddDate(0, 0, -27))
+	require.NoErrorf(t, err, "Couldn't count the events of %s: %s", stream, err)
+	require.Equalf(t, count, actual, "Query count incorrect; Expected %d, Actual %d", count, actual)
 }
 
 func QueryTwoLogStreamCount(t *testing.T, client HTTPClient, stream1 string, stream2 string, count uint64) {
-	// Query last 30 minutes of data only
-	endTime := time.Now().Add(time.Second).Format(time.RFC3339Nano)
-	startTime := time.Now().Add(-30 * time.Minute).Format(time.RFC3339Nano)
+	sql := fmt.Sprintf("select sum(c) as count from (select count(*) as c from %s union all select count(*) as c from %s)", stream1, stream2)
+	rows := runQuery(t, client, sql)
+	require.Lenf(t, rows, 1, "Query %s returned %v", sql, rows)
+	require.Equalf(t, fmt.Sprint(count), fmt.Sprint(rows[0]["count"]), "Query count incorrect; Expected %d, Actual %v", count, rows[0]["count"])
+}
 
-	query := map[string]interface{}{
-		"query":     fmt.Sprintf("select sum(c) as count from (select count(*) as c from %s union all select count(*) as c from %s)", stream1, stream2),
-		"startTime": startTime,
-		"endTime":   endTime,
+func AssertQueryOK(t *testing.T, client HTTPClient, query string, args ...any) {
+	if len(args) != 0 {
+		query = fmt.Sprintf(query, args...)
 	}
-	queryJSON, _ := json.Marshal(query)
-	req, _ := client.NewRequest("POST", "query", bytes.NewBuffer(queryJSON))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	expected := fmt.Sprintf(`[{"count":%d}]`, count)
-	require.Equalf(t, expected, body, "Query count incorrect; Expected %s, Actual %s", expected, body)
+	runQuery(t, client, query)
 }
 
-func AssertQueryOK(t *testing.T, client HTTPClient, query string, args ...any) {
-	// Query last 30 minutes of data only
-	endTime := time.Now().Add(time.Second).Format(time.RFC3339Nano)
-	startTime := time.Now().Add(-30 * time.Minute).Format(time.RFC3339Nano)
+// Runs `sql` over the last 30 minutes and returns the rows.
+func runQuery(t *testing.T, client HTTPClient, sql string) []map[string]interface{} {
+	now := time.Now()
+	rows, err := client.API().Query(parseable.Query{SQL: sql, StartTime: now.Add(-30 * time.Minute), EndTime: now.Add(time.Second)})
+	require.NoErrorf(t, err, "Query %s failed: %s", sql, err)
+	return rows
+}
 
-	var finalQuery string
-	if len(args) == 0 {
-		finalQuery = query
-	} else {
-		finalQuery = fmt.Sprintf(query, args...)
-	}
+func AssertStreamSchema(t *testing.T, client HTTPClient, stream string, schema string) {
+	body, err := client.API().SchemaJSON(stream)
+	require.NoErrorf(t, err, "Couldn't get the schema of %s: %s", stream, err)
+	require.JSONEq(t, schema, string(body), "Get schema response doesn't match with expected schema")
+}
 
-	queryJSON, _ := json.Marshal(map[string]interface{}{
-		"query":     finalQuery,
-		"startTime": startTime,
-		"endTime":   endTime,
-	})
+func SetAlert(t *testing.T, client HTTPClient, stream string, alert string) {
+	err := client.API().SetAlertJSON(stream, []byte(alert))
+	require.NoErrorf(t, err, "Couldn't set the alert of %s: %s", stream, err)
+}
 
-	req, _ := client.NewRequest("POST", "query", bytes.NewBuffer(queryJSON))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
+func AssertAlert(t *testing.T, client HTTPClient, stream string, alert string) {
+	body, err := client.API().AlertJSON(stream)
+	require.NoErrorf(t, err, "Couldn't get the alert of %s: %s", stream, err)
+	require.JSONEq(t, alert, string(body), "Get alert response doesn't match with Alert config returned")
 }
 
-func AssertStreamSchema(t *testing.T, client HTTPClient, stream string, schema string) {
-	req, _ := client.NewRequest("GET", "logstream/"+stream+"/schema", nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	require.JSONEq(t, schema, body, "Get schema response doesn't match with expected schema")
+func SetRetention(t *testing.T, client HTTPClient, stream string, retention string) {
+	err := client.API().SetRetentionJSON(stream, []byte(retention))
+	require.NoErrorf(t, err, "Couldn't set the retention of %s: %s", stream, err)
+}
+
+func AssertRetention(t *testing.T, client HTTPClient, stream string, retention string) {
+	body, err := client.API().RetentionJSON(stream)
+	require.NoErrorf(t, err, "Couldn't get the retention of %s: %s", stream, err)
+	require.JSONEq(t, retention, string(body), "Get retention response doesn't match with retention config returned")
 }
 
 func CreateRole(t *testing.T, client HTTPClient, name string, role string) {
-	req, _ := client.NewRequest("PUT", "role/"+name, strings.NewReader(role))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().PutRoleJSON(name, []byte(role))
+	require.NoErrorf(t, err, "Couldn't create role %s: %s", name, err)
 }
 
 func AssertRole(t *testing.T, client HTTPClient, name string, role string) {
-	req, _ := client.NewRequest("GET", "role/"+name, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	require.JSONEq(t, role, body, "Get role response doesn't match with retention config returned")
+	body, err := client.API().RoleJSON(name)
+	require.NoErrorf(t, err, "Couldn't get role %s: %s", name, err)
+	require.JSONEq(t, role, string(body), "Get role response doesn't match with role created")
 }
 
 func CreateUser(t *testing.T, client HTTPClient, user string) string {
-	req, _ := client.NewRequest("POST", "user/"+user, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	return body
+	password, err := client.API().CreateUser(user, nil)
+	require.NoErrorf(t, err, "Couldn't create user %s: %s", user, err)
+	return password
 }
 
 func CreateUserWithRole(t *testing.T, client HTTPClient, user string, roles []string) string {
-	payload, _ := json.Marshal(roles)
-	req, _ := client.NewRequest("POST", "user/"+user, bytes.NewBuffer(payload))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	return body
+	password, err := client.API().CreateUser(user, roles)
+	require.NoErrorf(t, err, "Couldn't create user %s: %s", user, err)
+	return password
 }
 
 func AssignRolesToUser(t *testing.T, client HTTPClient, user string, roles []string) {
-	payload, _ := json.Marshal(roles)
-	req, _ := client.NewRequest("PUT", "user/"+user+"/role", bytes.NewBuffer(payload))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().SetUserRoles(user, roles)
+	require.NoErrorf(t, err, "Couldn't assign roles to user %s: %s", user, err)
 }
 
 func AssertUserRole(t *testing.T, client HTTPClient, user string, roleName, roleBody string) {
-	req, _ := client.NewRequest("GET", "user/"+user+"/role", nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	userRoleBody := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, userRoleBody)
-	expectedRoleBody := fmt.Sprintf(`{"%s":%s}`, roleName, roleBody)
-	require.JSONEq(t, userRoleBody, expectedRoleBody, "Get user role response doesn't match with expected role")
+	body, err := client.API().UserRolesJSON(user)
+	require.NoErrorf(t, err, "Couldn't get the roles of user %s: %s", user, err)
+	expected := fmt.Sprintf(`{"%s":%s}`, roleName, roleBody)
+	require.JSONEq(t, expected, string(body), "Get user role response doesn't match with expected role")
 }
 
 func RegenPassword(t *testing.T, client HTTPClient, user string) string {
-	req, _ := client.NewRequest("POST", "user/"+user+"/generate-new-password", nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	return body
+	password, err := client.API().ResetPassword(user)
+	require.NoErrorf(t, err, "Couldn't generate a new password for user %s: %s", user, err)
+	return password
 }
 
 func SetUserRole(t *testing.T, client HTTPClient, user string, roles []string) {
-	payload, _ := json.Marshal(roles)
-	req, _ := client.NewRequest("PUT", "user/"+user+"/role", bytes.NewBuffer(payload))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().SetUserRoles(user, roles)
+	require.NoErrorf(t, err, "Couldn't set roles of user %s: %s", user, err)
 }
 
 func DeleteUser(t *testing.T, client HTTPClient, user string) {
-	req, _ := client.NewRequest("DELETE", "user/"+user, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().DeleteUser(user)
+	require.NoErrorf(t, err, "Couldn't delete user %s: %s", user, err)
 }
 
 func DeleteRole(t *testing.T, client HTTPClient, roleName string) {
-	req, _ := client.NewRequest("DELETE", "role/"+roleName, nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().DeleteRole(roleName)
+	require.NoErrorf(t, err, "Couldn't delete role %s: %s", roleName, err)
 }
 
 func SetDefaultRole(t *testing.T, client HTTPClient, roleName string) {
-	payload, _ := json.Marshal(roleName)
-	req, _ := client.NewRequest("PUT", "role/default", bytes.NewBuffer(payload))
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().SetDefaultRole(roleName)
+	require.NoErrorf(t, err, "Couldn't set default role %s: %s", roleName, err)
 }
 
 func AssertDefaultRole(t *testing.T, client HTTPClient, roleName string) {
-	req, _ := client.NewRequest("GET", "role/default", nil)
-	response, err := client.Do(req)
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	body := readAsString(response.Body)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, body)
-	require.Equalf(t, roleName, body, "Get default role response doesn't match with expected role")
+	actual, err := client.API().DefaultRole()
+	require.NoErrorf(t, err, "Couldn't get the default role: %s", err)
+	require.Equalf(t, roleName, actual, "Get default role response doesn't match with expected role")
 }
 
 func PutSingleEventExpectErr(t *testing.T, client HTTPClient, stream string) {
@@ -456,11 +398,8 @@ func PutSingleEventExpectErr(t *testing.T, client HTTPClient, stream string) {
 		"address": "address",
 		"hairColor": "color"
 	}`
-	req, _ := client.NewRequest("POST", "logstream/"+stream, bytes.NewBufferString(payload))
-	response, err := client.Do(req)
-
-	require.NoErrorf(t, err, "Request failed when expected to pass: %s", err)
-	require.Equalf(t, 403, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().PostEvents(stream, []byte(payload))
+	requireAPIStatus(t, err, 403)
 }
 
 func PutSingleEvent(t *testing.T, client HTTPClient, stream string) {
@@ -478,86 +417,24 @@ func PutSingleEvent(t *testing.T, client HTTPClient, stream string) {
 		"address": "address",
 		"hairColor": "color"
 	}`
-	req, _ := client.NewRequest("POST", "logstream/"+stream, bytes.NewBufferString(payload))
-	response, err := client.Do(req)
-
-	require.NoErrorf(t, err, "Request failed: %s", err)
-	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	err := client.API().PostEvents(stream, []byte(payload))
+	require.NoErrorf(t, err, "Couldn't send an event to %s: %s", stream, err)
 }
 
+// Checks that a user holding one of `RoleEditor`, `RoleWriter`, `RoleReader`
+// or `Roleingestor` on `stream`, as named by `role`, can reach the API it
+// should but can't delete `stream`. `CheckRBACMatrix` covers the rest.
 func checkAPIAccess(t *testing.T, client HTTPClient, stream string, role string) {
-	switch role {
-	case "editor":
-		// Check access to non-protected API
-		req, _ := client.NewRequest("GET", "liveness", nil)
-		response, err := client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Check access to protected API with access
-		req, _ = client.NewRequest("GET", "logstream", nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Attempt to call protected API without access
-		req, _ = client.NewRequest("DELETE", "logstream/"+stream, nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 403, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-	case "writer":
-		// Check access to non-protected API
-		req, _ := client.NewRequest("GET", "liveness", nil)
-		response, err := client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Check access to protected API with access
-		req, _ = client.NewRequest("GET", "logstream", nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Attempt to call protected API without access
-		req, _ = client.NewRequest("DELETE", "logstream/"+stream, nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 403, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-	case "reader":
-		// Check access to non-protected API
-		req, _ := client.NewRequest("GET", "liveness", nil)
-		response, err := client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Check access to protected API with access
-		req, _ = client.NewRequest("GET", "logstream", nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Attempt to call protected API without access
-		req, _ = client.NewRequest("DELETE", "logstream/"+stream, nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 403, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-	case "ingestor":
-		// Check access to non-protected API
-		req, _ := client.NewRequest("GET", "liveness", nil)
-		response, err := client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
-
-		// Check access to protected API with access
-		PutSingleEvent(t, client, stream)
+	// Check access to non-protected API
+	assertRBACStatus(t, client, RBACEndpoint{Method: "GET", Path: fixedPath("liveness")}, stream, 200)
 
-		// Attempt to call protected API without access
-		req, _ = client.NewRequest("DELETE", "logstream/"+stream, nil)
-		response, err = client.Do(req)
-		require.NoErrorf(t, err, "Request failed: %s", err)
-		require.Equalf(t, 403, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+	// Check access to protected API with access
+	if role == "ingestor" {
+		PutSingleEvent(t, client, stream)
+	} else {
+		assertRBACStatus(t, client, RBACEndpoint{Method: "GET", Path: fixedPath("logstream")}, stream, 200)
 	}
+
+	// Attempt to call protected API without access
+	assertRBACStatus(t, client, RBACEndpoint{Method: "DELETE", Path: streamPath("")}, stream, 403)
 }
@@ -0,0 +1,203 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fakeFlogs(n int) []Flog {
+	flogs := make([]Flog, n)
+	for i := range flogs {
+		flogs[i] = Flog{
+			Host:      "192.168.0.1",
+			UserId:    "-",
+			Timestamp: "17/Oct/2026:10:00:00 +0000",
+			Method:    "GET",
+			Request:   "/index.html",
+			Protocol:  "HTTP/1.1",
+			Status:    200,
+			ByteCount: uint64(1000 + i),
+			Referer:   "-",
+		}
+	}
+	return flogs
+}
+
+func fakeIngest(t *testing.T, client HTTPClient, stream string, events interface{}) {
+	payload, _ := json.Marshal(events)
+	req, _ := client.NewRequest("POST", "ingest", bytes.NewBuffer(payload))
+	req.Header.Add("X-P-Stream", stream)
+	response, err := client.Do(req)
+	require.NoErrorf(t, err, "Request failed: %s", err)
+	require.Equalf(t, 200, response.StatusCode, "Server returned http code: %s and response: %s", response.Status, readAsString(response.Body))
+}
+
+func TestFakeStreamLifecycle(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+
+	CreateStream(t, client, "app")
+	fakeIngest(t, client, "app", fakeFlogs(50))
+	QueryLogStreamCount(t, client, "app", 50)
+	AssertStreamSchema(t, client, "app", FlogJsonSchema)
+	AssertQueryOK(t, client, "SELECT * FROM %s OFFSET 25 LIMIT 25", "app")
+
+	CreateStream(t, client, "app2")
+	fakeIngest(t, client, "app2", fakeFlogs(10))
+	QueryTwoLogStreamCount(t, client, "app", "app2", 60)
+
+	SetAlert(t, client, "app", AlertBody)
+	AssertAlert(t, client, "app", AlertBody)
+	SetRetention(t, client, "app", RetentionBody)
+	AssertRetention(t, client, "app", RetentionBody)
+
+	DeleteStream(t, client, "app")
+	DeleteStream(t, client, "app2")
+	require.Empty(t, fake.Events("app"))
+}
+
+func TestFakeTimePartition(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+
+	CreateStreamWithHeader(t, client, "historical", map[string]string{"X-P-Time-Partition": "source_time"})
+	IngestOneEventWithTimePartition_TimeStampMismatch(t, client, "historical")
+	IngestOneEventWithTimePartition_NoTimePartitionInLog(t, client, "historical")
+	IngestOneEventWithTimePartition_IncorrectDateTimeFormatTimePartitionInLog(t, client, "historical")
+
+	sourceTime := time.Now().AddDate(0, 0, -29).UTC().Format(time.RFC3339Nano)
+	fakeIngest(t, client, "historical", []map[string]string{{"source_time": sourceTime, "level": "info"}})
+	QueryLogStreamCount_Historical(t, client, "historical", 1)
+	QueryLogStreamCount(t, client, "historical", 0)
+}
+
+func TestFakeStaticSchema(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+
+	CreateStreamWithSchemaBody(t, client, "static", map[string]string{"X-P-Static-Schema-Flag": "true"})
+	IngestOneEventForStaticSchemaStream_NewFieldInLog(t, client, "static")
+	IngestOneEventForStaticSchemaStream_SameFieldsInLog(t, client, "static")
+	QueryLogStreamCount(t, client, "static", 1)
+}
+
+func TestFakeUsersAndRoles(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+
+	CreateStream(t, client, "app")
+	CreateRole(t, client, "dummyrole", dummyRole)
+	AssertRole(t, client, "dummyrole", dummyRole)
+
+	CreateUser(t, client, "dummyuser")
+	AssignRolesToUser(t, client, "dummyuser", []string{"dummyrole"})
+	AssertUserRole(t, client, "dummyuser", "dummyrole", dummyRole)
+	RegenPassword(t, client, "dummyuser")
+	DeleteUser(t, client, "dummyuser")
+
+	SetDefaultRole(t, client, "dummyrole")
+	AssertDefaultRole(t, client, "dummyrole")
+
+	password := CreateUser(t, client, "norole")
+	userClient := client
+	userClient.Username = "norole"
+	userClient.Password = password
+	PutSingleEventExpectErr(t, userClient, "app")
+
+	DeleteUser(t, client, "norole")
+	DeleteRole(t, client, "dummyrole")
+}
+
+func TestCheckAPIAccess(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	CreateStream(t, client, "app")
+
+	roles := map[string]string{
+		"editor":   RoleEditor,
+		"reader":   RoleReader("app"),
+		"writer":   RoleWriter("app"),
+		"ingestor": Roleingestor("app"),
+	}
+	for roleName, body := range roles {
+		t.Run(roleName, func(t *testing.T) {
+			CreateRole(t, client, roleName, body)
+			username := roleName + "_user"
+			password := CreateUserWithRole(t, client, username, []string{roleName})
+			userClient := client
+			userClient.Username = username
+			userClient.Password = password
+			checkAPIAccess(t, userClient, "app", roleName)
+			DeleteUser(t, client, username)
+			DeleteRole(t, client, roleName)
+		})
+	}
+}
+
+func TestFakeFaults(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	CreateStream(t, client, "app")
+	fakeIngest(t, client, "app", fakeFlogs(5))
+
+	t.Run("status", func(t *testing.T) {
+		fake.AddFault(FakeFault{Path: "query", Status: http.StatusServiceUnavailable, Body: "overloaded", Times: 1})
+		end := time.Now().Add(time.Second)
+		_, err := fetchLogStreamCount(client, "app", end.Add(-time.Minute), end)
+		require.ErrorContains(t, err, "503")
+		require.ErrorContains(t, err, "overloaded")
+
+		count, err := fetchLogStreamCount(client, "app", end.Add(-time.Minute), end)
+		require.NoError(t, err)
+		require.EqualValues(t, 5, count)
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		// Go's transport retries a GET once on a reused connection, so drop
+		// every request until the faults are cleared.
+		fake.AddFault(FakeFault{Method: http.MethodGet, Path: "logstream/app/schema", Drop: true})
+		_, err := FetchStreamSchema(client, "app")
+		require.Error(t, err)
+		fake.ClearFaults()
+
+		_, err = FetchStreamSchema(client, "app")
+		require.NoError(t, err)
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		fake.AddFault(FakeFault{Path: "liveness", Delay: 500 * time.Millisecond, Times: 1})
+		slow := client
+		slow.RequestTimeout = 100 * time.Millisecond
+		require.Error(t, checkEndpoint(slow, "liveness"))
+		require.NoError(t, checkEndpoint(slow, "liveness"))
+	})
+
+	t.Run("wait recovers", func(t *testing.T) {
+		fake.AddFault(FakeFault{Path: "query", Status: http.StatusInternalServerError, Times: 2})
+		WaitForCount(t, client, "app", 5, 10*time.Second)
+	})
+
+	fake.ClearFaults()
+	require.NoError(t, checkEndpoint(client, "logstream"))
+}
@@ -0,0 +1,428 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quest/parseable"
+)
+
+type TestStatus string
+
+const (
+	TestPassed  TestStatus = "passed"
+	TestFailed  TestStatus = "failed"
+	TestSkipped TestStatus = "skipped"
+)
+
+type TestResult struct {
+	Name     string        `json:"name"`
+	Status   TestStatus    `json:"status"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Requests sent while the test ran.
+	Calls []HTTPCall `json:"calls"`
+	// What a failed test logged, its failed assertions included.
+	Output string `json:"output,omitempty"`
+}
+
+type ReportSummary struct {
+	Tests   int `json:"tests"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Skipped int `json:"skipped"`
+}
+
+// Everything recorded about a run of a suite. This is what the JSON report
+// holds, the JUnit report is built from it.
+type RunReport struct {
+	Suite    string        `json:"suite"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	// Server the suite ran against, nil if it couldn't be asked.
+	Parseable      *parseable.About  `json:"parseable"`
+	ParseableError string            `json:"parseable_error,omitempty"`
+	Config         map[string]string `json:"config"`
+	Summary        ReportSummary     `json:"summary"`
+	Tests          []TestResult      `json:"tests"`
+}
+
+// Records the tests of a suite as they run, and the requests they make
+// through the clients `ObserveClient` gives them.
+type Reporter struct {
+	mu      sync.Mutex
+	report  RunReport
+	running map[int]*TestResult
+	nextID  int
+}
+
+func NewReporter(suite string, config Config, about *parseable.About, aboutErr error) *Reporter {
+	report := RunReport{
+		Suite:     suite,
+		Started:   time.Now(),
+		Parseable: about,
+		Config:    config.Settings(),
+		Tests:     make([]TestResult, 0),
+	}
+	if aboutErr != nil {
+		report.ParseableError = aboutErr.Error()
+	}
+	return &Reporter{report: report, running: make(map[int]*TestResult)}
+}
+
+// Records the requests of the tests `Reporter.Wrap` runs, by test name.
+var testObservers sync.Map
+
+// Copy of `client` whose requests are recorded as `t`'s, when `t` or the test
+// it is a subtest of was wrapped by a `Reporter`.
+func ObserveClient(t *testing.T, client HTTPClient) HTTPClient {
+	name := t.Name()
+	for {
+		if observer, ok := testObservers.Load(name); ok {
+			client.OnCall = observer.(func(HTTPCall))
+			return client
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return client
+		}
+		name = name[:i]
+	}
+}
+
+// Wraps `test` so that its result, and the requests it makes through the
+// clients `ObserveClient` gives it, are recorded once it and its subtests
+// finish.
+func (r *Reporter) Wrap(test testing.InternalTest) testing.InternalTest {
+	f := test.F
+	return testing.InternalTest{Name: test.Name, F: func(t *testing.T) {
+		id := r.start(test.Name)
+		testObservers.Store(t.Name(), func(call HTTPCall) { r.record(id, call) })
+		t.Cleanup(func() {
+			testObservers.Delete(t.Name())
+			r.finish(id, t)
+		})
+		f(t)
+	}}
+}
+
+func (r *Reporter) record(id int, call HTTPCall) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if test, ok := r.running[id]; ok {
+		test.Calls = append(test.Calls, call)
+	}
+}
+
+func (r *Reporter) start(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	r.running[r.nextID] = &TestResult{Name: name, Started: time.Now(), Calls: make([]HTTPCall, 0)}
+	return r.nextID
+}
+
+func (r *Reporter) finish(id int, t *testing.T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.running[id]
+	delete(r.running, id)
+	result.Duration = time.Since(result.Started)
+	switch {
+	case t.Failed():
+		result.Status = TestFailed
+		r.report.Summary.Failed++
+	case t.Skipped():
+		result.Status = TestSkipped
+		r.report.Summary.Skipped++
+	default:
+		result.Status = TestPassed
+		r.report.Summary.Passed++
+	}
+	r.report.Summary.Tests++
+	r.report.Tests = append(r.report.Tests, *result)
+	r.report.Duration = time.Since(r.report.Started)
+}
+
+// Copy of the report of the tests finished so far.
+func (r *Reporter) Report() RunReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := r.report
+	report.Tests = append([]TestResult{}, r.report.Tests...)
+	return report
+}
+
+// Output of a run of `go test`, split by top level test.
+type TestOutput struct {
+	mu      sync.Mutex
+	tests   map[string]*strings.Builder
+	current string
+}
+
+func NewTestOutput() *TestOutput {
+	return &TestOutput{tests: make(map[string]*strings.Builder)}
+}
+
+// Copies `output` to `w`, keeping what each test printed. Lines go to the
+// test named by the last `=== RUN`, `=== CONT`, `=== NAME` or `--- FAIL`
+// line before them, which is how `go test` marks whose output follows.
+func (o *TestOutput) Capture(output io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(output)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if _, err := io.WriteString(w, line); err != nil {
+				return err
+			}
+			o.record(line)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (o *TestOutput) record(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if line == "PASS\n" || line == "FAIL\n" {
+		// The result of the whole run.
+		o.current = ""
+		return
+	}
+	trimmed := strings.TrimLeft(line, " ")
+	header := strings.HasPrefix(trimmed, "=== ")
+	if header || strings.HasPrefix(trimmed, "--- ") {
+		if fields := strings.Fields(trimmed); len(fields) > 2 {
+			o.current, _, _ = strings.Cut(fields[2], "/")
+		}
+	}
+	if header || o.current == "" {
+		return
+	}
+	if o.tests[o.current] == nil {
+		o.tests[o.current] = &strings.Builder{}
+	}
+	o.tests[o.current].WriteString(line)
+}
+
+// What the top level test `name` printed, its `=== ` lines left out.
+func (o *TestOutput) Of(name string) string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if output := o.tests[name]; output != nil {
+		return output.String()
+	}
+	return ""
+}
+
+// Adds to the failed tests of the report what they printed.
+func (report *RunReport) AddOutput(output *TestOutput) {
+	for i, test := range report.Tests {
+		if test.Status == TestFailed {
+			report.Tests[i].Output = output.Of(test.Name)
+		}
+	}
+}
+
+func (report RunReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// One line per request, for the `system-out` of a test case.
+func formatCalls(calls []HTTPCall) string {
+	var b strings.Builder
+	for _, call := range calls {
+		outcome := fmt.Sprint(call.Status)
+		if call.Error != "" {
+			outcome = "error: " + call.Error
+		}
+		fmt.Fprintf(&b, "%s %s %s -> %s in %s", call.Time.Format(time.RFC3339Nano), call.Method, call.Url, outcome, call.Duration)
+		if call.Attempts > 1 {
+			fmt.Fprintf(&b, " after %d attempts", call.Attempts)
+		}
+		if call.RequestID != "" {
+			fmt.Fprintf(&b, " (request ID %s)", call.RequestID)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (report RunReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:      report.Suite,
+		Tests:     report.Summary.Tests,
+		Failures:  report.Summary.Failed,
+		Skipped:   report.Summary.Skipped,
+		Time:      junitSeconds(report.Duration),
+		Timestamp: report.Started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	if report.Parseable != nil {
+		suite.Properties = append(suite.Properties,
+			junitProperty{"parseable.version", report.Parseable.Version},
+			junitProperty{"parseable.commit", report.Parseable.Commit},
+			junitProperty{"parseable.mode", report.Parseable.Mode},
+		)
+	} else {
+		suite.Properties = append(suite.Properties, junitProperty{"parseable.error", report.ParseableError})
+	}
+	names := make([]string, 0, len(report.Config))
+	for name := range report.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		suite.Properties = append(suite.Properties, junitProperty{"config." + name, report.Config[name]})
+	}
+
+	for _, test := range report.Tests {
+		testCase := junitTestCase{
+			Name:      test.Name,
+			Classname: "quest." + report.Suite,
+			Time:      junitSeconds(test.Duration),
+			SystemOut: formatCalls(test.Calls),
+		}
+		switch test.Status {
+		case TestFailed:
+			testCase.Failure = &junitFailure{Message: test.Name + " failed", Text: test.Output}
+			if test.Output == "" {
+				testCase.Failure.Text = "See the test log for the failed assertions."
+			}
+		case TestSkipped:
+			testCase.Skipped = &struct{}{}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	suites := junitTestSuites{
+		Name:     "quest",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeReportFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return errors.Join(write(f), f.Close())
+}
+
+// Writes the report of the tests finished so far to the paths that aren't
+// empty.
+func (r *Reporter) Save(junitPath string, jsonPath string) error {
+	return r.Report().Save(junitPath, jsonPath)
+}
+
+// Writes the report to the paths that aren't empty.
+func (report RunReport) Save(junitPath string, jsonPath string) error {
+	var errs []error
+	if junitPath != "" {
+		errs = append(errs, writeReportFile(junitPath, report.WriteJUnit))
+	}
+	if jsonPath != "" {
+		errs = append(errs, writeReportFile(jsonPath, report.WriteJSON))
+	}
+	return errors.Join(errs...)
+}
+
+// Reads back a report written by WriteJSON.
+func LoadRunReport(path string) (RunReport, error) {
+	var report RunReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	return report, json.Unmarshal(data, &report)
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporterRecordsTests(t *testing.T) {
+	fake := NewFakeParseable(t)
+	client := fake.Client()
+	reporter := NewReporter("smoke", Config{QueryUrl: fake.Server.URL}, &parseable.About{Version: "v1.2.3"}, nil)
+
+	CreateStream(t, ObserveClient(t, client), "outside")
+	// The tests run at the same time, each request must still go to the
+	// test that sent it.
+	t.Run("group", func(t *testing.T) {
+		for _, stream := range []string{"app", "web"} {
+			stream := stream
+			t.Run(stream, reporter.Wrap(testing.InternalTest{Name: stream, F: func(t *testing.T) {
+				t.Parallel()
+				client := ObserveClient(t, client)
+				CreateStream(t, client, stream)
+				t.Run("ingest", func(t *testing.T) {
+					fakeIngest(t, ObserveClient(t, client), stream, map[string]string{"level": "info"})
+				})
+			}}).F)
+		}
+	})
+	t.Run("Skipped", reporter.Wrap(testing.InternalTest{Name: "Skipped", F: func(t *testing.T) {
+		t.Skip("nothing to do")
+	}}).F)
+
+	report := reporter.Report()
+	require.Equal(t, ReportSummary{Tests: 3, Passed: 2, Skipped: 1}, report.Summary)
+	require.Equal(t, "v1.2.3", report.Parseable.Version)
+
+	for _, created := range report.Tests[:2] {
+		require.Equal(t, TestPassed, created.Status)
+		require.Len(t, created.Calls, 2, created.Name)
+		require.Equal(t, http.MethodPut, created.Calls[0].Method)
+		require.Equal(t, fake.Server.URL+"/api/v1/logstream/"+created.Name, created.Calls[0].Url)
+		require.Equal(t, http.StatusOK, created.Calls[0].Status)
+		require.Equal(t, 1, created.Calls[0].Attempts)
+		require.Equal(t, http.MethodPost, created.Calls[1].Method)
+	}
+
+	require.Equal(t, TestSkipped, report.Tests[2].Status)
+	require.Empty(t, report.Tests[2].Calls)
+}
+
+func TestRunReportOutput(t *testing.T) {
+	output := strings.Join([]string{
+		"=== RUN   Alert",
+		"=== PAUSE Alert",
+		"=== RUN   Roles",
+		"    suites.go:10: creating roles",
+		"=== CONT  Alert",
+		"    test_utils.go:20: alert not set",
+		"=== RUN   Roles/reader",
+		"=== NAME  Alert",
+		"    test_utils.go:21: giving up",
+		"--- PASS: Roles (0.00s)",
+		"    --- PASS: Roles/reader (0.00s)",
+		"--- FAIL: Alert (1.00s)",
+		"FAIL",
+	}, "\n") + "\n"
+
+	captured := NewTestOutput()
+	var stdout bytes.Buffer
+	require.NoError(t, captured.Capture(strings.NewReader(output), &stdout))
+	require.Equal(t, output, stdout.String())
+	require.Equal(t, "    suites.go:10: creating roles\n--- PASS: Roles (0.00s)\n    --- PASS: Roles/reader (0.00s)\n", captured.Of("Roles"))
+
+	report := RunReport{Tests: []TestResult{{Name: "Alert", Status: TestFailed}, {Name: "Roles", Status: TestPassed}}}
+	report.AddOutput(captured)
+	require.Equal(t, "    test_utils.go:20: alert not set\n    test_utils.go:21: giving up\n--- FAIL: Alert (1.00s)\n", report.Tests[0].Output)
+	require.Empty(t, report.Tests[1].Output)
+}
+
+func TestRunReportFormats(t *testing.T) {
+	config, err := LoadConfig("", "", env(nil), nil)
+	require.NoError(t, err)
+	started := time.Date(2024, 3, 26, 18, 0, 0, 0, time.UTC)
+	report := NewReporter("load", config, nil, errors.New("connection refused")).Report()
+	report.Started = started
+	report.Duration = 3 * time.Second
+	report.Summary = ReportSummary{Tests: 2, Passed: 1, Failed: 1}
+	report.Tests = []TestResult{
+		{Name: "BatchEvents", Status: TestPassed, Started: started, Duration: time.Second, Calls: []HTTPCall{
+			{Time: started, Method: "POST", Url: "http://localhost:8000/api/v1/ingest", Status: 503, Attempts: 3, RequestID: "abc"},
+		}},
+		{Name: "SingleEvents", Status: TestFailed, Started: started, Duration: 2 * time.Second, Output: "    load.go:10: 3 of 100 events failed\n--- FAIL: SingleEvents (2.00s)\n"},
+	}
+
+	var junit bytes.Buffer
+	require.NoError(t, report.WriteJUnit(&junit))
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(junit.Bytes(), &suites))
+	require.Equal(t, 2, suites.Tests)
+	require.Equal(t, 1, suites.Failures)
+	suite := suites.Suites[0]
+	require.Equal(t, "load", suite.Name)
+	require.Equal(t, "2024-03-26T18:00:00", suite.Timestamp)
+	require.Contains(t, suite.Properties, junitProperty{"parseable.error", "connection refused"})
+	require.Contains(t, suite.Properties, junitProperty{"config.query-pass", "REDACTED"})
+	require.Contains(t, suite.Properties, junitProperty{"config.query-url", "http://localhost:8000"})
+	require.Equal(t, "1.000", suite.TestCases[0].Time)
+	require.Nil(t, suite.TestCases[0].Failure)
+	require.Contains(t, suite.TestCases[0].SystemOut, "POST http://localhost:8000/api/v1/ingest -> 503 in 0s after 3 attempts (request ID abc)")
+	require.NotNil(t, suite.TestCases[1].Failure)
+	require.Equal(t, report.Tests[1].Output, suite.TestCases[1].Failure.Text)
+
+	var encoded bytes.Buffer
+	require.NoError(t, report.WriteJSON(&encoded))
+	var decoded RunReport
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	require.Equal(t, report.Summary, decoded.Summary)
+	require.Equal(t, report.Tests, decoded.Tests)
+	require.Nil(t, decoded.Parseable)
+}
+
+func TestReporterSave(t *testing.T) {
+	dir := t.TempDir()
+	reporter := NewReporter("smoke", Config{}, nil, nil)
+	junitPath, jsonPath := filepath.Join(dir, "junit.xml"), filepath.Join(dir, "report.json")
+	require.NoError(t, reporter.Save(junitPath, jsonPath))
+	for _, path := range []string{junitPath, jsonPath} {
+		_, err := os.Stat(path)
+		require.NoError(t, err)
+	}
+	require.Error(t, reporter.Save(filepath.Join(dir, "missing", "junit.xml"), ""))
+
+	loaded, err := LoadRunReport(jsonPath)
+	require.NoError(t, err)
+	require.Equal(t, "smoke", loaded.Suite)
+	_, err = LoadRunReport(filepath.Join(dir, "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// Upper bound for waiting on data that is being synced by Parseable. It
+	// covers the one minute local sync plus the object store upload and, in
+	// distributed mode, the ingestor to query node propagation.
+	syncTimeout = 3 * time.Minute
+
+	pollInitialInterval = 500 * time.Millisecond
+	pollMaxInterval     = 10 * time.Second
+)
+
+var errWaitTimeout = errors.New("timed out waiting for condition")
+
+// Polls `check` with exponential backoff until it reports done or the timeout
+// expires. `check` returns a short description of what it observed, which is
+// returned alongside the timeout error so callers can report the last state.
+func pollUntil(timeout time.Duration, check func() (done bool, state string)) (string, error) {
+	deadline := time.Now().Add(timeout)
+	interval := pollInitialInterval
+	var state string
+
+	for {
+		var done bool
+		done, state = check()
+		if done {
+			return state, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return state, errWaitTimeout
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		time.Sleep(interval)
+
+		interval *= 2
+		if interval > pollMaxInterval {
+			interval = pollMaxInterval
+		}
+	}
+}
+
+func fetchLogStreamCount(client HTTPClient, stream string, startTime time.Time, endTime time.Time) (uint64, error) {
+	return client.API().Count(stream, startTime, endTime)
+}
+
+func waitForCountInWindow(t *testing.T, client HTTPClient, stream string, count uint64, timeout time.Duration, window func() (time.Time, time.Time)) {
+	state, err := pollUntil(timeout, func() (bool, string) {
+		startTime, endTime := window()
+		actual, err := fetchLogStreamCount(client, stream, startTime, endTime)
+		if err != nil {
+			return false, err.Error()
+		}
+		return actual == count, fmt.Sprintf("count=%d", actual)
+	})
+	if err != nil {
+		t.Fatalf("Waiting for %d events in stream %s: %s after %s; last observed: %s\n%s",
+			count, stream, err, timeout, state, streamDiagnostics(client, stream))
+	}
+}
+
+// Waits until querying the last 30 minutes of `stream` returns exactly `count`
+// events, failing the test with a diagnostic dump if it doesn't within `timeout`.
+func WaitForCount(t *testing.T, client HTTPClient, stream string, count uint64, timeout time.Duration) {
+	waitForCountInWindow(t, client, stream, count, timeout, func() (time.Time, time.Time) {
+		now := time.Now()
+		return now.Add(-30 * time.Minute), now.Add(time.Second)
+	})
+}
+
+// Same as `WaitForCount`, but over the window used by `QueryLogStreamCount_Historical`.
+func WaitForHistoricalCount(t *testing.T, client HTTPClient, stream string, count uint64, timeout time.Duration) {
+	waitForCountInWindow(t, client, stream, count, timeout, func() (time.Time, time.Time) {
+		now := time.Now()
+		return now.AddDate(0, 0, -33), now.AddDate(0, 0, -27)
+	})
+}
+
+// Waits until the schema returned for `stream` is JSON equal to `schema`.
+func WaitForSchema(t *testing.T, client HTTPClient, stream string, schema string, timeout time.Duration) {
+	var expected interface{}
+	if err := json.Unmarshal([]byte(schema), &expected); err != nil {
+		t.Fatalf("Expected schema is not valid JSON: %s", err)
+	}
+
+	state, err := pollUntil(timeout, func() (bool, string) {
+		body, err := client.API().SchemaJSON(stream)
+		if err != nil {
+			return false, err.Error()
+		}
+		var actual interface{}
+		if err := json.Unmarshal(body, &actual); err != nil {
+			return false, string(body)
+		}
+		return reflect.DeepEqual(expected, actual), string(body)
+	})
+	if err != nil {
+		t.Fatalf("Waiting for schema of stream %s: %s after %s; last observed: %s\n%s",
+			stream, err, timeout, state, streamDiagnostics(client, stream))
+	}
+}
+
+func asJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func isParquetFile(path string) bool {
+	return filepath.Ext(path) == ".parquet"
+}
+
+func listParquetObjects(stream string, store ObjectStore) ([]string, error) {
+	objects, err := store.List(stream + "/")
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if isParquetFile(object.Key) {
+			keys = append(keys, object.Key)
+		}
+	}
+	return keys, err
+}
+
+// Waits until at least `count` parquet objects exist under the `stream`
+// prefix of `store`, and returns their keys.
+func WaitForParquetObjects(t *testing.T, store ObjectStore, stream string, count int, timeout time.Duration) []string {
+	var keys []string
+	state, err := pollUntil(timeout, func() (bool, string) {
+		var err error
+		keys, err = listParquetObjects(stream, store)
+		if err != nil {
+			return false, err.Error()
+		}
+		return len(keys) >= count, fmt.Sprintf("%d parquet objects: %v", len(keys), keys)
+	})
+	if err != nil {
+		t.Fatalf("Waiting for %d parquet objects of stream %s in %s: %s after %s; last observed: %s",
+			count, stream, store, err, timeout, state)
+	}
+	return keys
+}
+
+// Collects whatever the server can tell about `stream`, for use in failure
+// messages. Errors are included inline rather than returned.
+func streamDiagnostics(client HTTPClient, stream string) string {
+	api := client.API()
+	var dump strings.Builder
+	dump.WriteString("--- diagnostics for stream " + stream + " ---\n")
+	report := func(what string, value interface{}, err error) {
+		if err != nil {
+			fmt.Fprintf(&dump, "%s: %s\n", what, err)
+			return
+		}
+		fmt.Fprintf(&dump, "%s: %s\n", what, asJSON(value))
+	}
+	streams, err := api.ListStreams()
+	report("streams", streams, err)
+	stats, err := api.Stats(stream)
+	report("stats", stats, err)
+	schema, err := api.Schema(stream)
+	report("schema", schema, err)
+	return dump.String()
+}
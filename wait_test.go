@@ -0,0 +1,47 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollUntil(t *testing.T) {
+	t.Run("done", func(t *testing.T) {
+		calls := 0
+		state, err := pollUntil(5*time.Second, func() (bool, string) {
+			calls++
+			return calls == 2, fmt.Sprintf("calls=%d", calls)
+		})
+		require.NoError(t, err)
+		require.Equal(t, "calls=2", state)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		start := time.Now()
+		state, err := pollUntil(time.Second, func() (bool, string) {
+			return false, "never"
+		})
+		require.ErrorIs(t, err, errWaitTimeout)
+		require.Equal(t, "never", state)
+		require.Less(t, time.Since(start), 3*time.Second)
+	})
+}
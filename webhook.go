@@ -0,0 +1,265 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A request the webhook sink received.
+type WebhookDelivery struct {
+	Received time.Time
+	Path     string
+	Header   http.Header
+	Body     []byte
+}
+
+// Local HTTP server alert targets are pointed at. It answers every request
+// with 200 and records it.
+type WebhookSink struct {
+	// Base URL Parseable reaches the sink at.
+	URL string
+
+	listener net.Listener
+	server   *http.Server
+
+	mu         sync.Mutex
+	deliveries []WebhookDelivery
+}
+
+// Starts a sink listening on `listen`, e.g. `:9000`. `advertised` is the URL
+// Parseable reaches it at; `http://127.0.0.1:{port}` when empty.
+func NewWebhookSink(listen string, advertised string) (*WebhookSink, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	if advertised == "" {
+		advertised = fmt.Sprintf("http://127.0.0.1:%d", listener.Addr().(*net.TCPAddr).Port)
+	}
+	sink := &WebhookSink{URL: strings.TrimSuffix(advertised, "/"), listener: listener}
+	sink.server = &http.Server{Handler: http.HandlerFunc(sink.receive)}
+	go sink.server.Serve(listener)
+	return sink, nil
+}
+
+func (sink *WebhookSink) receive(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sink.mu.Lock()
+	sink.deliveries = append(sink.deliveries, WebhookDelivery{time.Now(), r.URL.Path, r.Header.Clone(), body})
+	sink.mu.Unlock()
+}
+
+// URL of `path` on the sink, for an alert target.
+func (sink *WebhookSink) Endpoint(path string) string {
+	return sink.URL + path
+}
+
+// Requests received on `path` so far, oldest first.
+func (sink *WebhookSink) Deliveries(path string) []WebhookDelivery {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	var deliveries []WebhookDelivery
+	for _, delivery := range sink.deliveries {
+		if delivery.Path == path {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// Waits until `path` received at least `n` requests and returns them.
+func (sink *WebhookSink) WaitForDeliveries(path string, n int, timeout time.Duration) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	state, err := pollUntil(timeout, func() (bool, string) {
+		deliveries = sink.Deliveries(path)
+		return len(deliveries) >= n, fmt.Sprintf("%d deliveries", len(deliveries))
+	})
+	if err != nil {
+		return deliveries, fmt.Errorf("waiting for %d deliveries to %s: %w, got %s", n, path, err, state)
+	}
+	return deliveries, nil
+}
+
+func (sink *WebhookSink) Close() error {
+	return sink.server.Close()
+}
+
+// Parses a repeat interval the way Parseable does, e.g. `3m 20s`.
+func parseRepeatInterval(interval string) (time.Duration, error) {
+	return time.ParseDuration(strings.ReplaceAll(interval, " ", ""))
+}
+
+// Checks that there are exactly `repeat.Times` deliveries and that they are
+// at least `repeat.Interval`, less `slack`, apart.
+func checkRepeats(deliveries []WebhookDelivery, repeat parseable.AlertRepeat, slack time.Duration) error {
+	interval, err := parseRepeatInterval(repeat.Interval)
+	if err != nil {
+		return err
+	}
+	if len(deliveries) != repeat.Times {
+		return fmt.Errorf("got %d deliveries, expected %d", len(deliveries), repeat.Times)
+	}
+	for i := 1; i < len(deliveries); i++ {
+		if gap := deliveries[i].Received.Sub(deliveries[i-1].Received); gap < interval-slack {
+			return fmt.Errorf("delivery %d came %s after the previous one, expected at least %s", i, gap, repeat.Interval)
+		}
+	}
+	return nil
+}
+
+// Header the webhook targets of `SinkAlert` send, holding the target type.
+const alertTargetHeader = "X-Quest-Target"
+
+// `AlertBody` with every target pointed at `{prefix}/{type}` on `sink` and
+// repeating as `repeats` has it for its type. Webhook targets also send
+// `alertTargetHeader`; Slack ones can't send headers.
+func SinkAlert(t *testing.T, sink *WebhookSink, prefix string, repeats map[string]parseable.AlertRepeat) parseable.AlertConfig {
+	var config parseable.AlertConfig
+	require.NoError(t, json.Unmarshal([]byte(AlertBody), &config))
+	for i := range config.Alerts {
+		for j := range config.Alerts[i].Targets {
+			target := &config.Alerts[i].Targets[j]
+			target.Endpoint = sink.Endpoint(prefix + "/" + target.Type)
+			if target.Type == "webhook" {
+				target.Headers = map[string]string{alertTargetHeader: target.Type}
+			}
+			if repeat, ok := repeats[target.Type]; ok {
+				target.Repeat = &repeat
+			}
+		}
+	}
+	return config
+}
+
+// Starts a sink for the test from `-webhook-listen` and `-webhook-url`. Skips
+// the test when Parseable is elsewhere and `-webhook-url` doesn't say where
+// it can reach the sink.
+func StartWebhookSink(t *testing.T) *WebhookSink {
+	advertised := NewGlob.Config.WebhookUrl
+	if advertised == "" && !isLoopback(NewGlob.QueryUrl) {
+		t.Skipf("Parseable at %s can't reach a webhook sink on this host; set -webhook-url to check alert delivery", NewGlob.QueryUrl.Host)
+	}
+	sink, err := NewWebhookSink(NewGlob.Config.WebhookListen, advertised)
+	require.NoErrorf(t, err, "Couldn't start the webhook sink: %s", err)
+	t.Cleanup(func() { sink.Close() })
+	return sink
+}
+
+func isLoopback(u url.URL) bool {
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// Repeats of the targets of the alerts `CheckAlertDelivery` sets, short
+// enough for a test.
+var testAlertRepeats = map[string]parseable.AlertRepeat{
+	"webhook": {Interval: "2s", Times: 3},
+	"slack":   {Interval: "3s", Times: 2},
+}
+
+// Sets an alert on `stream` pointed at `sink`, ingests events matching its
+// `status_code != 500` rule and checks that every target is called as many
+// times and as far apart as it should, with the alert in the payload.
+func CheckAlertDelivery(t *testing.T, client HTTPClient, sink *WebhookSink, stream string) {
+	prefix := "/" + stream
+	config := SinkAlert(t, sink, prefix, testAlertRepeats)
+	err := client.API().SetAlert(stream, config)
+	require.NoErrorf(t, err, "Couldn't set alert on %s: %s", stream, err)
+	err = client.API().Ingest(stream, []byte(`[{"status_code": 200, "message": "ok"}, {"status_code": 404, "message": "not found"}]`), nil)
+	require.NoErrorf(t, err, "Couldn't ingest into %s: %s", stream, err)
+
+	alert := config.Alerts[0]
+	for _, target := range alert.Targets {
+		path := prefix + "/" + target.Type
+		interval, _ := parseRepeatInterval(target.Repeat.Interval)
+		deliveries, err := sink.WaitForDeliveries(path, target.Repeat.Times, time.Duration(target.Repeat.Times+1)*interval+syncTimeout)
+		require.NoErrorf(t, err, "Alert %s wasn't delivered to its %s target: %s", alert.Name, target.Type, err)
+
+		// Give the target the time for one more call, which it mustn't make.
+		time.Sleep(2 * interval)
+		deliveries = sink.Deliveries(path)
+		require.NoErrorf(t, checkRepeats(deliveries, *target.Repeat, 500*time.Millisecond), "Deliveries to the %s target don't repeat as configured", target.Type)
+
+		for _, delivery := range deliveries {
+			message := string(delivery.Body)
+			switch target.Type {
+			case "webhook":
+				require.Equal(t, "webhook", delivery.Header.Get(alertTargetHeader), "Webhook target didn't send its headers")
+			case "slack":
+				var payload struct {
+					Text string `json:"text"`
+				}
+				require.NoErrorf(t, json.Unmarshal(delivery.Body, &payload), "Slack target sent %s, not a JSON message", delivery.Body)
+				message = payload.Text
+			}
+			require.Containsf(t, message, alert.Name, "Payload of the %s target doesn't name the alert", target.Type)
+			require.Containsf(t, message, alert.Message, "Payload of the %s target doesn't hold the alert message", target.Type)
+		}
+	}
+}
+
+// Sets an alert on `stream` pointed at `sink` and ingests events that don't
+// match its rule, which mustn't call any target.
+func CheckAlertNotDelivered(t *testing.T, client HTTPClient, sink *WebhookSink, stream string) {
+	prefix := "/" + stream
+	config := SinkAlert(t, sink, prefix, testAlertRepeats)
+	err := client.API().SetAlert(stream, config)
+	require.NoErrorf(t, err, "Couldn't set alert on %s: %s", stream, err)
+	err = client.API().Ingest(stream, []byte(`[{"status_code": 500, "message": "error"}]`), nil)
+	require.NoErrorf(t, err, "Couldn't ingest into %s: %s", stream, err)
+
+	time.Sleep(5 * time.Second)
+	for _, target := range config.Alerts[0].Targets {
+		require.Emptyf(t, sink.Deliveries(prefix+"/"+target.Type), "Events not matching the rule triggered the %s target", target.Type)
+	}
+}
+
+// Checks alert delivery end to end against a webhook sink: alerts on a
+// stream with matching events call their targets, alerts on one without
+// don't. Parseable only evaluates alerts on the node events are sent to, so
+// this skips in distributed mode.
+func CheckAlertLifecycle(t *testing.T, client HTTPClient) {
+	if NewGlob.IngestorUrl.String() != "" {
+		t.Skip("Alert delivery is only checked against a standalone Parseable")
+	}
+	sink := StartWebhookSink(t)
+	t.Run("matching", func(t *testing.T) {
+		t.Parallel()
+		CheckAlertDelivery(t, client, sink, NewTestStream(t, client, parseable.StreamOptions{}))
+	})
+	t.Run("not-matching", func(t *testing.T) {
+		t.Parallel()
+		CheckAlertNotDelivered(t, client, sink, NewTestStream(t, client, parseable.StreamOptions{}))
+	})
+}
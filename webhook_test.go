@@ -0,0 +1,124 @@
+// Copyright (c) 2023 Cloudnatively Services Pvt Ltd
+//
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"quest/parseable"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSinkRecordsDeliveries(t *testing.T) {
+	sink, err := NewWebhookSink("127.0.0.1:0", "")
+	require.NoError(t, err)
+	defer sink.Close()
+	require.True(t, strings.HasPrefix(sink.URL, "http://127.0.0.1:"), sink.URL)
+
+	request, err := http.NewRequest(http.MethodPost, sink.Endpoint("/a/webhook"), strings.NewReader("fired"))
+	require.NoError(t, err)
+	request.Header.Set(alertTargetHeader, "webhook")
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusOK, response.StatusCode)
+
+	deliveries, err := sink.WaitForDeliveries("/a/webhook", 1, time.Second)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, "fired", string(deliveries[0].Body))
+	require.Equal(t, "webhook", deliveries[0].Header.Get(alertTargetHeader))
+	require.Empty(t, sink.Deliveries("/a/slack"))
+
+	_, err = sink.WaitForDeliveries("/a/slack", 1, 100*time.Millisecond)
+	require.Error(t, err)
+}
+
+func TestWebhookSinkAdvertisedURL(t *testing.T) {
+	sink, err := NewWebhookSink("127.0.0.1:0", "http://quest.internal:9000/")
+	require.NoError(t, err)
+	defer sink.Close()
+	require.Equal(t, "http://quest.internal:9000/x/slack", sink.Endpoint("/x/slack"))
+}
+
+func TestSinkAlert(t *testing.T) {
+	sink := &WebhookSink{URL: "http://127.0.0.1:1234"}
+	config := SinkAlert(t, sink, "/stream", testAlertRepeats)
+	require.Len(t, config.Alerts, 1)
+	require.Equal(t, "Status Alert", config.Alerts[0].Name)
+	for _, target := range config.Alerts[0].Targets {
+		require.Equal(t, "http://127.0.0.1:1234/stream/"+target.Type, target.Endpoint)
+		require.Equal(t, testAlertRepeats[target.Type], *target.Repeat)
+		if target.Type == "webhook" {
+			require.Equal(t, map[string]string{alertTargetHeader: "webhook"}, target.Headers)
+		} else {
+			require.Empty(t, target.Headers)
+		}
+	}
+}
+
+func TestParseRepeatInterval(t *testing.T) {
+	for interval, expected := range map[string]time.Duration{
+		"3m 20s": 200 * time.Second,
+		"3m":     3 * time.Minute,
+		"2s":     2 * time.Second,
+	} {
+		actual, err := parseRepeatInterval(interval)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, interval)
+	}
+	_, err := parseRepeatInterval("soon")
+	require.Error(t, err)
+}
+
+func TestCheckRepeats(t *testing.T) {
+	start := time.Now()
+	at := func(offsets ...time.Duration) []WebhookDelivery {
+		var deliveries []WebhookDelivery
+		for _, offset := range offsets {
+			deliveries = append(deliveries, WebhookDelivery{Received: start.Add(offset)})
+		}
+		return deliveries
+	}
+	repeat := parseable.AlertRepeat{Interval: "2s", Times: 3}
+	slack := 100 * time.Millisecond
+
+	require.NoError(t, checkRepeats(at(0, 2*time.Second, 4*time.Second), repeat, slack))
+	require.NoError(t, checkRepeats(at(0, 1950*time.Millisecond, 4*time.Second), repeat, slack))
+	require.ErrorContains(t, checkRepeats(at(0, 2*time.Second), repeat, slack), "got 2 deliveries, expected 3")
+	require.ErrorContains(t, checkRepeats(at(0, 2*time.Second, 4*time.Second, 6*time.Second), repeat, slack), "got 4 deliveries")
+	require.ErrorContains(t, checkRepeats(at(0, time.Second, 3*time.Second), repeat, slack), "delivery 1 came 1s after")
+}
+
+func TestIsLoopback(t *testing.T) {
+	for raw, expected := range map[string]bool{
+		"http://localhost:8000":            true,
+		"http://127.0.0.1:8000":            true,
+		"http://[::1]:8000":                true,
+		"http://host.docker.internal:8000": false,
+		"https://demo.parseable.io":        false,
+	} {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		require.Equal(t, expected, isLoopback(*u), raw)
+	}
+}